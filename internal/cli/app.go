@@ -3,21 +3,28 @@ package cli
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log/slog"
+	"net/http"
+	"net/http/httptrace"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/DishanRajapaksha/industrial-cli-kit/command"
 	"github.com/DishanRajapaksha/industrial-cli-kit/exitcode"
 	"github.com/hooklift/gowsdl/soap"
+	"gopkg.in/yaml.v3"
 
 	"opc-xml-da-cli/internal/config"
+	"opc-xml-da-cli/internal/httpauth"
 	"opc-xml-da-cli/internal/output"
+	"opc-xml-da-cli/internal/sink"
 	"opc-xml-da-cli/service"
 )
 
@@ -47,6 +54,9 @@ type commandOptions struct {
 	Profile        string
 	Format         string
 	Endpoint       string
+	Endpoints      []string
+	Failover       config.FailoverConfig
+	Retry          config.RetryConfig
 	BrowsePath     string
 	BrowseItemPath string
 	BrowseDepth    int
@@ -54,6 +64,10 @@ type commandOptions struct {
 	ReadItemPath   string
 	ReadItems      []itemRef
 	DumpHTTP       bool
+	DumpHTTPFile   string
+	DumpHTTPMax    int64
+	Record         string
+	Replay         string
 	LogLevel       string
 	Verbose        bool
 	Debug          bool
@@ -63,6 +77,12 @@ type commandOptions struct {
 	RequestTimeout time.Duration
 	Username       string
 	Password       string
+	PasswordStdin  bool
+	Auth           string
+	TLS            config.TLSConfig
+	HTTP           config.HTTPConfig
+	Quirks         service.Quirks
+	Sink           string
 }
 
 func defaultCommandOptions() commandOptions {
@@ -70,6 +90,7 @@ func defaultCommandOptions() commandOptions {
 		ConfigPath:     config.DefaultConfigPath,
 		Format:         "table",
 		BrowseDepth:    defaultBrowseDepth,
+		DumpHTTPMax:    DefaultHARMaxBodyBytes,
 		LogLevel:       defaultLogLevel,
 		HTTPTimeout:    defaultHTTPTimeout,
 		RequestTimeout: defaultRequestTimeout,
@@ -117,10 +138,24 @@ func (a *App) Run(args []string) int {
 		err = a.read(args[1:])
 	case "watch":
 		err = a.watch(args[1:])
+	case "exporter":
+		err = a.exporter(args[1:])
+	case "query":
+		err = a.query(args[1:])
+	case "serve":
+		err = a.serve(args[1:])
+	case "simulate":
+		err = a.simulate(args[1:])
+	case "proxy":
+		err = a.proxy(args[1:])
+	case "call":
+		err = a.call(args[1:])
 	case "test-connection":
 		err = a.testConnection(args[1:])
 	case "validate-config":
 		err = a.validateConfig(args[1:])
+	case "config":
+		err = a.configCommand(args[1:])
 	case "init-config":
 		err = a.initConfig(args[1:])
 	case "completions":
@@ -181,9 +216,22 @@ func isConfigError(err error) bool {
 		"usage:",
 		"invalid output format",
 		"endpoint is required",
+		"endpoint \"",
+		"endpoints, not both",
+		"failover.",
+		"retry.",
+		"http.proxy",
+		"http.max_",
+		"http.idle_conn_timeout",
+		"http.disable_keep_alives",
+		"http.compression",
+		"http.headers",
+		"resolve http.",
+		"quirks.",
 		"read config ",
 		"parse config ",
 		"profile ",
+		"undefined variable ",
 		"http_timeout",
 		"request_timeout",
 		"refusing to overwrite",
@@ -191,7 +239,31 @@ func isConfigError(err error) bool {
 		"browse-depth",
 		"at least one --item-name or --item-path is required",
 		"--interval must be greater than zero",
+		"--stream-interval must be greater than zero",
 		"choose either browse or read options, not both",
+		"open sink: ",
+		"--sink requires --watch",
+		"open capture database: ",
+		"--db is required",
+		"open recording: ",
+		"open HAR file: ",
+		"load TLS ",
+		"auth \"",
+		"requires --username",
+		"resolve password: ",
+		"config key ",
+		"unknown config ",
+		"usage: config ",
+		"would not load after the change",
+		"--password-stdin",
+		"tls.",
+		"--record and --replay",
+		"load model ",
+		"--upstream",
+		"--operation",
+		"--body",
+		"--from: ",
+		"--to: ",
 	}
 	for _, fragment := range configFragments {
 		if strings.Contains(msg, fragment) {
@@ -209,7 +281,8 @@ func isOutputError(err error) bool {
 	msg := err.Error()
 	return strings.HasPrefix(msg, "print status:") ||
 		strings.HasPrefix(msg, "print read:") ||
-		strings.HasPrefix(msg, "print watch:")
+		strings.HasPrefix(msg, "print watch:") ||
+		strings.HasPrefix(msg, "print query:")
 }
 
 func isRequestError(err error) bool {
@@ -217,7 +290,8 @@ func isRequestError(err error) bool {
 	return strings.HasPrefix(msg, "get status:") ||
 		strings.HasPrefix(msg, "browse:") ||
 		strings.HasPrefix(msg, "read:") ||
-		strings.HasPrefix(msg, "watch:")
+		strings.HasPrefix(msg, "watch:") ||
+		strings.HasPrefix(msg, "query:")
 }
 
 func isConnectionError(err error) bool {
@@ -273,37 +347,85 @@ func (a *App) initConfig(args []string) error {
 func (a *App) validateConfig(args []string) error {
 	configPath := config.DefaultConfigPath
 	profile := ""
+	sources := false
 	fs := a.newFlagSet("validate-config")
 	fs.StringVar(&configPath, "config", configPath, "YAML config file")
 	fs.StringVar(&profile, "profile", profile, "config profile name")
+	fs.BoolVar(&sources, "sources", false, "also show each effective setting and where it came from")
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
-	cfg, err := config.LoadClientConfigForProfile(configPath, profile)
+	if path, ok := os.LookupEnv(config.EnvConfig); ok && path != "" && !visitedFlags(fs)["config"] {
+		configPath = path
+	}
+	effective, err := config.Load(config.LoadOptions{Path: configPath, Profile: profile, LookupEnv: os.LookupEnv})
 	if err != nil {
 		return err
 	}
-	if err := config.ValidateClientConfig(cfg); err != nil {
+	if err := config.ValidateClientConfig(effective.ClientConfig); err != nil {
 		return err
 	}
+	for _, key := range config.PlaintextSecrets(effective.File) {
+		fmt.Fprintf(a.out, "WARNING: %s is stored in plaintext; use env:, file:, or cmd: references instead\n", key)
+	}
+	if sources {
+		if err := printConfigSources(a.out, effective); err != nil {
+			return err
+		}
+	}
 	fmt.Fprintln(a.out, "config validation: PASS")
 	return nil
 }
 
+// printConfigSources lists each effective setting with the layer it came
+// from.
+func printConfigSources(out io.Writer, effective config.Effective) error {
+	var rows [][]string
+	if effective.Profile != "" {
+		rows = append(rows, []string{"profile", effective.Profile, effective.Sources["profile"]})
+	}
+	for _, field := range config.Fields(effective.ClientConfig) {
+		rows = append(rows, []string{field.Key, field.Value, effective.Sources[field.Key]})
+	}
+	return output.WriteTable(out, []string{"SETTING", "VALUE", "SOURCE"}, rows)
+}
+
 func (a *App) status(args []string) error {
 	opts := defaultCommandOptions()
+	watch := false
+	interval := 5 * time.Second
+	duration := time.Duration(0)
 	fs := a.newFlagSet("status")
-	addCommonFlags(fs, &opts, "output format: table, text, json, or csv")
+	addCommonFlags(fs, &opts, "output format: table, text, json, or csv; text, jsonl, or csv with --watch")
+	fs.BoolVar(&watch, "watch", false, "poll the server status until interrupted")
+	fs.DurationVar(&interval, "interval", interval, "poll interval for --watch")
+	fs.DurationVar(&duration, "duration", duration, "stop --watch after this duration; zero runs until interrupted")
+	fs.StringVar(&opts.Sink, "sink", "", "with --watch, also send status events to a sink URL, such as webhook://host/path")
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
 	if err := opts.applyConfig(fs); err != nil {
 		return err
 	}
-	if err := validateSnapshotFormat(opts.Format); err != nil {
+	if !watch {
+		if opts.Sink != "" {
+			return fmt.Errorf("--sink requires --watch")
+		}
+		if err := validateSnapshotFormat(opts.Format); err != nil {
+			return err
+		}
+		return a.runStatus(opts)
+	}
+	if interval <= 0 {
+		return fmt.Errorf("--interval must be greater than zero")
+	}
+	if output.NormaliseFormat(opts.Format) == output.FormatTable {
+		opts.Format = output.FormatText
+	}
+	if err := validateStatusWatchFormat(opts.Format); err != nil {
 		return err
 	}
-	return a.runStatus(opts)
+	return a.runStatusWatch(opts, interval, duration)
 }
 
 func (a *App) browse(args []string) error {
@@ -334,7 +456,7 @@ func (a *App) read(args []string) error {
 	var itemPaths stringList
 	itemsFile := ""
 	fs := a.newFlagSet("read")
-	addCommonFlags(fs, &opts, "output format: table, text, json, or csv")
+	addCommonFlags(fs, &opts, "output format: table, text, json, csv, or influx")
 	fs.Var(&itemNames, "item-name", "OPC read item name; repeat for multiple items")
 	fs.Var(&itemPaths, "item-path", "OPC read item path; repeat for multiple items")
 	fs.StringVar(&itemsFile, "items", "", "path to file with one item name per line")
@@ -369,12 +491,13 @@ func (a *App) watch(args []string) error {
 	interval := time.Second
 	duration := time.Duration(0)
 	fs := a.newFlagSet("watch")
-	addCommonFlags(fs, &opts, "output format: text, jsonl, or csv")
+	addCommonFlags(fs, &opts, "output format: text, jsonl, csv, or influx")
 	fs.Var(&itemNames, "item-name", "OPC read item name; repeat for multiple items")
 	fs.Var(&itemPaths, "item-path", "OPC read item path; repeat for multiple items")
 	fs.StringVar(&itemsFile, "items", "", "path to file with one item name per line")
 	fs.DurationVar(&interval, "interval", interval, "poll interval")
 	fs.DurationVar(&duration, "duration", duration, "stop after this duration; zero runs until interrupted")
+	fs.StringVar(&opts.Sink, "sink", "", "also publish samples to a sink URL: mqtt://, influx://, sqlite:, or webhook://")
 	fs.StringVar(&opts.ReadPath, "read-path", "", "deprecated alias for --item-name")
 	fs.StringVar(&opts.ReadItemPath, "read-item-path", "", "deprecated alias for --item-path")
 	if err := fs.Parse(args); err != nil {
@@ -416,7 +539,19 @@ func (a *App) testConnection(args []string) error {
 	}
 	fmt.Fprintln(a.out, "Connection diagnostics")
 	fmt.Fprintf(a.out, "Endpoint: %s\n", opts.Endpoint)
+	var tlsState *tls.ConnectionState
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			if err == nil {
+				tlsState = &state
+			}
+		},
+	})
 	resp, err := FetchServerStatus(ctx, opcService, opts.Locale, opts.ClientHandle)
+	if f, ok := unwrapRetry(opcService).(*failoverService); ok && f.activeEndpoint() != opts.Endpoint {
+		fmt.Fprintf(a.out, "Failed over to: %s\n", f.activeEndpoint())
+	}
+	printTLSState(a.out, tlsState, time.Now())
 	if err != nil {
 		fmt.Fprintf(a.out, "OPC XML-DA GetStatus: FAIL (%v)\n", err)
 		fmt.Fprintln(a.out, "RESULT: FAIL")
@@ -533,16 +668,29 @@ func (a *App) runWatch(opts commandOptions, interval, duration time.Duration) er
 	if len(opts.ReadItems) == 0 {
 		return fmt.Errorf("at least one --item-name or --item-path is required")
 	}
-	ctx, opcService, err := a.newService(opts)
+	_, opcService, err := a.newService(opts)
 	if err != nil {
 		return err
 	}
-	runCtx := ctx
-	var cancel context.CancelFunc
+	a.reportEndpointSwitches(opcService, opts.Format)
+	// The watch loop outlives a single request, so each read gets its own
+	// request timeout instead of sharing the one from newService. Ctrl-C
+	// ends the loop cleanly, so deferred sinks flush what they buffered.
+	runCtx, stop := interruptContext()
+	defer stop()
 	if duration > 0 {
-		runCtx, cancel = context.WithTimeout(ctx, duration)
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(runCtx, duration)
 		defer cancel()
 	}
+	var samples sink.Sink
+	if opts.Sink != "" {
+		samples, err = sink.Open(opts.Sink)
+		if err != nil {
+			return fmt.Errorf("open sink: %w", err)
+		}
+		defer samples.Close()
+	}
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 	if output.NormaliseFormat(opts.Format) == output.FormatCSV {
@@ -552,13 +700,26 @@ func (a *App) runWatch(opts commandOptions, interval, duration time.Duration) er
 	}
 	for {
 		for _, item := range opts.ReadItems {
-			resp, err := FetchNodeValue(runCtx, opcService, opts.Locale, opts.ClientHandle, item.ItemPath, item.ItemName)
+			readCtx, cancel := withOptionalTimeout(runCtx, opts.RequestTimeout)
+			resp, err := FetchNodeValue(readCtx, opcService, opts.Locale, opts.ClientHandle, item.ItemPath, item.ItemName)
+			cancel()
 			if err != nil {
+				if runCtx.Err() != nil {
+					return nil
+				}
 				return fmt.Errorf("watch: %w", err)
 			}
 			if err := a.renderWatch(opts.Format, item, resp); err != nil {
 				return fmt.Errorf("print watch: %w", err)
 			}
+			if samples != nil {
+				if err := samples.Write(runCtx, readResponseSamples(item, resp, time.Now())); err != nil {
+					if runCtx.Err() != nil {
+						return nil
+					}
+					return fmt.Errorf("watch: %w", err)
+				}
+			}
 		}
 		select {
 		case <-runCtx.Done():
@@ -569,8 +730,8 @@ func (a *App) runWatch(opts commandOptions, interval, duration time.Duration) er
 }
 
 type itemRef struct {
-	ItemPath string
-	ItemName string
+	ItemPath string `json:"item_path,omitempty"`
+	ItemName string `json:"item_name,omitempty"`
 }
 
 type stringList []string
@@ -602,7 +763,15 @@ func readItemRefs(itemNames, itemPaths stringList, itemsFile string) ([]itemRef,
 	return append(items, fromFile...), nil
 }
 
+// readItemsFile reads an items file. A .yaml or .yml file holds a list of
+// items, optionally under an items key, each an item name or a mapping with
+// item_path and item_name. Any other file has one item name per line, with
+// blank lines and # comments ignored.
 func readItemsFile(path string) ([]itemRef, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return readItemsYAML(path)
+	}
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("read items file %q: %w", path, err)
@@ -623,23 +792,149 @@ func readItemsFile(path string) ([]itemRef, error) {
 	return items, nil
 }
 
+// yamlItem is one entry of a YAML items file.
+type yamlItem struct {
+	ItemPath string `yaml:"item_path"`
+	ItemName string `yaml:"item_name"`
+}
+
+func (i *yamlItem) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		i.ItemName = value.Value
+		return nil
+	}
+	type plain yamlItem
+	return value.Decode((*plain)(i))
+}
+
+func readItemsYAML(path string) ([]itemRef, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read items file %q: %w", path, err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("read items file %q: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	list := doc.Content[0]
+	if list.Kind == yaml.MappingNode {
+		list = nil
+		for i := 0; i+1 < len(doc.Content[0].Content); i += 2 {
+			if doc.Content[0].Content[i].Value == "items" {
+				list = doc.Content[0].Content[i+1]
+			}
+		}
+		if list == nil {
+			return nil, fmt.Errorf("read items file %q: no items list", path)
+		}
+	}
+	var entries []yamlItem
+	if err := list.Decode(&entries); err != nil {
+		return nil, fmt.Errorf("read items file %q: %w", path, err)
+	}
+	items := make([]itemRef, 0, len(entries))
+	for i, entry := range entries {
+		if entry.ItemPath == "" && entry.ItemName == "" {
+			return nil, fmt.Errorf("read items file %q: item %d has no item_name or item_path", path, i+1)
+		}
+		items = append(items, itemRef{ItemPath: entry.ItemPath, ItemName: entry.ItemName})
+	}
+	return items, nil
+}
+
+// newService returns the OPC XML-DA client for opts. With an endpoints list
+// it fails over between the endpoints, and with retry.max_attempts above
+// one it retries idempotent calls; see failoverService and retryService.
 func (a *App) newService(opts commandOptions) (context.Context, service.OpcXmlDASoap, error) {
-	if err := configureLogging(opts); err != nil {
+	ctx, clients, err := a.newSOAPClients(opts, nil)
+	if err != nil {
 		return nil, nil, err
 	}
-	if opts.Endpoint == "" {
-		return nil, nil, fmt.Errorf("endpoint is required")
+	if len(clients) == 1 {
+		return ctx, withRetry(service.NewOpcXmlDASoapWithQuirks(clients[0], opts.Quirks), opts.Retry), nil
 	}
+	return ctx, withRetry(newFailoverService(opts, clients), opts.Retry), nil
+}
 
-	var soapOpts []soap.Option
-	if opts.DumpHTTP {
-		soapOpts = append(soapOpts, soap.WithHTTPClient(NewDebugHTTPClient(opts.HTTPTimeout, opts.RequestTimeout)))
-	} else {
-		soapOpts = append(soapOpts, soap.WithTimeout(opts.HTTPTimeout), soap.WithRequestTimeout(opts.RequestTimeout))
+// newSOAPClient builds a SOAP client for the primary endpoint. A non-nil
+// capture records the raw envelopes of every exchange.
+func (a *App) newSOAPClient(opts commandOptions, capture *envelopeCapture) (context.Context, *soap.Client, error) {
+	ctx, clients, err := a.newSOAPClients(opts, capture)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ctx, clients[0], nil
+}
+
+// newSOAPClients builds one SOAP client per endpoint, primary first. The
+// clients share one HTTP client, so debugging, recording, and
+// authentication cover every endpoint.
+func (a *App) newSOAPClients(opts commandOptions, capture *envelopeCapture) (context.Context, []*soap.Client, error) {
+	ctx, endpoints, httpClient, err := a.newSOAPHTTPClient(opts, capture)
+	if err != nil {
+		return nil, nil, err
 	}
-	if opts.Username != "" {
+	soapOpts := []soap.Option{soap.WithHTTPClient(httpClient)}
+	if usesBasicAuth(opts) {
 		soapOpts = append(soapOpts, soap.WithBasicAuth(opts.Username, opts.Password))
 	}
+	clients := make([]*soap.Client, len(endpoints))
+	for i, endpoint := range endpoints {
+		clients[i] = soap.NewClient(endpoint, soapOpts...)
+		for _, header := range soapHeaders(opts) {
+			clients[i].AddHeader(header)
+		}
+	}
+	return ctx, clients, nil
+}
+
+// newSOAPHTTPClient checks the endpoints and auth settings in opts and
+// returns the request context, the endpoints, primary first, and the HTTP
+// client that SOAP requests go through.
+func (a *App) newSOAPHTTPClient(opts commandOptions, capture *envelopeCapture) (context.Context, []string, *http.Client, error) {
+	if err := configureLogging(opts); err != nil {
+		return nil, nil, nil, err
+	}
+	if opts.Record != "" && opts.Replay != "" {
+		return nil, nil, nil, errRecordReplay
+	}
+	var replay *replayRoundTripper
+	if opts.Replay != "" {
+		var err error
+		if replay, err = loadReplayRoundTripper(opts.Replay); err != nil {
+			return nil, nil, nil, err
+		}
+		if opts.Endpoint == "" {
+			opts.Endpoint = replay.first
+		}
+		slog.Info("replaying recorded session", "path", opts.Replay)
+	}
+	if opts.Endpoint == "" {
+		return nil, nil, nil, fmt.Errorf("endpoint is required")
+	}
+	endpoints := []string{opts.Endpoint}
+	if len(opts.Endpoints) > 1 && replay == nil {
+		endpoints = opts.Endpoints
+	}
+	for _, endpoint := range endpoints {
+		if err := config.ValidateEndpoint(endpoint); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	authScheme, err := httpauth.ParseScheme(opts.Auth)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if (authScheme == httpauth.SchemeWSSE || authScheme == httpauth.SchemeWSSEDigest) && opts.Username == "" {
+		return nil, nil, nil, fmt.Errorf("auth %s requires --username", authScheme)
+	}
+	httpClient, err := newHTTPClient(opts, replay, capture)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 
 	ctx := context.Background()
 	if opts.RequestTimeout > 0 {
@@ -648,10 +943,29 @@ func (a *App) newService(opts commandOptions) (context.Context, service.OpcXmlDA
 		_ = cancel
 	}
 
-	slog.Info("opc xml-da cli start", "endpoint", opts.Endpoint)
+	if len(endpoints) > 1 {
+		slog.Info("opc xml-da cli start", "endpoint", opts.Endpoint, "backups", endpoints[1:])
+	} else {
+		slog.Info("opc xml-da cli start", "endpoint", opts.Endpoint)
+	}
 	slog.Debug("soap timeouts configured", "http_timeout", opts.HTTPTimeout, "request_timeout", opts.RequestTimeout)
-	client := soap.NewClient(opts.Endpoint, soapOpts...)
-	return ctx, service.NewOpcXmlDASoap(client), nil
+	return ctx, endpoints, httpClient, nil
+}
+
+// usesBasicAuth reports whether requests carry HTTP Basic credentials.
+func usesBasicAuth(opts commandOptions) bool {
+	scheme, _ := httpauth.ParseScheme(opts.Auth)
+	return scheme == httpauth.SchemeBasic && opts.Username != ""
+}
+
+// soapHeaders returns the SOAP headers added to every request envelope: a
+// WS-Security UsernameToken for the wsse auth schemes.
+func soapHeaders(opts commandOptions) []interface{} {
+	switch scheme, _ := httpauth.ParseScheme(opts.Auth); scheme {
+	case httpauth.SchemeWSSE, httpauth.SchemeWSSEDigest:
+		return []interface{}{&service.UsernameToken{Username: opts.Username, Password: opts.Password, Digest: scheme == httpauth.SchemeWSSEDigest}}
+	}
+	return nil
 }
 
 func (a *App) newFlagSet(name string) *flag.FlagSet {
@@ -679,27 +993,71 @@ func addCommonFlagsBase(fs *flag.FlagSet, opts *commandOptions, formatHelp strin
 	fs.BoolVar(&opts.Debug, "debug", opts.Debug, "enable lower-level client debug logging")
 	fs.BoolVar(&opts.DumpHTTP, "dump-http", opts.DumpHTTP, "dump HTTP request/response details to stderr")
 	fs.BoolVar(&opts.DumpHTTP, "net-debug", opts.DumpHTTP, "deprecated alias for --dump-http")
+	fs.StringVar(&opts.DumpHTTPFile, "dump-http-file", opts.DumpHTTPFile, "write HTTP exchanges with timings to a HAR file")
+	fs.Int64Var(&opts.DumpHTTPMax, "dump-http-max-body", opts.DumpHTTPMax, "maximum bytes of each body kept in the HAR file (0 for no limit)")
+	fs.StringVar(&opts.Record, "record", opts.Record, "record SOAP requests and responses to a JSON Lines session file")
+	fs.StringVar(&opts.Replay, "replay", opts.Replay, "answer requests from a recorded session file instead of the network")
 	fs.StringVar(&opts.LogLevel, "log-level", opts.LogLevel, "deprecated log level override: debug, info, warn, error")
 	fs.StringVar(&opts.Locale, "locale", opts.Locale, "locale ID")
 	fs.StringVar(&opts.ClientHandle, "client-handle", opts.ClientHandle, "client request handle")
 	fs.DurationVar(&opts.HTTPTimeout, "http-timeout", opts.HTTPTimeout, "HTTP dial timeout")
 	fs.DurationVar(&opts.RequestTimeout, "timeout", opts.RequestTimeout, "end-to-end request timeout")
 	fs.DurationVar(&opts.RequestTimeout, "request-timeout", opts.RequestTimeout, "deprecated alias for --timeout")
-	fs.StringVar(&opts.Username, "username", opts.Username, "Basic auth username")
-	fs.StringVar(&opts.Password, "password", opts.Password, "Basic auth password")
+	fs.IntVar(&opts.Retry.MaxAttempts, "retry-attempts", opts.Retry.MaxAttempts, "tries per read, browse, or status request on transient failures (1 disables retries)")
+	fs.StringVar(&opts.Username, "username", opts.Username, "HTTP auth username; DOMAIN\\user or user@domain for NTLM")
+	fs.StringVar(&opts.Password, "password", opts.Password, "auth password; prefer --password-stdin or a secret reference in the config")
+	fs.BoolVar(&opts.PasswordStdin, "password-stdin", opts.PasswordStdin, "read the auth password from the first line of stdin")
+	fs.StringVar(&opts.Auth, "auth", opts.Auth, "auth scheme: basic, digest, ntlm, or WS-Security wsse or wsse-digest")
+	fs.StringVar(&opts.TLS.CAFile, "tls-ca-file", opts.TLS.CAFile, "PEM bundle of CAs trusted for the endpoint instead of the system roots")
+	fs.StringVar(&opts.TLS.CertFile, "tls-cert-file", opts.TLS.CertFile, "PEM client certificate")
+	fs.StringVar(&opts.TLS.KeyFile, "tls-key-file", opts.TLS.KeyFile, "PEM client private key")
+	fs.StringVar(&opts.TLS.ServerName, "tls-server-name", opts.TLS.ServerName, "server name to verify instead of the endpoint host")
+	fs.StringVar(&opts.TLS.MinVersion, "tls-min-version", opts.TLS.MinVersion, "minimum TLS version: 1.0, 1.1, 1.2, or 1.3")
+	fs.BoolVar(&opts.TLS.InsecureSkipVerify, "tls-insecure-skip-verify", opts.TLS.InsecureSkipVerify, "do not verify the server certificate chain (lab use only)")
+	fs.Var((*stringList)(&opts.TLS.PinSHA256), "tls-pin", "accept only servers presenting this SHA-256 public key pin; repeatable")
 }
 
 func (opts *commandOptions) applyConfig(fs *flag.FlagSet) error {
 	visited := visitedFlags(fs)
-	if !shouldLoadConfig(opts.ConfigPath, visited) {
-		return nil
-	}
-	fileCfg, err := config.LoadClientConfigForProfile(opts.ConfigPath, opts.Profile)
+	if opts.PasswordStdin {
+		if visited["password"] {
+			return errors.New("--password and --password-stdin cannot be used together")
+		}
+		password, err := readPasswordLine(os.Stdin)
+		if err != nil {
+			return err
+		}
+		opts.Password = password
+		visited["password"] = true
+	}
+	explicit := applyConfigEnv(opts, visited)
+	effective, err := config.Load(config.LoadOptions{
+		Path:           opts.ConfigPath,
+		Profile:        opts.Profile,
+		OptionalFile:   !explicit,
+		LookupEnv:      os.LookupEnv,
+		ResolveSecrets: true,
+	})
 	if err != nil {
 		return err
 	}
+	opts.Profile = effective.Profile
+	fileCfg := effective.ClientConfig
 	if !visited["endpoint"] {
 		opts.Endpoint = fileCfg.Endpoint
+		opts.Endpoints = fileCfg.Endpoints
+		if opts.Endpoint == "" && len(opts.Endpoints) > 0 {
+			opts.Endpoint = opts.Endpoints[0]
+		}
+	}
+	opts.Failover = fileCfg.Failover
+	attempts := opts.Retry.MaxAttempts
+	opts.Retry = fileCfg.Retry
+	if visited["retry-attempts"] {
+		opts.Retry.MaxAttempts = attempts
+	}
+	if err := opts.Retry.Validate(); err != nil {
+		return err
 	}
 	if !visited["username"] {
 		opts.Username = fileCfg.Username
@@ -707,6 +1065,9 @@ func (opts *commandOptions) applyConfig(fs *flag.FlagSet) error {
 	if !visited["password"] {
 		opts.Password = fileCfg.Password
 	}
+	if !visited["auth"] {
+		opts.Auth = fileCfg.Auth
+	}
 	if !visited["locale"] {
 		opts.Locale = fileCfg.Locale
 	}
@@ -719,9 +1080,48 @@ func (opts *commandOptions) applyConfig(fs *flag.FlagSet) error {
 	if !visited["timeout"] && !visited["request-timeout"] {
 		opts.RequestTimeout = fileCfg.RequestTimeout
 	}
+	opts.TLS = applyTLSConfig(opts.TLS, fileCfg.TLS, visited)
+	opts.HTTP = fileCfg.HTTP
+	if err := opts.HTTP.Validate(); err != nil {
+		return err
+	}
+	if err := opts.HTTP.ValidateAuth(opts.Auth); err != nil {
+		return err
+	}
+	quirks, err := fileCfg.Quirks.Quirks()
+	if err != nil {
+		return err
+	}
+	opts.Quirks = quirks
 	return nil
 }
 
+// applyTLSConfig fills TLS settings not given as flags from the config file.
+func applyTLSConfig(flags, file config.TLSConfig, visited map[string]bool) config.TLSConfig {
+	if !visited["tls-ca-file"] {
+		flags.CAFile = file.CAFile
+	}
+	if !visited["tls-cert-file"] {
+		flags.CertFile = file.CertFile
+	}
+	if !visited["tls-key-file"] {
+		flags.KeyFile = file.KeyFile
+	}
+	if !visited["tls-server-name"] {
+		flags.ServerName = file.ServerName
+	}
+	if !visited["tls-min-version"] {
+		flags.MinVersion = file.MinVersion
+	}
+	if !visited["tls-insecure-skip-verify"] {
+		flags.InsecureSkipVerify = file.InsecureSkipVerify
+	}
+	if !visited["tls-pin"] {
+		flags.PinSHA256 = file.PinSHA256
+	}
+	return flags
+}
+
 func readHeaders() []string {
 	return []string{"ItemPath", "ItemName", "Value", "Quality", "Timestamp", "DiagnosticInfo"}
 }
@@ -747,6 +1147,38 @@ func readResponseRows(resp *service.ReadResponse) [][]string {
 	return rows
 }
 
+// readResponseSamples converts a read response into sink samples, filling in
+// the requested item identifiers when the server omits them.
+func readResponseSamples(item itemRef, resp *service.ReadResponse, received time.Time) []sink.Sample {
+	if resp == nil || resp.RItemList == nil {
+		return nil
+	}
+	state := ""
+	if resp.ReadResult != nil && resp.ReadResult.ServerState != nil {
+		state = string(*resp.ReadResult.ServerState)
+	}
+	samples := make([]sink.Sample, 0, len(resp.RItemList.Items))
+	for _, value := range resp.RItemList.Items {
+		if value == nil {
+			continue
+		}
+		sample := sink.Sample{
+			ItemPath:    firstNonEmpty(value.ItemPath, item.ItemPath),
+			ItemName:    firstNonEmpty(value.ItemName, item.ItemName),
+			Value:       formatXMLDAValue(value.Value),
+			Quality:     qualityName(value.Quality),
+			Timestamp:   value.Timestamp.ToGoTime(),
+			Received:    received,
+			ServerState: state,
+		}
+		if value.ResultID != nil {
+			sample.ResultID = string(*value.ResultID)
+		}
+		samples = append(samples, sample)
+	}
+	return samples
+}
+
 func (a *App) renderStatus(format string, resp *service.GetStatusResponse) error {
 	rows := [][]string{}
 	if resp != nil && resp.GetStatusResult != nil && resp.GetStatusResult.ServerState != nil {
@@ -808,8 +1240,10 @@ func (a *App) renderRead(format string, resp *service.ReadResponse) error {
 		return output.WriteTable(a.out, readHeaders(), rows)
 	case output.FormatCSV:
 		return output.WriteCSVRows(a.out, rows)
+	case output.FormatInflux:
+		return writeInfluxLines(a.out, readResponseSamples(itemRef{}, resp, time.Now()))
 	default:
-		return invalidSnapshotFormat(format)
+		return invalidReadFormat(format)
 	}
 }
 
@@ -825,11 +1259,22 @@ func (a *App) renderWatch(format string, item itemRef, resp *service.ReadRespons
 		})
 	case output.FormatCSV:
 		return output.WriteCSVRows(a.out, readResponseRows(resp))
+	case output.FormatInflux:
+		return writeInfluxLines(a.out, readResponseSamples(item, resp, time.Now()))
 	default:
 		return invalidWatchFormat(format)
 	}
 }
 
+func writeInfluxLines(w io.Writer, samples []sink.Sample) error {
+	for _, sample := range samples {
+		if _, err := fmt.Fprintln(w, sink.InfluxLine(sink.DefaultInfluxMeasurement, sample)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func validateSnapshotFormat(format string) error {
 	switch output.NormaliseFormat(format) {
 	case output.FormatText, output.FormatTable, output.FormatJSON, output.FormatCSV:
@@ -845,7 +1290,7 @@ func invalidSnapshotFormat(format string) error {
 
 func validateWatchFormat(format string) error {
 	switch output.NormaliseFormat(format) {
-	case output.FormatText, output.FormatJSONL, output.FormatCSV:
+	case output.FormatText, output.FormatJSONL, output.FormatCSV, output.FormatInflux:
 		return nil
 	default:
 		return invalidWatchFormat(format)
@@ -853,21 +1298,63 @@ func validateWatchFormat(format string) error {
 }
 
 func invalidWatchFormat(format string) error {
-	return fmt.Errorf("invalid output format %q; expected text, jsonl, or csv", format)
+	return fmt.Errorf("invalid output format %q; expected text, jsonl, csv, or influx", format)
 }
 
 func validateReadFormat(format string) error {
-	return validateSnapshotFormat(format)
+	if output.NormaliseFormat(format) == output.FormatInflux {
+		return nil
+	}
+	if err := validateSnapshotFormat(format); err != nil {
+		return invalidReadFormat(format)
+	}
+	return nil
 }
 
-func shouldLoadConfig(path string, visited map[string]bool) bool {
-	if visited["config"] || visited["profile"] {
-		return true
+func invalidReadFormat(format string) error {
+	return fmt.Errorf("invalid output format %q; expected table, text, json, csv, or influx", format)
+}
+
+// readPasswordLine reads a password for --password-stdin, dropping the line
+// ending. It reads one byte at a time so the rest of stdin, such as a
+// call --body -, is left for the command.
+func readPasswordLine(r io.Reader) (string, error) {
+	var line []byte
+	read := false
+	b := make([]byte, 1)
+	for {
+		n, err := r.Read(b)
+		if n > 0 {
+			read = true
+			if b[0] == '\n' {
+				break
+			}
+			line = append(line, b[0])
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("--password-stdin: %w", err)
+		}
 	}
-	if _, err := os.Stat(path); err == nil {
-		return true
+	if !read {
+		return "", errors.New("--password-stdin: no password on stdin")
 	}
-	return false
+	return strings.TrimRight(string(line), "\r"), nil
+}
+
+// applyConfigEnv takes the config path from OPCXMLDA_CONFIG when --config is
+// not given. It reports whether a config file was asked for explicitly, in
+// which case it must exist.
+func applyConfigEnv(opts *commandOptions, visited map[string]bool) bool {
+	if !visited["config"] {
+		if path, ok := os.LookupEnv(config.EnvConfig); ok && path != "" {
+			opts.ConfigPath = path
+			return true
+		}
+	}
+	return visited["config"] || visited["profile"]
 }
 
 func visitedFlags(fs *flag.FlagSet) map[string]bool {
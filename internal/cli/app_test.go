@@ -5,8 +5,11 @@ import (
 	"encoding/json"
 	"encoding/xml"
 	"errors"
+	"flag"
+	"io"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -258,6 +261,60 @@ func TestValidateConfigPasses(t *testing.T) {
 	}
 }
 
+func TestValidateConfigWarnsAboutPlaintextPasswords(t *testing.T) {
+	t.Setenv("OPC_TEST_PASS", "secret")
+	var out, err bytes.Buffer
+	path := writeCLIConfig(t, `
+endpoint: http://localhost/opc
+password: env:OPC_TEST_PASS
+profiles:
+  site-a:
+    password: secret
+`)
+	code := NewApp(&out, &err).Run([]string{"validate-config", "--config", path})
+	if code != exitSuccess {
+		t.Fatalf("Run(validate-config) = %d, want %d; stderr=%q", code, exitSuccess, err.String())
+	}
+	if !strings.Contains(out.String(), "WARNING: profiles.site-a.password is stored in plaintext") || strings.Contains(out.String(), "WARNING: password ") {
+		t.Fatalf("stdout = %q", out.String())
+	}
+}
+
+func TestPasswordStdin(t *testing.T) {
+	r, w, pipeErr := os.Pipe()
+	if pipeErr != nil {
+		t.Fatal(pipeErr)
+	}
+	stdin := os.Stdin
+	os.Stdin = r
+	t.Cleanup(func() { os.Stdin = stdin })
+	_, _ = w.WriteString("from-stdin\r\n<GetStatus/>")
+	_ = w.Close()
+
+	opts := defaultCommandOptions()
+	opts.ConfigPath = filepath.Join(t.TempDir(), "missing.yaml")
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	addCommonFlags(fs, &opts, "")
+	if err := fs.Parse([]string{"--password-stdin"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := opts.applyConfig(fs); err != nil {
+		t.Fatal(err)
+	}
+	if opts.Password != "from-stdin" {
+		t.Fatalf("Password = %q", opts.Password)
+	}
+	if rest, err := io.ReadAll(os.Stdin); err != nil || string(rest) != "<GetStatus/>" {
+		t.Fatalf("stdin after the password = %q, %v; want the rest left unread", rest, err)
+	}
+
+	var errOut bytes.Buffer
+	code := NewApp(&bytes.Buffer{}, &errOut).Run([]string{"status", "--endpoint", "http://localhost:1", "--password", "x", "--password-stdin"})
+	if code != exitConfigError || !strings.Contains(errOut.String(), "cannot be used together") {
+		t.Fatalf("Run(status --password --password-stdin) = %d, stderr %q", code, errOut.String())
+	}
+}
+
 func TestValidateConfigAcceptsGlobalConfigFlag(t *testing.T) {
 	var out, err bytes.Buffer
 	path := writeCLIConfig(t, `endpoint: http://localhost/opc`)
@@ -280,6 +337,19 @@ func TestValidateConfigFailsForInvalidConfig(t *testing.T) {
 	if !strings.Contains(err.String(), "endpoint is required") {
 		t.Fatalf("stderr missing validation error: %q", err.String())
 	}
+
+	err.Reset()
+	path = writeCLIConfig(t, "endpoint: http://localhost/opc\nrequst_timeout: 5s\n")
+	code = NewApp(&out, &err).Run([]string{"validate-config", "--config", path})
+	if code != exitConfigError || !strings.Contains(err.String(), `line 2: unknown key "requst_timeout"`) {
+		t.Fatalf("Run(validate-config typo) = %d, stderr %q", code, err.String())
+	}
+
+	err.Reset()
+	code = NewApp(&out, &err).Run([]string{"status", "--endpoint", "localhost:8080/opc"})
+	if code != exitConfigError || !strings.Contains(err.String(), "must use http or https") {
+		t.Fatalf("Run(status bad endpoint) = %d, stderr %q", code, err.String())
+	}
 }
 
 func TestCommandOptionsApplyConfig(t *testing.T) {
@@ -388,6 +458,70 @@ func TestCommandOptionsApplyConfigIgnoresMissingDefaultConfig(t *testing.T) {
 	}
 }
 
+func TestCommandOptionsApplyConfigEnvOverlay(t *testing.T) {
+	path := writeCLIConfig(t, `
+endpoint: http://from-config/opc
+http_timeout: 2s
+profiles:
+  site-a:
+    locale: de-DE
+`)
+	t.Setenv("OPCXMLDA_CONFIG", path)
+	t.Setenv("OPCXMLDA_PROFILE", "site-a")
+	t.Setenv("OPCXMLDA_ENDPOINT", "http://from-env/opc")
+	t.Setenv("OPCXMLDA_HTTP_TIMEOUT", "4s")
+	t.Setenv("OPCXMLDA_USERNAME", "env-user")
+	opts := defaultCommandOptions()
+	fs := NewApp(&bytes.Buffer{}, &bytes.Buffer{}).newFlagSet("status")
+	addCommonFlags(fs, &opts, "output format: table, text, or json")
+	if err := fs.Parse([]string{"--username", "flag-user"}); err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if err := opts.applyConfig(fs); err != nil {
+		t.Fatalf("applyConfig returned error: %v", err)
+	}
+	if opts.Endpoint != "http://from-env/opc" || opts.HTTPTimeout != 4*time.Second || opts.Locale != "de-DE" || opts.Username != "flag-user" {
+		t.Fatalf("options = %+v", opts)
+	}
+}
+
+func TestEnvOnlyConfiguration(t *testing.T) {
+	_, server := newFakeServer(t)
+	t.Chdir(t.TempDir())
+	t.Setenv("OPCXMLDA_ENDPOINT", server.URL)
+	var out, errOut bytes.Buffer
+	code := NewApp(&out, &errOut).Run([]string{"read", "--item-name", "Plant.Area.Temp", "--format", "csv"})
+	if code != exitSuccess || !strings.Contains(out.String(), "21.5") {
+		t.Fatalf("Run(read) = %d, stdout %q, stderr %q", code, out.String(), errOut.String())
+	}
+}
+
+func TestValidateConfigShowsSources(t *testing.T) {
+	path := writeCLIConfig(t, `
+endpoint: http://localhost/opc
+default_profile: site-a
+profiles:
+  site-a:
+    locale: de-DE
+`)
+	t.Setenv("OPCXMLDA_TIMEOUT", "5s")
+	var out, errOut bytes.Buffer
+	code := NewApp(&out, &errOut).Run([]string{"validate-config", "--config", path})
+	if code != exitSuccess || out.String() != "config validation: PASS\n" {
+		t.Fatalf("Run(validate-config) = %d, stdout %q, stderr %q; want only the result", code, out.String(), errOut.String())
+	}
+	out.Reset()
+	code = NewApp(&out, &errOut).Run([]string{"validate-config", "--config", path, "--sources"})
+	if code != exitSuccess {
+		t.Fatalf("Run(validate-config --sources) = %d, stderr %q", code, errOut.String())
+	}
+	for _, want := range []string{"file default_profile", "http://localhost/opc", "profile site-a", "30s", "default", "env OPCXMLDA_TIMEOUT"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("validate-config output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
 func TestReadItemRefs(t *testing.T) {
 	itemsPath := filepath.Join(t.TempDir(), "items.txt")
 	if err := os.WriteFile(itemsPath, []byte("# comment\nFile.Item\n\n"), 0o600); err != nil {
@@ -409,6 +543,34 @@ func TestReadItemRefs(t *testing.T) {
 	}
 }
 
+func TestReadItemsFileYAML(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"list.yaml": "- Plant.Area.Temp\n- item_path: Plant\n  item_name: Area.Running\n",
+		"items.yml": "items:\n  - Plant.Area.Temp\n  - {item_path: Plant, item_name: Area.Running}\n",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatalf("write items file: %v", err)
+		}
+		items, err := readItemsFile(path)
+		if err != nil {
+			t.Fatalf("readItemsFile(%s) returned error: %v", name, err)
+		}
+		want := []itemRef{{ItemName: "Plant.Area.Temp"}, {ItemPath: "Plant", ItemName: "Area.Running"}}
+		if !reflect.DeepEqual(items, want) {
+			t.Fatalf("readItemsFile(%s) = %+v, want %+v", name, items, want)
+		}
+	}
+	path := filepath.Join(dir, "empty.yaml")
+	if err := os.WriteFile(path, []byte("- item_path: Plant\n- {}\n"), 0o600); err != nil {
+		t.Fatalf("write items file: %v", err)
+	}
+	if _, err := readItemsFile(path); err == nil || !strings.Contains(err.Error(), "item 2") {
+		t.Fatalf("readItemsFile(empty entry) error = %v", err)
+	}
+}
+
 func TestRenderStatusJSON(t *testing.T) {
 	var out, errOut bytes.Buffer
 	app := NewApp(&out, &errOut)
@@ -500,3 +662,85 @@ func writeCLIConfig(t *testing.T, body string) string {
 	}
 	return path
 }
+
+func TestReadResponseSamplesFillsRequestedItem(t *testing.T) {
+	state := service.ServerStateRunning
+	good := service.QualityBitsGood
+	resp := &service.ReadResponse{
+		ReadResult: &service.ReplyBase{ServerState: &state},
+		RItemList: &service.ReplyItemList{
+			Items: []*service.ItemValue{{Value: service.AnyType{InnerXML: "7"}, Quality: &service.OPCQuality{QualityField: &good}}},
+		},
+	}
+	received := time.Now()
+	samples := readResponseSamples(itemRef{ItemName: "A"}, resp, received)
+	if len(samples) != 1 {
+		t.Fatalf("len(samples) = %d, want 1", len(samples))
+	}
+	got := samples[0]
+	if got.ItemName != "A" || got.Value != "7" || got.Quality != "good" || got.ServerState != "running" || !got.Received.Equal(received) {
+		t.Fatalf("sample = %+v", got)
+	}
+}
+
+func TestRenderReadInflux(t *testing.T) {
+	var out, errOut bytes.Buffer
+	app := NewApp(&out, &errOut)
+	resp := &service.ReadResponse{
+		RItemList: &service.ReplyItemList{
+			Items: []*service.ItemValue{{ItemName: "A", Value: service.AnyType{InnerXML: "3.5"}}},
+		},
+	}
+	if err := validateReadFormat("influx"); err != nil {
+		t.Fatalf("validateReadFormat(influx) returned error: %v", err)
+	}
+	if err := app.renderRead("influx", resp); err != nil {
+		t.Fatalf("renderRead returned error: %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "opcxmlda,item_name=A value=3.5 ") {
+		t.Fatalf("influx output = %q", out.String())
+	}
+}
+
+func TestStatusSinkRequiresWatch(t *testing.T) {
+	var out, err bytes.Buffer
+	code := NewApp(&out, &err).Run([]string{"status", "--endpoint", "http://127.0.0.1:1", "--sink", "webhook://example.test/hook"})
+	if code != exitConfigError {
+		t.Fatalf("Run(status --sink) = %d, want %d", code, exitConfigError)
+	}
+}
+
+func TestWriteStatusEvent(t *testing.T) {
+	received := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	state := service.ServerStateRunning
+	resp := &service.GetStatusResponse{
+		GetStatusResult: &service.ReplyBase{ServerState: &state},
+		Status:          &service.ServerStatus{ProductVersion: "1.2"},
+	}
+	var out bytes.Buffer
+	if err := writeStatusEvent(&out, "text", statusEvent(resp, nil, received)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeStatusEvent(&out, "text", statusEvent(nil, errors.New("connection refused"), received)); err != nil {
+		t.Fatal(err)
+	}
+	want := "2026-03-01T12:00:00Z running\n2026-03-01T12:00:00Z error: connection refused\n"
+	if out.String() != want {
+		t.Fatalf("output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestSimulateRejectsInvalidModel(t *testing.T) {
+	var out, errOut bytes.Buffer
+	path := filepath.Join(t.TempDir(), "model.yaml")
+	if err := os.WriteFile(path, []byte("tags:\n  - name: X\n    generator: {kind: square}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	code := NewApp(&out, &errOut).Run([]string{"simulate", "--model", path})
+	if code != exitConfigError {
+		t.Fatalf("Run(simulate invalid model) = %d, want %d", code, exitConfigError)
+	}
+	if !strings.Contains(errOut.String(), "unknown generator kind") {
+		t.Fatalf("stderr = %q", errOut.String())
+	}
+}
@@ -0,0 +1,168 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"opc-xml-da-cli/service"
+	"opc-xml-da-cli/service/servicetest"
+)
+
+// newDigestFakeServer puts the fake OPC server behind MD5 Digest auth with
+// qop=auth, the way IIS does when Digest is enabled.
+func newDigestFakeServer(t *testing.T, username, password string) *httptest.Server {
+	t.Helper()
+	const realm, nonce = "opc", "5f0c1e7a9b"
+	md5hex := func(parts ...string) string {
+		sum := md5.Sum([]byte(strings.Join(parts, ":")))
+		return hex.EncodeToString(sum[:])
+	}
+	fake := servicetest.New()
+	fake.SetValue("Plant.Area.Temp", 21.5)
+	handler := service.NewHandler(fake)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := map[string]string{}
+		if rest, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Digest "); ok {
+			for _, part := range strings.Split(rest, ",") {
+				name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+				params[name] = strings.Trim(value, `"`)
+			}
+		}
+		want := md5hex(md5hex(username, realm, password), nonce, params["nc"], params["cnonce"], "auth", md5hex(r.Method, params["uri"]))
+		if params["username"] != username || params["response"] != want {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest realm=%q, nonce=%q, qop="auth"`, realm, nonce))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDigestAuthWithDumpHTTPFile(t *testing.T) {
+	server := newDigestFakeServer(t, "operator", "s3cret")
+	path := filepath.Join(t.TempDir(), "trace.har")
+	var out, errOut bytes.Buffer
+	code := NewApp(&out, &errOut).Run([]string{"read", "--endpoint", server.URL, "--item-name", "Plant.Area.Temp", "--format", "json",
+		"--auth", "digest", "--username", "operator", "--password", "s3cret", "--dump-http-file", path})
+	if code != exitSuccess {
+		t.Fatalf("Run(read --auth digest) = %d, stderr %q", code, errOut.String())
+	}
+	if !strings.Contains(out.String(), "21.5") {
+		t.Fatalf("read output = %q", out.String())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var archive harLog
+	if err := json.Unmarshal(data, &archive); err != nil {
+		t.Fatal(err)
+	}
+	if len(archive.Log.Entries) != 2 || archive.Log.Entries[0].Response.Status != 401 || archive.Log.Entries[1].Response.Status != 200 {
+		t.Fatalf("HAR entries = %+v, want the 401 challenge then 200", archive.Log.Entries)
+	}
+	if strings.Contains(string(data), "response=") {
+		t.Fatalf("HAR file contains the Digest response:\n%s", data)
+	}
+
+	code = NewApp(&bytes.Buffer{}, &bytes.Buffer{}).Run([]string{"read", "--endpoint", server.URL, "--item-name", "Plant.Area.Temp",
+		"--username", "operator", "--password", "s3cret"})
+	if code == exitSuccess {
+		t.Fatal("Basic auth was accepted by a Digest-only server")
+	}
+}
+
+func TestAuthSchemeIsValidated(t *testing.T) {
+	var errOut bytes.Buffer
+	code := NewApp(&bytes.Buffer{}, &errOut).Run([]string{"status", "--endpoint", "http://localhost:1", "--auth", "kerberos"})
+	if code != exitConfigError || !strings.Contains(errOut.String(), "basic, digest, ntlm") {
+		t.Fatalf("Run(status --auth kerberos) = %d, stderr %q", code, errOut.String())
+	}
+}
+
+var usernameTokenPattern = regexp.MustCompile(`(?s)<wsse:Username>([^<]*)</wsse:Username>\s*<wsse:Password Type="[^"]*#PasswordDigest">([^<]*)</wsse:Password>\s*<wsse:Nonce[^>]*>([^<]*)</wsse:Nonce>\s*<wsu:Created>([^<]*)</wsu:Created>`)
+
+// newWSSEFakeServer stands in for a gateway that answers only requests
+// carrying a valid UsernameToken with a password digest.
+func newWSSEFakeServer(t *testing.T, username, password string) *httptest.Server {
+	t.Helper()
+	fake := servicetest.New()
+	fake.SetValue("Plant.Area.Temp", 21.5)
+	handler := service.NewHandler(fake)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		match := usernameTokenPattern.FindStringSubmatch(string(body))
+		if match == nil || match[1] != username {
+			http.Error(w, "missing UsernameToken", http.StatusForbidden)
+			return
+		}
+		nonce, err := base64.StdEncoding.DecodeString(match[3])
+		if err != nil || service.PasswordDigest(nonce, match[4], password) != match[2] {
+			http.Error(w, "bad password digest", http.StatusForbidden)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestWSSecurityUsernameTokenIsRedactedAndReplays(t *testing.T) {
+	server := newWSSEFakeServer(t, "operator", "s3cret")
+	dir := t.TempDir()
+	harPath, session := filepath.Join(dir, "trace.har"), filepath.Join(dir, "session.jsonl")
+	args := []string{"read", "--item-name", "Plant.Area.Temp", "--format", "csv", "--auth", "wsse-digest", "--username", "operator", "--password", "s3cret"}
+	var recorded, errOut bytes.Buffer
+	code := NewApp(&recorded, &errOut).Run(append(args, "--endpoint", server.URL, "--dump-http-file", harPath, "--record", session))
+	if code != exitSuccess {
+		t.Fatalf("Run(read --auth wsse-digest) = %d, stderr %q", code, errOut.String())
+	}
+	for _, path := range []string{harPath, session} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), "REDACTED") || usernameTokenPattern.Match(data) {
+			t.Fatalf("%s does not redact the UsernameToken password:\n%s", filepath.Base(path), data)
+		}
+	}
+
+	// A fresh nonce is sent on replay, which must not affect matching.
+	var replayed bytes.Buffer
+	errOut.Reset()
+	code = NewApp(&replayed, &errOut).Run(append(args, "--replay", session))
+	if code != exitSuccess || replayed.String() != recorded.String() {
+		t.Fatalf("Run(read --replay) = %d, output %q, stderr %q", code, replayed.String(), errOut.String())
+	}
+
+	code = NewApp(&bytes.Buffer{}, &bytes.Buffer{}).Run([]string{"read", "--endpoint", server.URL, "--item-name", "Plant.Area.Temp",
+		"--auth", "wsse", "--username", "operator", "--password", "s3cret"})
+	if code == exitSuccess {
+		t.Fatal("PasswordText token was accepted by a digest-only gateway")
+	}
+}
+
+func TestRedactWSSecurity(t *testing.T) {
+	body := `<soap:Header><wsse:Security><wsse:UsernameToken><wsse:Username>operator</wsse:Username>` +
+		`<wsse:Password Type="...#PasswordText">s3cret</wsse:Password></wsse:UsernameToken></wsse:Security></soap:Header>`
+	got := string(redactWSSecurity([]byte(body)))
+	if strings.Contains(got, "s3cret") || !strings.Contains(got, `<wsse:Password Type="...#PasswordText">REDACTED</wsse:Password>`) {
+		t.Fatalf("redactWSSecurity = %s", got)
+	}
+}
@@ -106,6 +106,10 @@ func fetchBrowseElements(ctx context.Context, svc service.OpcXmlDASoap, locale,
 		if !resp.MoreElements || resp.ContinuationPoint == "" {
 			break
 		}
+		if resp.ContinuationPoint == continuation {
+			slog.Warn("server returned the same continuation point again, stopping browse", "item_path", itemPath, "item_name", itemName)
+			break
+		}
 		continuation = resp.ContinuationPoint
 	}
 
@@ -0,0 +1,306 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hooklift/gowsdl/soap"
+
+	"opc-xml-da-cli/internal/output"
+	"opc-xml-da-cli/service"
+)
+
+// envelopeCapture keeps the raw envelopes of the last exchange.
+type envelopeCapture struct {
+	base http.RoundTripper
+
+	mu       sync.Mutex
+	request  []byte
+	response []byte
+}
+
+func (c *envelopeCapture) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := drainRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.request, c.response = body, nil
+	c.mu.Unlock()
+	resp, err := c.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	c.mu.Lock()
+	c.response = respBody
+	c.mu.Unlock()
+	return resp, nil
+}
+
+func (c *envelopeCapture) envelopes() ([]byte, []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.request, c.response
+}
+
+func (a *App) call(args []string) error {
+	opts := defaultCommandOptions()
+	opts.Format = string(output.FormatJSON)
+	operation := ""
+	bodyPath := ""
+	raw := false
+	fs := a.newFlagSet("call")
+	addCommonFlags(fs, &opts, "output format: json or text")
+	fs.StringVar(&operation, "operation", "", "operation to call: "+strings.Join(service.Operations, ", "))
+	fs.StringVar(&bodyPath, "body", "", "request body file (XML envelope, XML operation element, or JSON request struct); - reads stdin")
+	fs.BoolVar(&raw, "raw", false, "print the raw request and response envelopes to stderr")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := validateCallFormat(opts.Format); err != nil {
+		return err
+	}
+	if err := opts.applyConfig(fs); err != nil {
+		return err
+	}
+	operation, req, xmlBody, err := loadCallRequest(operation, bodyPath)
+	if err != nil {
+		return err
+	}
+	resp, err := service.NewResponse(operation)
+	if err != nil {
+		return err
+	}
+
+	var capture *envelopeCapture
+	if raw {
+		capture = &envelopeCapture{}
+	}
+	var callErr error
+	if xmlBody != nil {
+		ctx, endpoints, httpClient, err := a.newSOAPHTTPClient(opts, capture)
+		if err != nil {
+			return err
+		}
+		callErr = postEnvelope(ctx, httpClient, endpoints[0], opts, operation, xmlBody, resp)
+	} else {
+		ctx, client, err := a.newSOAPClient(opts, capture)
+		if err != nil {
+			return err
+		}
+		callErr = opts.Quirks.Call(ctx, client, operation, req, resp)
+	}
+	if capture != nil {
+		request, response := capture.envelopes()
+		fmt.Fprintf(a.err, "> request\n%s\n< response\n%s\n", bytes.TrimSpace(request), bytes.TrimSpace(response))
+	}
+	if callErr != nil {
+		return describeCallError(callErr)
+	}
+	return a.renderCall(opts.Format, resp)
+}
+
+func validateCallFormat(format string) error {
+	switch output.NormaliseFormat(format) {
+	case output.FormatJSON, output.FormatText:
+		return nil
+	default:
+		return fmt.Errorf("invalid output format %q; expected json or text", format)
+	}
+}
+
+// loadCallRequest reads the request named by --operation from bodyPath.
+// JSON bodies use the generated request struct fields and are returned
+// decoded. XML bodies, whole envelopes or the bare operation element, name
+// the operation themselves and are returned as written, to be sent verbatim.
+func loadCallRequest(operation, bodyPath string) (string, interface{}, []byte, error) {
+	var data []byte
+	var err error
+	switch bodyPath {
+	case "":
+		if operation == "" {
+			return "", nil, nil, errors.New("--operation or --body is required")
+		}
+		data = []byte("{}")
+	case "-":
+		data, err = io.ReadAll(os.Stdin)
+	default:
+		data, err = os.ReadFile(bodyPath)
+	}
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("read --body: %w", err)
+	}
+	trimmed := bytes.TrimSpace(data)
+
+	if strings.EqualFold(filepath.Ext(bodyPath), ".json") || bytes.HasPrefix(trimmed, []byte("{")) {
+		if operation == "" {
+			return "", nil, nil, errors.New("--operation is required with a JSON --body")
+		}
+		req, err := service.NewRequest(operation)
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("--operation: %w", err)
+		}
+		decoder := json.NewDecoder(bytes.NewReader(trimmed))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(req); err != nil {
+			return "", nil, nil, fmt.Errorf("decode --body: %w", err)
+		}
+		return operation, req, nil, nil
+	}
+
+	named, _, err := service.RequestOperation(trimmed)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("decode --body: %w", err)
+	}
+	if operation != "" && operation != named {
+		return "", nil, nil, fmt.Errorf("--operation %s does not match the %s request in --body", operation, named)
+	}
+	return named, nil, trimmed, nil
+}
+
+// postEnvelope sends an XML request body through client without re-encoding
+// it and decodes the reply into response. A bare operation element is
+// wrapped in a SOAP 1.1 envelope with the configured SOAP headers; a whole
+// envelope is sent as is, with the SOAP 1.2 content type when it uses the
+// SOAP 1.2 namespace.
+func postEnvelope(ctx context.Context, client *http.Client, endpoint string, opts commandOptions, operation string, body []byte, response interface{}) error {
+	_, envelope, err := service.RequestOperation(body)
+	if err != nil {
+		return err
+	}
+	if envelope == "" {
+		if body, err = service.WrapRequest(body, soapHeaders(opts)...); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	action := opts.Quirks.Action(operation)
+	if envelope == service.SOAP12Namespace {
+		req.Header.Set("Content-Type", `application/soap+xml; charset="utf-8"; action=`+strconv.Quote(strings.Trim(action, `"`)))
+	} else {
+		req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+		req.Header.Set("SOAPAction", action)
+	}
+	if usesBasicAuth(opts) {
+		req.SetBasicAuth(opts.Username, opts.Password)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 400 {
+		return &soap.HTTPError{StatusCode: resp.StatusCode, ResponseBody: data}
+	}
+	return opts.Quirks.DecodeResponse(data, response)
+}
+
+// describeCallError surfaces the SOAP fault inside an HTTP error response,
+// which gowsdl otherwise reports only as a status code.
+func describeCallError(err error) error {
+	var httpErr *soap.HTTPError
+	if !errors.As(err, &httpErr) || len(httpErr.ResponseBody) == 0 {
+		return err
+	}
+	var fault *soap.SOAPFault
+	if _, _, decodeErr := service.DecodeResponse(httpErr.ResponseBody); errors.As(decodeErr, &fault) {
+		return fmt.Errorf("HTTP %d: SOAP fault %s: %s", httpErr.StatusCode, fault.Code, fault.String)
+	}
+	return err
+}
+
+func (a *App) renderCall(format string, resp interface{}) error {
+	if output.NormaliseFormat(format) == output.FormatJSON {
+		return output.WriteJSON(a.out, resp)
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var tree interface{}
+	if err := decoder.Decode(&tree); err != nil {
+		return err
+	}
+	return printTextTree(a.out, tree, "")
+}
+
+// printTextTree prints decoded JSON as indented "Field: value" lines, the
+// layout used by the text renderers for status and read.
+func printTextTree(out io.Writer, node interface{}, indent string) error {
+	switch node := node.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(node))
+		for key := range node {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if isTextLeaf(node[key]) {
+				if _, err := fmt.Fprintf(out, "%s%s: %v\n", indent, key, node[key]); err != nil {
+					return err
+				}
+				continue
+			}
+			if _, err := fmt.Fprintf(out, "%s%s:\n", indent, key); err != nil {
+				return err
+			}
+			if err := printTextTree(out, node[key], indent+"  "); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, child := range node {
+			if isTextLeaf(child) {
+				if _, err := fmt.Fprintf(out, "%s- %v\n", indent, child); err != nil {
+					return err
+				}
+				continue
+			}
+			if _, err := fmt.Fprintf(out, "%s-\n", indent); err != nil {
+				return err
+			}
+			if err := printTextTree(out, child, indent+"  "); err != nil {
+				return err
+			}
+		}
+	default:
+		_, err := fmt.Fprintf(out, "%s%v\n", indent, node)
+		return err
+	}
+	return nil
+}
+
+func isTextLeaf(node interface{}) bool {
+	switch node.(type) {
+	case map[string]interface{}, []interface{}:
+		return false
+	default:
+		return true
+	}
+}
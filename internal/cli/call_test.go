@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"opc-xml-da-cli/service/servicetest"
+)
+
+func TestCallSendsXMLBodyAndPrintsEnvelopes(t *testing.T) {
+	fake, server := newFakeServer(t)
+	body := filepath.Join(t.TempDir(), "request.xml")
+	request := `<Read xmlns="http://opcfoundation.org/webservices/XMLDA/1.0/">
+  <ItemList><Items ItemName="Plant.Area.Temp"/><Items ItemName="Plant.Line.Count"/></ItemList>
+</Read>`
+	if err := os.WriteFile(body, []byte(request), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var out, errOut bytes.Buffer
+	code := NewApp(&out, &errOut).Run([]string{"call", "--endpoint", server.URL, "--body", body, "--raw"})
+	if code != exitSuccess {
+		t.Fatalf("Run(call) = %d, stderr %q", code, errOut.String())
+	}
+	var resp struct {
+		RItemList struct {
+			Items []struct {
+				ItemName string
+				Value    struct{ InnerXML string }
+			}
+		}
+	}
+	if err := json.Unmarshal(out.Bytes(), &resp); err != nil {
+		t.Fatalf("call output is not JSON: %v\n%s", err, out.String())
+	}
+	if len(resp.RItemList.Items) != 2 || resp.RItemList.Items[1].Value.InnerXML != "7" {
+		t.Fatalf("call output = %s", out.String())
+	}
+	if !strings.Contains(errOut.String(), "> request\n") || !strings.Contains(errOut.String(), "ReadResponse") {
+		t.Fatalf("raw envelopes missing from stderr:\n%s", errOut.String())
+	}
+	if reqs := fake.Requests(); len(reqs) != 1 {
+		t.Fatalf("Requests() = %d", len(reqs))
+	}
+}
+
+func TestCallSendsXMLBodyVerbatim(t *testing.T) {
+	var got struct {
+		body, contentType, action string
+	}
+	server, _ := newCountingServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			data, _ := io.ReadAll(r.Body)
+			got.body, got.contentType, got.action = string(data), r.Header.Get("Content-Type"), r.Header.Get("SOAPAction")
+			r.Body = io.NopCloser(bytes.NewReader(data))
+			next.ServeHTTP(w, r)
+		})
+	})
+	element := `<Read xmlns="http://opcfoundation.org/webservices/XMLDA/1.0/" xmlns:v="urn:vendor" v:Trace="1">
+  <v:Extension Mode="fast">keep me</v:Extension>
+  <ItemList><Items ItemName="Plant.Area.Temp"/></ItemList>
+</Read>`
+	soap12 := `<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope"><env:Header><v:Session xmlns:v="urn:vendor">42</v:Session></env:Header><env:Body>` + element + `</env:Body></env:Envelope>`
+	for _, tc := range []struct {
+		name, body, contentType string
+	}{
+		{"bare element", element, "text/xml"},
+		{"soap 1.2 envelope", soap12, "application/soap+xml"},
+	} {
+		path := filepath.Join(t.TempDir(), "request.xml")
+		if err := os.WriteFile(path, []byte(tc.body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		var out, errOut bytes.Buffer
+		code := NewApp(&out, &errOut).Run([]string{"call", "--endpoint", server.URL, "--body", path})
+		if code != exitSuccess || !strings.Contains(out.String(), `"InnerXML": "21.5"`) {
+			t.Fatalf("%s: Run(call) = %d, stdout %s, stderr %q", tc.name, code, out.String(), errOut.String())
+		}
+		if !strings.Contains(got.body, tc.body) {
+			t.Fatalf("%s: request was re-encoded:\n%s", tc.name, got.body)
+		}
+		if !strings.HasPrefix(got.contentType, tc.contentType) {
+			t.Fatalf("%s: Content-Type = %q", tc.name, got.contentType)
+		}
+	}
+	if got.action != "" || !strings.Contains(got.contentType, `action="http://opcfoundation.org/webservices/XMLDA/1.0/Read"`) {
+		t.Fatalf("SOAP 1.2 action headers = %q, %q", got.action, got.contentType)
+	}
+}
+
+func TestCallJSONBodyAndFault(t *testing.T) {
+	fake, server := newFakeServer(t)
+	body := filepath.Join(t.TempDir(), "write.json")
+	request := `{"ItemList": {"Items": [{"ItemName": "Plant.Area.Temp", "Value": {"InnerXML": "22.5", "Type": "double"}}]}}`
+	if err := os.WriteFile(body, []byte(request), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var out, errOut bytes.Buffer
+	code := NewApp(&out, &errOut).Run([]string{"call", "--endpoint", server.URL, "--operation", "Write", "--body", body, "--format", "text"})
+	if code != exitSuccess {
+		t.Fatalf("Run(call Write) = %d, stderr %q", code, errOut.String())
+	}
+	if value, _ := fake.Value("Plant.Area.Temp"); value.InnerXML != "22.5" {
+		t.Fatalf("Value(Plant.Area.Temp) = %+v", value)
+	}
+	if !strings.Contains(out.String(), "RItemList:\n") {
+		t.Fatalf("text output:\n%s", out.String())
+	}
+
+	fake.SetError(servicetest.OpGetStatus, errors.New("device offline"))
+	errOut.Reset()
+	code = NewApp(&bytes.Buffer{}, &errOut).Run([]string{"call", "--endpoint", server.URL, "--operation", "GetStatus"})
+	if code == exitSuccess || !strings.Contains(errOut.String(), "SOAP fault") || !strings.Contains(errOut.String(), "device offline") {
+		t.Fatalf("Run(call GetStatus) = %d, stderr %q", code, errOut.String())
+	}
+}
+
+func TestCallRejectsMismatchedOperation(t *testing.T) {
+	body := filepath.Join(t.TempDir(), "request.xml")
+	if err := os.WriteFile(body, []byte(`<Browse xmlns="http://opcfoundation.org/webservices/XMLDA/1.0/"/>`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var errOut bytes.Buffer
+	code := NewApp(&bytes.Buffer{}, &errOut).Run([]string{"call", "--endpoint", "http://localhost:1", "--operation", "Read", "--body", body})
+	if code != exitConfigError || !strings.Contains(errOut.String(), "does not match") {
+		t.Fatalf("Run(call) = %d, stderr %q", code, errOut.String())
+	}
+}
@@ -0,0 +1,178 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"opc-xml-da-cli/internal/config"
+	"opc-xml-da-cli/internal/output"
+)
+
+const configUsage = "usage: config show [--profile X] [--sources] | config profiles | config get KEY | config set KEY VALUE | config schema"
+
+// configCommand dispatches the config subcommands, which inspect and edit
+// the config file without touching the server.
+func (a *App) configCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New(configUsage)
+	}
+	switch args[0] {
+	case "show":
+		return a.configShow(args[1:])
+	case "profiles":
+		return a.configProfiles(args[1:])
+	case "get":
+		return a.configGet(args[1:])
+	case "set":
+		return a.configSet(args[1:])
+	case "schema":
+		return a.configSchema(args[1:])
+	default:
+		return fmt.Errorf("unknown config subcommand %q; %s", args[0], configUsage)
+	}
+}
+
+// newConfigFlagSet returns a flag set with --config, defaulting to
+// OPCXMLDA_CONFIG when set.
+func (a *App) newConfigFlagSet(name string, configPath *string) *flag.FlagSet {
+	*configPath = config.DefaultConfigPath
+	if path, ok := os.LookupEnv(config.EnvConfig); ok && path != "" {
+		*configPath = path
+	}
+	fs := a.newFlagSet("config " + name)
+	fs.StringVar(configPath, "config", *configPath, "YAML config file")
+	return fs
+}
+
+func (a *App) configShow(args []string) error {
+	var configPath, profile string
+	sources := false
+	fs := a.newConfigFlagSet("show", &configPath)
+	fs.StringVar(&profile, "profile", "", "config profile name")
+	fs.BoolVar(&sources, "sources", false, "show where each value came from")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	effective, err := config.Load(config.LoadOptions{Path: configPath, Profile: profile, LookupEnv: os.LookupEnv})
+	if err != nil {
+		return err
+	}
+	if sources {
+		return printConfigSources(a.out, effective)
+	}
+	if effective.Profile != "" {
+		fmt.Fprintf(a.out, "profile: %s\n", effective.Profile)
+	}
+	for _, field := range config.Fields(effective.ClientConfig) {
+		fmt.Fprintf(a.out, "%s: %s\n", field.Key, field.Value)
+	}
+	return nil
+}
+
+func (a *App) configProfiles(args []string) error {
+	var configPath string
+	fs := a.newConfigFlagSet("profiles", &configPath)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	fileCfg, err := config.LoadFile(configPath)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(fileCfg.Profiles))
+	for name := range fileCfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	rows := make([][]string, 0, len(names))
+	for _, name := range names {
+		marker := ""
+		if name == fileCfg.DefaultProfile {
+			marker = "*"
+		}
+		// Template profiles may leave variables for their children to
+		// define, so fall back to the endpoint as written.
+		endpoint := strings.Join(fileCfg.Profiles[name].EndpointList(), ",")
+		if resolved, err := fileCfg.ResolveProfile(name); err == nil {
+			endpoint = strings.Join(resolved.EndpointList(), ",")
+		} else if endpoint == "" {
+			endpoint = fileCfg.Endpoint
+		}
+		rows = append(rows, []string{name, marker, fileCfg.Profiles[name].Extends, endpoint})
+	}
+	return output.WriteTable(a.out, []string{"PROFILE", "DEFAULT", "EXTENDS", "ENDPOINT"}, rows)
+}
+
+func (a *App) configGet(args []string) error {
+	var configPath string
+	fs := a.newConfigFlagSet("get", &configPath)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: config get KEY")
+	}
+	if _, err := os.Stat(configPath); err != nil {
+		return fmt.Errorf("read config %q: %w", configPath, err)
+	}
+	doc, err := config.LoadDocument(configPath)
+	if err != nil {
+		return err
+	}
+	value, ok, err := doc.Get(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("config key %q is not set", fs.Arg(0))
+	}
+	fmt.Fprintln(a.out, value)
+	return nil
+}
+
+func (a *App) configSet(args []string) error {
+	var configPath string
+	fs := a.newConfigFlagSet("set", &configPath)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errors.New("usage: config set KEY VALUE")
+	}
+	doc, err := config.LoadDocument(configPath)
+	if err != nil {
+		return err
+	}
+	if err := doc.Set(fs.Arg(0), fs.Arg(1)); err != nil {
+		return err
+	}
+	if err := doc.Save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "set %s in %s\n", fs.Arg(0), configPath)
+	return nil
+}
+
+func (a *App) configSchema(args []string) error {
+	// --config is accepted, like the other subcommands, but the schema
+	// does not depend on it.
+	var configPath string
+	fs := a.newConfigFlagSet("schema", &configPath)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errors.New("usage: config schema")
+	}
+	data, err := json.MarshalIndent(config.Schema(), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(a.out, "%s\n", data)
+	return err
+}
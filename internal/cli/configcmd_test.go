@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigSetGetShowProfiles(t *testing.T) {
+	path := writeCLIConfig(t, `# managed by ops
+endpoint: http://localhost/opc
+default_profile: site-a
+profiles:
+  site-a:
+    endpoint: http://site-a/opc # line 3
+`)
+	run := func(args ...string) string {
+		t.Helper()
+		var out, errOut bytes.Buffer
+		if code := NewApp(&out, &errOut).Run(args); code != exitSuccess {
+			t.Fatalf("Run(%v) = %d, stderr %q", args, code, errOut.String())
+		}
+		return out.String()
+	}
+
+	run("config", "set", "--config", path, "profiles.site-b.endpoint", "http://site-b/opc")
+	run("--config", path, "config", "set", "profiles.site-a.locale", "de-DE")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "# managed by ops") || !strings.Contains(string(data), "# line 3") {
+		t.Fatalf("config set dropped comments:\n%s", data)
+	}
+
+	if got := run("config", "get", "--config", path, "profiles.site-b.endpoint"); got != "http://site-b/opc\n" {
+		t.Fatalf("config get = %q", got)
+	}
+	profiles := run("config", "profiles", "--config", path)
+	if !strings.Contains(profiles, "site-a") || !strings.Contains(profiles, "*") || !strings.Contains(profiles, "http://site-b/opc") {
+		t.Fatalf("config profiles = %q", profiles)
+	}
+	show := run("config", "show", "--config", path)
+	if !strings.Contains(show, "profile: site-a") || !strings.Contains(show, "endpoint: http://site-a/opc") || !strings.Contains(show, "locale: de-DE") {
+		t.Fatalf("config show = %q", show)
+	}
+	show = run("config", "show", "--config", path, "--profile", "site-b", "--sources")
+	if !strings.Contains(show, "profile site-b") || !strings.Contains(show, "flag --profile") {
+		t.Fatalf("config show --sources = %q", show)
+	}
+
+	var errOut bytes.Buffer
+	code := NewApp(&bytes.Buffer{}, &errOut).Run([]string{"config", "set", "--config", path, "profiles.site-a.endpoit", "x"})
+	if code != exitConfigError || !strings.Contains(errOut.String(), "unknown config key") {
+		t.Fatalf("Run(config set typo) = %d, stderr %q", code, errOut.String())
+	}
+	errOut.Reset()
+	code = NewApp(&bytes.Buffer{}, &errOut).Run([]string{"config", "get", "--config", path, "username"})
+	if code != exitConfigError || !strings.Contains(errOut.String(), "is not set") {
+		t.Fatalf("Run(config get unset) = %d, stderr %q", code, errOut.String())
+	}
+}
+
+func TestConfigProfilesResolvesExtends(t *testing.T) {
+	path := writeCLIConfig(t, `
+profiles:
+  plant:
+    endpoint: http://${host}/OPC/DA
+  site-a:
+    extends: plant
+    vars:
+      host: 10.0.0.1
+`)
+	var out, errOut bytes.Buffer
+	if code := NewApp(&out, &errOut).Run([]string{"config", "set", "--config", path, "profiles.site-b.vars.host", "10.0.0.2"}); code != exitSuccess {
+		t.Fatalf("Run(config set vars) = %d, stderr %q", code, errOut.String())
+	}
+	if code := NewApp(&out, &errOut).Run([]string{"config", "set", "--config", path, "profiles.site-b.extends", "plant"}); code != exitSuccess {
+		t.Fatalf("Run(config set extends) = %d, stderr %q", code, errOut.String())
+	}
+	out.Reset()
+	if code := NewApp(&out, &errOut).Run([]string{"config", "profiles", "--config", path}); code != exitSuccess {
+		t.Fatalf("Run(config profiles) = %d, stderr %q", code, errOut.String())
+	}
+	if !strings.Contains(out.String(), "http://10.0.0.1/OPC/DA") || !strings.Contains(out.String(), "http://10.0.0.2/OPC/DA") {
+		t.Fatalf("config profiles = %q", out.String())
+	}
+
+	errOut.Reset()
+	code := NewApp(&bytes.Buffer{}, &errOut).Run([]string{"config", "show", "--config", path, "--profile", "plant"})
+	if code != exitConfigError || !strings.Contains(errOut.String(), "undefined variable ${host}") {
+		t.Fatalf("Run(config show plant) = %d, stderr %q", code, errOut.String())
+	}
+}
+
+func TestConfigInspectionDoesNotResolveSecrets(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "ran")
+	path := writeCLIConfig(t, `endpoint: http://localhost/opc
+password: "cmd:touch `+marker+`"
+http:
+  headers:
+    X-Api-Key: env:OPC_TEST_UNSET_KEY
+`)
+	for _, args := range [][]string{
+		{"config", "show", "--config", path},
+		{"validate-config", "--config", path},
+	} {
+		var out, errOut bytes.Buffer
+		if code := NewApp(&out, &errOut).Run(args); code != exitSuccess {
+			t.Fatalf("Run(%v) = %d, stderr %q", args, code, errOut.String())
+		}
+		if strings.Contains(out.String(), "touch") {
+			t.Fatalf("Run(%v) printed the secret reference:\n%s", args, out.String())
+		}
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatal("inspecting the config ran the cmd: secret")
+	}
+}
+
+func TestConfigSchema(t *testing.T) {
+	var out, errOut bytes.Buffer
+	if code := NewApp(&out, &errOut).Run([]string{"config", "schema"}); code != exitSuccess {
+		t.Fatalf("Run(config schema) = %d, stderr %q", code, errOut.String())
+	}
+	var schema struct {
+		Schema     string                     `json:"$schema"`
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &schema); err != nil {
+		t.Fatalf("config schema is not JSON: %v", err)
+	}
+	if !strings.Contains(schema.Schema, "json-schema.org") || schema.Properties["profiles"] == nil || schema.Properties["request_timeout"] == nil {
+		t.Fatalf("config schema = %s", out.String())
+	}
+}
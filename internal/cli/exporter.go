@@ -0,0 +1,287 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"opc-xml-da-cli/internal/sink"
+	"opc-xml-da-cli/service"
+)
+
+const defaultExporterListen = ":9108"
+
+type exporterSample struct {
+	ItemPath string
+	ItemName string
+	Quality  string
+	Value    float64
+}
+
+// itemExporter polls items on an interval and serves the latest values as
+// Prometheus text exposition metrics.
+type itemExporter struct {
+	svc          service.OpcXmlDASoap
+	locale       string
+	clientHandle string
+	items        []itemRef
+
+	mu             sync.Mutex
+	samples        []exporterSample
+	serverState    string
+	up             bool
+	scrapeDuration time.Duration
+	lastPoll       time.Time
+	readErrors     uint64
+	statusErrors   uint64
+}
+
+func newItemExporter(svc service.OpcXmlDASoap, locale, clientHandle string, items []itemRef) *itemExporter {
+	return &itemExporter{svc: svc, locale: locale, clientHandle: clientHandle, items: items}
+}
+
+func (a *App) exporter(args []string) error {
+	opts := defaultCommandOptions()
+	var itemNames stringList
+	var itemPaths stringList
+	itemsFile := ""
+	listen := defaultExporterListen
+	interval := 10 * time.Second
+	fs := a.newFlagSet("exporter")
+	addCommonFlagsWithoutFormat(fs, &opts)
+	fs.StringVar(&listen, "listen", listen, "HTTP listen address for the /metrics endpoint")
+	fs.Var(&itemNames, "item-name", "OPC read item name; repeat for multiple items")
+	fs.Var(&itemPaths, "item-path", "OPC read item path; repeat for multiple items")
+	fs.StringVar(&itemsFile, "items", "", "path to file with one item name per line")
+	fs.DurationVar(&interval, "interval", interval, "poll interval")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if interval <= 0 {
+		return fmt.Errorf("--interval must be greater than zero")
+	}
+	if err := opts.applyConfig(fs); err != nil {
+		return err
+	}
+	items, err := readItemRefs(itemNames, itemPaths, itemsFile)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return fmt.Errorf("at least one --item-name or --item-path is required")
+	}
+	_, opcService, err := a.newService(opts)
+	if err != nil {
+		return err
+	}
+
+	exp := newItemExporter(opcService, opts.Locale, opts.ClientHandle, items)
+	ctx, stop := interruptContext()
+	defer stop()
+	go exp.run(ctx, interval, opts.RequestTimeout)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", exp)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintln(w, "opc-xml-da-cli exporter; metrics are served at /metrics")
+	})
+	server := &http.Server{Addr: listen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	slog.Info("exporter listening", "listen", listen, "items", len(items), "interval", interval)
+	fmt.Fprintf(a.err, "serving metrics on %s/metrics\n", listen)
+	if err := listenAndServe(ctx, server); err != nil {
+		return fmt.Errorf("exporter listen %s: %w", listen, err)
+	}
+	return nil
+}
+
+func (e *itemExporter) run(ctx context.Context, interval, requestTimeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		e.poll(ctx, requestTimeout)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll refreshes the server state and reads every configured item in one
+// Read request. Each request gets its own requestTimeout.
+func (e *itemExporter) poll(ctx context.Context, requestTimeout time.Duration) {
+	start := time.Now()
+	state := ""
+	up := true
+	var statusFailures uint64
+	statusCtx, cancel := withOptionalTimeout(ctx, requestTimeout)
+	resp, err := FetchServerStatus(statusCtx, e.svc, e.locale, e.clientHandle)
+	cancel()
+	if err != nil {
+		slog.Warn("exporter status failed", "err", err)
+		up = false
+		statusFailures++
+	} else if resp != nil && resp.GetStatusResult != nil && resp.GetStatusResult.ServerState != nil {
+		state = string(*resp.GetStatusResult.ServerState)
+	}
+
+	samples := make([]exporterSample, 0, len(e.items))
+	var readFailures uint64
+	readCtx, cancel := withOptionalTimeout(ctx, requestTimeout)
+	read, err := FetchNodeValues(readCtx, e.svc, e.locale, e.clientHandle, e.items)
+	cancel()
+	if err != nil {
+		slog.Warn("exporter read failed", "items", len(e.items), "err", err)
+		readFailures++
+	} else if read != nil && read.RItemList != nil {
+		for i, value := range read.RItemList.Items {
+			if value == nil {
+				continue
+			}
+			if value.ResultID != nil && isOPCErrorResult(*value.ResultID) {
+				readFailures++
+				continue
+			}
+			number, ok := sink.ParseNumber(formatXMLDAValue(value.Value))
+			if !ok {
+				continue
+			}
+			// Replies list items in request order; fall back to the request
+			// for servers that leave out the item name or path.
+			var item itemRef
+			if i < len(e.items) {
+				item = e.items[i]
+			}
+			samples = append(samples, exporterSample{
+				ItemPath: firstNonEmpty(value.ItemPath, item.ItemPath),
+				ItemName: firstNonEmpty(value.ItemName, item.ItemName),
+				Quality:  qualityName(value.Quality),
+				Value:    number,
+			})
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.samples = samples
+	e.serverState = state
+	e.up = up
+	e.readErrors += readFailures
+	e.statusErrors += statusFailures
+	e.scrapeDuration = time.Since(start)
+	e.lastPoll = time.Now()
+}
+
+func (e *itemExporter) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := e.writeMetrics(w); err != nil {
+		slog.Warn("exporter write metrics failed", "err", err)
+	}
+}
+
+func (e *itemExporter) writeMetrics(w io.Writer) error {
+	e.mu.Lock()
+	samples := append([]exporterSample(nil), e.samples...)
+	state := e.serverState
+	up := e.up
+	readErrors := e.readErrors
+	statusErrors := e.statusErrors
+	duration := e.scrapeDuration
+	lastPoll := e.lastPoll
+	e.mu.Unlock()
+
+	sort.Slice(samples, func(i, j int) bool {
+		return makeBrowseKey(samples[i].ItemPath, samples[i].ItemName) < makeBrowseKey(samples[j].ItemPath, samples[j].ItemName)
+	})
+
+	var b strings.Builder
+	writeMetricHeader(&b, "opcxmlda_item_value", "gauge", "Latest numeric item value read from the server.")
+	for _, sample := range samples {
+		fmt.Fprintf(&b, "opcxmlda_item_value{item_path=%s,item_name=%s,quality=%s} %s\n",
+			promLabel(sample.ItemPath), promLabel(sample.ItemName), promLabel(sample.Quality), formatPromFloat(sample.Value))
+	}
+	writeMetricHeader(&b, "opcxmlda_up", "gauge", "Whether the last GetStatus request succeeded.")
+	fmt.Fprintf(&b, "opcxmlda_up %d\n", boolToInt(up))
+	writeMetricHeader(&b, "opcxmlda_server_state", "gauge", "Server state reported by GetStatus; 1 for the current state.")
+	for _, known := range serverStates() {
+		fmt.Fprintf(&b, "opcxmlda_server_state{state=%s} %d\n", promLabel(known), boolToInt(known == state))
+	}
+	writeMetricHeader(&b, "opcxmlda_scrape_duration_seconds", "gauge", "Duration of the last poll cycle.")
+	fmt.Fprintf(&b, "opcxmlda_scrape_duration_seconds %s\n", formatPromFloat(duration.Seconds()))
+	writeMetricHeader(&b, "opcxmlda_last_poll_timestamp_seconds", "gauge", "Unix time of the last completed poll cycle.")
+	lastPollSeconds := 0.0
+	if !lastPoll.IsZero() {
+		lastPollSeconds = float64(lastPoll.UnixNano()) / float64(time.Second)
+	}
+	fmt.Fprintf(&b, "opcxmlda_last_poll_timestamp_seconds %s\n", formatPromFloat(lastPollSeconds))
+	writeMetricHeader(&b, "opcxmlda_errors_total", "counter", "Failed requests by operation.")
+	fmt.Fprintf(&b, "opcxmlda_errors_total{operation=\"read\"} %d\n", readErrors)
+	fmt.Fprintf(&b, "opcxmlda_errors_total{operation=\"status\"} %d\n", statusErrors)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeMetricHeader(b *strings.Builder, name, kind, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func serverStates() []string {
+	return []string{
+		string(service.ServerStateRunning),
+		string(service.ServerStateFailed),
+		string(service.ServerStateNoConfig),
+		string(service.ServerStateSuspended),
+		string(service.ServerStateTest),
+		string(service.ServerStateCommFault),
+	}
+}
+
+func qualityName(quality *service.OPCQuality) string {
+	if quality == nil || quality.QualityField == nil {
+		return ""
+	}
+	return string(*quality.QualityField)
+}
+
+func isOPCErrorResult(resultID service.QName) bool {
+	id := string(resultID)
+	if i := strings.LastIndex(id, ":"); i >= 0 {
+		id = id[i+1:]
+	}
+	return strings.HasPrefix(id, "E_")
+}
+
+func promLabel(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + replacer.Replace(value) + `"`
+}
+
+func formatPromFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func boolToInt(value bool) int {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+func withOptionalTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
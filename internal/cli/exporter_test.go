@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"opc-xml-da-cli/service"
+)
+
+type fakeExporterService struct {
+	service.OpcXmlDASoap
+	values map[string]string
+	state  service.ServerState
+	reads  int
+	// delay holds each request, to check the per-request timeout.
+	delay time.Duration
+}
+
+func (f *fakeExporterService) GetStatusContext(ctx context.Context, _ *service.GetStatus) (*service.GetStatusResponse, error) {
+	if err := f.wait(ctx); err != nil {
+		return nil, err
+	}
+	state := f.state
+	return &service.GetStatusResponse{GetStatusResult: &service.ReplyBase{ServerState: &state}}, nil
+}
+
+func (f *fakeExporterService) ReadContext(ctx context.Context, req *service.Read) (*service.ReadResponse, error) {
+	f.reads++
+	if err := f.wait(ctx); err != nil {
+		return nil, err
+	}
+	good := service.QualityBitsGood
+	unknown := service.QName("E_UNKNOWNITEMNAME")
+	list := &service.ReplyItemList{}
+	for _, item := range req.ItemList.Items {
+		value, ok := f.values[item.ItemName]
+		if !ok {
+			list.Items = append(list.Items, &service.ItemValue{ItemName: item.ItemName, ResultID: &unknown})
+			continue
+		}
+		list.Items = append(list.Items, &service.ItemValue{
+			Value:   service.AnyType{InnerXML: value},
+			Quality: &service.OPCQuality{QualityField: &good},
+		})
+	}
+	return &service.ReadResponse{RItemList: list}, nil
+}
+
+func (f *fakeExporterService) wait(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(f.delay):
+		return nil
+	}
+}
+
+func TestItemExporterPollAndWriteMetrics(t *testing.T) {
+	svc := &fakeExporterService{
+		values: map[string]string{"Plant.Temp": "24.5", "Plant.Run": "true", "Plant.Name": "pump"},
+		state:  service.ServerStateRunning,
+	}
+	exp := newItemExporter(svc, "", "", []itemRef{
+		{ItemName: "Plant.Temp"}, {ItemName: "Plant.Run"}, {ItemName: "Plant.Name"}, {ItemName: "Missing"},
+	})
+	exp.poll(context.Background(), time.Second)
+	if svc.reads != 1 {
+		t.Fatalf("poll sent %d Read requests, want one for all items", svc.reads)
+	}
+
+	var out bytes.Buffer
+	if err := exp.writeMetrics(&out); err != nil {
+		t.Fatalf("writeMetrics returned error: %v", err)
+	}
+	metrics := out.String()
+	for _, want := range []string{
+		`opcxmlda_item_value{item_path="",item_name="Plant.Temp",quality="good"} 24.5`,
+		`opcxmlda_item_value{item_path="",item_name="Plant.Run",quality="good"} 1`,
+		`opcxmlda_server_state{state="running"} 1`,
+		`opcxmlda_server_state{state="failed"} 0`,
+		`opcxmlda_up 1`,
+		`opcxmlda_errors_total{operation="read"} 1`,
+		"# TYPE opcxmlda_scrape_duration_seconds gauge",
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("metrics missing %q:\n%s", want, metrics)
+		}
+	}
+	if strings.Contains(metrics, "Plant.Name") {
+		t.Errorf("non-numeric item exported:\n%s", metrics)
+	}
+}
+
+func TestItemExporterTimesOutEachRequest(t *testing.T) {
+	svc := &fakeExporterService{values: map[string]string{"Plant.Temp": "24.5"}, state: service.ServerStateRunning, delay: 30 * time.Millisecond}
+	exp := newItemExporter(svc, "", "", []itemRef{{ItemName: "Plant.Temp"}})
+	exp.poll(context.Background(), 50*time.Millisecond)
+	var out bytes.Buffer
+	if err := exp.writeMetrics(&out); err != nil {
+		t.Fatalf("writeMetrics returned error: %v", err)
+	}
+	if !strings.Contains(out.String(), `item_name="Plant.Temp",quality="good"} 24.5`) {
+		t.Fatalf("metrics:\n%s", out.String())
+	}
+}
+
+func TestPromLabelEscapes(t *testing.T) {
+	if got := promLabel("a\"b\\c\nd"); got != `"a\"b\\c\nd"` {
+		t.Fatalf("promLabel = %s", got)
+	}
+}
+
+func TestRunExporterRequiresItems(t *testing.T) {
+	var out, err bytes.Buffer
+	code := NewApp(&out, &err).Run([]string{"exporter", "--endpoint", "http://localhost/opc"})
+	if code != exitConfigError {
+		t.Fatalf("Run(exporter) = %d, want %d", code, exitConfigError)
+	}
+	if !strings.Contains(err.String(), "at least one --item-name or --item-path is required") {
+		t.Fatalf("stderr = %q", err.String())
+	}
+}
@@ -0,0 +1,397 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/hooklift/gowsdl/soap"
+
+	"opc-xml-da-cli/internal/config"
+	"opc-xml-da-cli/internal/output"
+	"opc-xml-da-cli/service"
+)
+
+// failoverService sends every call to the active endpoint of a primary and
+// backup list. When the active endpoint cannot be reached, or replies with
+// a failed or commFault server state, it moves to the next endpoint whose
+// GetStatus health check reports running. Reads, browses, status, and
+// property requests are then sent again to the new endpoint; writes and
+// subscription calls are not, because they may already have taken effect or
+// refer to server-side state the backup does not have.
+//
+// With failback auto, an earlier endpoint is health checked at most once
+// per health interval and becomes active again as soon as it passes.
+//
+// Health checks run outside the lock, one goroutine at a time, so that
+// concurrent calls from serve, stream, or exporter keep going to the active
+// endpoint meanwhile. They and the resent call run under their own
+// probeTimeout, because the caller's request context has usually expired by
+// the time a hung endpoint is given up on.
+type failoverService struct {
+	endpoints      []string
+	services       []service.OpcXmlDASoap
+	failback       string
+	healthInterval time.Duration
+	locale         string
+	clientHandle   string
+	probeTimeout   time.Duration
+	now            func() time.Time
+	// onSwitch, when set, is told about every change of active endpoint.
+	onSwitch func(endpointSwitch)
+
+	mu        sync.Mutex
+	active    int
+	lastProbe time.Time
+	probing   bool
+}
+
+// defaultProbeTimeout bounds health checks and resent calls when no request
+// timeout is configured.
+const defaultProbeTimeout = 30 * time.Second
+
+// endpointSwitch describes one move between endpoints.
+type endpointSwitch struct {
+	Event  string    `json:"event"`
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Reason string    `json:"reason"`
+	Time   time.Time `json:"time"`
+}
+
+func newFailoverService(opts commandOptions, clients []*soap.Client) *failoverService {
+	f := &failoverService{
+		failback:       opts.Failover.Failback,
+		healthInterval: opts.Failover.HealthInterval,
+		locale:         opts.Locale,
+		clientHandle:   opts.ClientHandle,
+		probeTimeout:   opts.RequestTimeout,
+		now:            time.Now,
+	}
+	if f.failback == "" {
+		f.failback = config.FailbackAuto
+	}
+	if f.healthInterval == 0 {
+		f.healthInterval = config.DefaultHealthInterval
+	}
+	for _, client := range clients {
+		f.services = append(f.services, service.NewOpcXmlDASoapWithQuirks(client, opts.Quirks))
+	}
+	f.endpoints = opts.Endpoints
+	return f
+}
+
+// activeEndpoint returns the endpoint calls are currently sent to.
+func (f *failoverService) activeEndpoint() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.endpoints[f.active]
+}
+
+// failoverCall runs call against the active endpoint and fails over when the
+// endpoint looks unhealthy. resend says whether call may be repeated on the
+// new endpoint. A call the caller cancelled is not failed over, but one that
+// ran out of time is: that is how a hung endpoint shows.
+func failoverCall[T any](ctx context.Context, f *failoverService, resend bool, call func(context.Context, service.OpcXmlDASoap) (T, *service.ReplyBase, error)) (T, error) {
+	index := f.current(ctx)
+	resp, reply, err := call(ctx, f.services[index])
+	reason := unhealthyReason(reply, err)
+	if reason == "" || errors.Is(ctx.Err(), context.Canceled) {
+		return resp, err
+	}
+	recoverCtx, cancel := f.recoveryContext(ctx)
+	defer cancel()
+	next, ok := f.failover(recoverCtx, index, reason)
+	if !ok || !resend {
+		return resp, err
+	}
+	resp, _, err = call(recoverCtx, f.services[next])
+	return resp, err
+}
+
+// recoveryContext returns a context for health checks and a resent call
+// that keeps the values of ctx but not its deadline, bounded by
+// probeTimeout.
+func (f *failoverService) recoveryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := f.probeTimeout
+	if timeout <= 0 {
+		timeout = defaultProbeTimeout
+	}
+	return context.WithTimeout(context.WithoutCancel(ctx), timeout)
+}
+
+// current returns the active endpoint, first failing back to an earlier
+// one if the policy allows and a health check is due. Only one goroutine
+// runs the health checks; the others get the active endpoint at once.
+func (f *failoverService) current(ctx context.Context) int {
+	f.mu.Lock()
+	active := f.active
+	if active == 0 || f.failback == config.FailbackNever || f.probing || f.now().Sub(f.lastProbe) < f.healthInterval {
+		f.mu.Unlock()
+		return active
+	}
+	f.probing = true
+	f.lastProbe = f.now()
+	f.mu.Unlock()
+
+	probeCtx, cancel := f.recoveryContext(ctx)
+	defer cancel()
+	healthy := -1
+	for candidate := 0; candidate < active; candidate++ {
+		if err := f.probe(probeCtx, candidate); err != nil {
+			slog.Info("endpoint still unhealthy", "endpoint", f.endpoints[candidate], "err", err)
+			continue
+		}
+		healthy = candidate
+		break
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.probing = false
+	if healthy >= 0 && f.active == active {
+		f.switchTo(healthy, "failback", "health check passed")
+	}
+	return f.active
+}
+
+// failover moves away from the endpoint at index to the next healthy one.
+// It reports false when no other endpoint passes its health check, or when
+// another goroutine is already checking.
+func (f *failoverService) failover(ctx context.Context, index int, reason string) (int, bool) {
+	f.mu.Lock()
+	if f.active != index {
+		// A concurrent call has already moved on.
+		active := f.active
+		f.mu.Unlock()
+		return active, true
+	}
+	if f.probing {
+		f.mu.Unlock()
+		return index, false
+	}
+	f.probing = true
+	f.mu.Unlock()
+
+	healthy := -1
+	for step := 1; step < len(f.endpoints); step++ {
+		candidate := (index + step) % len(f.endpoints)
+		if err := f.probe(ctx, candidate); err != nil {
+			slog.Info("endpoint unhealthy", "endpoint", f.endpoints[candidate], "err", err)
+			continue
+		}
+		healthy = candidate
+		break
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.probing = false
+	if healthy < 0 {
+		slog.Warn("no healthy backup endpoint", "endpoint", f.endpoints[index], "reason", reason)
+		return index, false
+	}
+	if f.active == index {
+		f.switchTo(healthy, "failover", reason)
+	}
+	return f.active, true
+}
+
+// probe is the health check: GetStatus must succeed and report running.
+func (f *failoverService) probe(ctx context.Context, index int) error {
+	resp, err := FetchServerStatus(ctx, f.services[index], f.locale, f.clientHandle)
+	if err != nil {
+		return err
+	}
+	if resp.GetStatusResult == nil || resp.GetStatusResult.ServerState == nil {
+		return errors.New("server state not reported")
+	}
+	if state := *resp.GetStatusResult.ServerState; state != service.ServerStateRunning {
+		return fmt.Errorf("server state %s", state)
+	}
+	return nil
+}
+
+func (f *failoverService) switchTo(index int, event, reason string) {
+	change := endpointSwitch{Event: event, From: f.endpoints[f.active], To: f.endpoints[index], Reason: reason, Time: f.now()}
+	f.active = index
+	f.lastProbe = change.Time
+	if event == "failover" {
+		slog.Warn("endpoint failover", "from", change.From, "to", change.To, "reason", reason)
+	} else {
+		slog.Info("endpoint failback", "from", change.From, "to", change.To, "reason", reason)
+	}
+	if f.onSwitch != nil {
+		f.onSwitch(change)
+	}
+}
+
+// unhealthyReason explains why a reply or error means the endpoint should
+// be abandoned, or returns "" when it should not.
+func unhealthyReason(reply *service.ReplyBase, err error) string {
+	if err != nil {
+		if isTransportError(err) {
+			return err.Error()
+		}
+		return ""
+	}
+	if reply != nil && reply.ServerState != nil {
+		switch *reply.ServerState {
+		case service.ServerStateFailed, service.ServerStateCommFault:
+			return "server state " + string(*reply.ServerState)
+		}
+	}
+	return ""
+}
+
+// isTransportError reports whether err means the request did not reach a
+// working server: a connection, TLS, or timeout error, or a gateway status
+// from a proxy in front of it.
+func isTransportError(err error) bool {
+	var httpErr *soap.HTTPError
+	if errors.As(err, &httpErr) {
+		switch httpErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	var urlErr *url.Error
+	var netErr net.Error
+	return errors.As(err, &urlErr) || errors.As(err, &netErr)
+}
+
+// reportEndpointSwitches adds failover and failback events to watch output
+// in the text and jsonl formats. Other formats carry only samples, so the
+// events reach them through the log.
+func (a *App) reportEndpointSwitches(svc service.OpcXmlDASoap, format string) {
+	f, ok := unwrapRetry(svc).(*failoverService)
+	if !ok {
+		return
+	}
+	switch output.NormaliseFormat(format) {
+	case output.FormatText:
+		f.onSwitch = func(change endpointSwitch) {
+			fmt.Fprintf(a.out, "%s: %s -> %s (%s)\n", change.Event, change.From, change.To, change.Reason)
+		}
+	case output.FormatJSONL:
+		f.onSwitch = func(change endpointSwitch) {
+			_ = output.WriteJSONLine(a.out, change)
+		}
+	}
+}
+
+func (f *failoverService) GetStatusContext(ctx context.Context, request *service.GetStatus) (*service.GetStatusResponse, error) {
+	return failoverCall(ctx, f, true, func(ctx context.Context, svc service.OpcXmlDASoap) (*service.GetStatusResponse, *service.ReplyBase, error) {
+		resp, err := svc.GetStatusContext(ctx, request)
+		if resp == nil {
+			return nil, nil, err
+		}
+		return resp, resp.GetStatusResult, err
+	})
+}
+
+func (f *failoverService) GetPropertiesContext(ctx context.Context, request *service.GetProperties) (*service.GetPropertiesResponse, error) {
+	return failoverCall(ctx, f, true, func(ctx context.Context, svc service.OpcXmlDASoap) (*service.GetPropertiesResponse, *service.ReplyBase, error) {
+		resp, err := svc.GetPropertiesContext(ctx, request)
+		if resp == nil {
+			return nil, nil, err
+		}
+		return resp, resp.GetPropertiesResult, err
+	})
+}
+
+func (f *failoverService) SubscribeContext(ctx context.Context, request *service.Subscribe) (*service.SubscribeResponse, error) {
+	return failoverCall(ctx, f, false, func(ctx context.Context, svc service.OpcXmlDASoap) (*service.SubscribeResponse, *service.ReplyBase, error) {
+		resp, err := svc.SubscribeContext(ctx, request)
+		if resp == nil {
+			return nil, nil, err
+		}
+		return resp, resp.SubscribeResult, err
+	})
+}
+
+func (f *failoverService) SubscriptionPolledRefreshContext(ctx context.Context, request *service.SubscriptionPolledRefresh) (*service.SubscriptionPolledRefreshResponse, error) {
+	return failoverCall(ctx, f, false, func(ctx context.Context, svc service.OpcXmlDASoap) (*service.SubscriptionPolledRefreshResponse, *service.ReplyBase, error) {
+		resp, err := svc.SubscriptionPolledRefreshContext(ctx, request)
+		if resp == nil {
+			return nil, nil, err
+		}
+		return resp, resp.SubscriptionPolledRefreshResult, err
+	})
+}
+
+func (f *failoverService) SubscriptionCancelContext(ctx context.Context, request *service.SubscriptionCancel) (*service.SubscriptionCancelResponse, error) {
+	return failoverCall(ctx, f, false, func(ctx context.Context, svc service.OpcXmlDASoap) (*service.SubscriptionCancelResponse, *service.ReplyBase, error) {
+		resp, err := svc.SubscriptionCancelContext(ctx, request)
+		return resp, nil, err
+	})
+}
+
+func (f *failoverService) BrowseContext(ctx context.Context, request *service.Browse) (*service.BrowseResponse, error) {
+	return failoverCall(ctx, f, true, func(ctx context.Context, svc service.OpcXmlDASoap) (*service.BrowseResponse, *service.ReplyBase, error) {
+		resp, err := svc.BrowseContext(ctx, request)
+		if resp == nil {
+			return nil, nil, err
+		}
+		return resp, resp.BrowseResult, err
+	})
+}
+
+func (f *failoverService) ReadContext(ctx context.Context, request *service.Read) (*service.ReadResponse, error) {
+	return failoverCall(ctx, f, true, func(ctx context.Context, svc service.OpcXmlDASoap) (*service.ReadResponse, *service.ReplyBase, error) {
+		resp, err := svc.ReadContext(ctx, request)
+		if resp == nil {
+			return nil, nil, err
+		}
+		return resp, resp.ReadResult, err
+	})
+}
+
+func (f *failoverService) WriteContext(ctx context.Context, request *service.Write) (*service.WriteResponse, error) {
+	return failoverCall(ctx, f, false, func(ctx context.Context, svc service.OpcXmlDASoap) (*service.WriteResponse, *service.ReplyBase, error) {
+		resp, err := svc.WriteContext(ctx, request)
+		if resp == nil {
+			return nil, nil, err
+		}
+		return resp, resp.WriteResult, err
+	})
+}
+
+func (f *failoverService) GetStatus(request *service.GetStatus) (*service.GetStatusResponse, error) {
+	return f.GetStatusContext(context.Background(), request)
+}
+
+func (f *failoverService) GetProperties(request *service.GetProperties) (*service.GetPropertiesResponse, error) {
+	return f.GetPropertiesContext(context.Background(), request)
+}
+
+func (f *failoverService) Subscribe(request *service.Subscribe) (*service.SubscribeResponse, error) {
+	return f.SubscribeContext(context.Background(), request)
+}
+
+func (f *failoverService) SubscriptionPolledRefresh(request *service.SubscriptionPolledRefresh) (*service.SubscriptionPolledRefreshResponse, error) {
+	return f.SubscriptionPolledRefreshContext(context.Background(), request)
+}
+
+func (f *failoverService) SubscriptionCancel(request *service.SubscriptionCancel) (*service.SubscriptionCancelResponse, error) {
+	return f.SubscriptionCancelContext(context.Background(), request)
+}
+
+func (f *failoverService) Browse(request *service.Browse) (*service.BrowseResponse, error) {
+	return f.BrowseContext(context.Background(), request)
+}
+
+func (f *failoverService) Read(request *service.Read) (*service.ReadResponse, error) {
+	return f.ReadContext(context.Background(), request)
+}
+
+func (f *failoverService) Write(request *service.Write) (*service.WriteResponse, error) {
+	return f.WriteContext(context.Background(), request)
+}
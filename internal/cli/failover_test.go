@@ -0,0 +1,216 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"opc-xml-da-cli/internal/config"
+	"opc-xml-da-cli/service"
+	"opc-xml-da-cli/service/servicetest"
+)
+
+func newTestFailover(failback string) (*failoverService, *servicetest.Fake, *servicetest.Fake, *time.Time, *[]endpointSwitch) {
+	primary, backup := servicetest.New(), servicetest.New()
+	primary.SetValue("Plant.Area.Temp", 21.5)
+	backup.SetValue("Plant.Area.Temp", 22.5)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var switches []endpointSwitch
+	f := &failoverService{
+		endpoints:      []string{"http://primary/opc", "http://backup/opc"},
+		services:       []service.OpcXmlDASoap{primary, backup},
+		failback:       failback,
+		healthInterval: time.Minute,
+		now:            func() time.Time { return now },
+		onSwitch:       func(change endpointSwitch) { switches = append(switches, change) },
+	}
+	return f, primary, backup, &now, &switches
+}
+
+func readTemp(t *testing.T, f *failoverService) (string, error) {
+	t.Helper()
+	resp, err := FetchNodeValue(context.Background(), f, "", "", "", "Plant.Area.Temp")
+	if err != nil {
+		return "", err
+	}
+	return string(resp.RItemList.Items[0].Value.InnerXML), nil
+}
+
+func TestFailoverServiceFailsOverAndBack(t *testing.T) {
+	f, primary, backup, now, switches := newTestFailover(config.FailbackAuto)
+	refused := &url.Error{Op: "Post", URL: "http://primary/opc", Err: errors.New("connection refused")}
+	primary.SetError(servicetest.OpRead, refused)
+
+	if value, err := readTemp(t, f); err != nil || value != "22.5" {
+		t.Fatalf("read after outage = %q, %v", value, err)
+	}
+	if len(*switches) != 1 || (*switches)[0].Event != "failover" || (*switches)[0].To != "http://backup/opc" {
+		t.Fatalf("switches = %+v", *switches)
+	}
+	if backup.Calls(servicetest.OpGetStatus) != 1 {
+		t.Fatalf("backup GetStatus calls = %d, want one health check", backup.Calls(servicetest.OpGetStatus))
+	}
+
+	primary.SetError(servicetest.OpRead, nil)
+	if value, _ := readTemp(t, f); value != "22.5" {
+		t.Fatalf("read before health interval = %q, want backup", value)
+	}
+	if primary.Calls(servicetest.OpGetStatus) != 0 {
+		t.Fatal("primary was health checked before the health interval")
+	}
+
+	*now = now.Add(2 * time.Minute)
+	if value, _ := readTemp(t, f); value != "21.5" {
+		t.Fatalf("read after failback = %q, want primary", value)
+	}
+	if len(*switches) != 2 || (*switches)[1].Event != "failback" {
+		t.Fatalf("switches = %+v", *switches)
+	}
+
+	primary.SetStatus(service.ServerStateCommFault, "")
+	if value, _ := readTemp(t, f); value != "22.5" {
+		t.Fatalf("read after commFault = %q, want backup", value)
+	}
+	if !strings.Contains((*switches)[2].Reason, "commFault") {
+		t.Fatalf("switches = %+v", *switches)
+	}
+}
+
+func TestFailoverServiceNeverFailsBack(t *testing.T) {
+	f, primary, _, now, switches := newTestFailover(config.FailbackNever)
+	primary.SetStatus(service.ServerStateFailed, "")
+	if value, _ := readTemp(t, f); value != "22.5" {
+		t.Fatalf("read after failed state = %q, want backup", value)
+	}
+	primary.SetStatus(service.ServerStateRunning, "")
+	*now = now.Add(time.Hour)
+	if value, _ := readTemp(t, f); value != "22.5" || len(*switches) != 1 {
+		t.Fatalf("read with failback never = %q, switches %+v", value, *switches)
+	}
+}
+
+func TestFailoverServiceDoesNotResendWrites(t *testing.T) {
+	f, primary, backup, _, _ := newTestFailover(config.FailbackAuto)
+	primary.SetError(servicetest.OpWrite, &url.Error{Op: "Post", URL: "http://primary/opc", Err: errors.New("connection reset")})
+	_, err := f.WriteContext(context.Background(), &service.Write{})
+	if err == nil || backup.Calls(servicetest.OpWrite) != 0 {
+		t.Fatalf("Write error = %v, backup writes = %d", err, backup.Calls(servicetest.OpWrite))
+	}
+	if f.active != 1 {
+		t.Fatalf("active endpoint = %d, want backup for later calls", f.active)
+	}
+}
+
+func TestFailoverServiceKeepsPrimaryWithoutHealthyBackup(t *testing.T) {
+	f, primary, backup, _, switches := newTestFailover(config.FailbackAuto)
+	primary.SetError(servicetest.OpRead, &url.Error{Op: "Post", URL: "http://primary/opc", Err: errors.New("connection refused")})
+	backup.SetStatus(service.ServerStateSuspended, "")
+	if _, err := readTemp(t, f); err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Fatalf("read error = %v", err)
+	}
+	if f.active != 0 || len(*switches) != 0 {
+		t.Fatalf("active = %d, switches %+v", f.active, *switches)
+	}
+
+	// Server faults are answers, not outages.
+	primary.SetError(servicetest.OpRead, errors.New("E_UNKNOWNITEMNAME"))
+	backup.SetStatus(service.ServerStateRunning, "")
+	if _, err := readTemp(t, f); err == nil || f.active != 0 {
+		t.Fatalf("read error = %v, active = %d", err, f.active)
+	}
+}
+
+// hangingService accepts calls and never answers them, like a server that
+// takes the connection and then hangs.
+type hangingService struct {
+	service.OpcXmlDASoap
+}
+
+func (hangingService) ReadContext(ctx context.Context, _ *service.Read) (*service.ReadResponse, error) {
+	<-ctx.Done()
+	return nil, &url.Error{Op: "Post", URL: "http://primary/opc", Err: ctx.Err()}
+}
+
+func (hangingService) GetStatusContext(ctx context.Context, _ *service.GetStatus) (*service.GetStatusResponse, error) {
+	<-ctx.Done()
+	return nil, &url.Error{Op: "Post", URL: "http://primary/opc", Err: ctx.Err()}
+}
+
+func TestFailoverServiceFailsOverFromHungEndpoint(t *testing.T) {
+	f, primary, _, _, switches := newTestFailover(config.FailbackAuto)
+	f.services[0] = hangingService{primary}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	resp, err := FetchNodeValue(ctx, f, "", "", "", "Plant.Area.Temp")
+	if err != nil || resp.RItemList.Items[0].Value.InnerXML != "22.5" {
+		t.Fatalf("read from hung primary = %+v, %v", resp, err)
+	}
+	if len(*switches) != 1 || (*switches)[0].Event != "failover" {
+		t.Fatalf("switches = %+v", *switches)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	f.active = 0
+	if _, err := FetchNodeValue(ctx, f, "", "", "", "Plant.Area.Temp"); err == nil || len(*switches) != 1 {
+		t.Fatalf("cancelled read = %v, switches %+v", err, *switches)
+	}
+}
+
+func TestFailoverServiceHealthCheckDoesNotBlockCalls(t *testing.T) {
+	f, primary, _, now, _ := newTestFailover(config.FailbackAuto)
+	f.services[0] = hangingService{primary}
+	f.probeTimeout = 300 * time.Millisecond
+	f.active = 1
+	*now = now.Add(time.Hour)
+
+	probed := make(chan struct{})
+	go func() {
+		defer close(probed)
+		_, _ = readTemp(t, f)
+	}()
+	for {
+		f.mu.Lock()
+		probing := f.probing
+		f.mu.Unlock()
+		if probing {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	start := time.Now()
+	if value, err := readTemp(t, f); err != nil || value != "22.5" {
+		t.Fatalf("read during health check = %q, %v", value, err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("read waited %s for the health check", elapsed)
+	}
+	<-probed
+}
+
+func TestWatchFailsOverToBackupEndpoint(t *testing.T) {
+	down := httptest.NewServer(nil)
+	down.Close()
+	_, backup := newFakeServer(t)
+	path := writeCLIConfig(t, `
+profiles:
+  pair:
+    endpoints: [`+down.URL+`, `+backup.URL+`]
+    failover:
+      failback: never
+`)
+	var out, errOut bytes.Buffer
+	code := NewApp(&out, &errOut).Run([]string{"watch", "--config", path, "--profile", "pair", "--item-name", "Plant.Area.Temp", "--interval", "10ms", "--duration", "35ms", "--format", "jsonl"})
+	if code != exitSuccess {
+		t.Fatalf("Run(watch) = %d, stderr %q", code, errOut.String())
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) < 2 || !strings.Contains(lines[0], `"event":"failover"`) || !strings.Contains(lines[0], backup.URL) || !strings.Contains(lines[1], "21.5") {
+		t.Fatalf("watch output:\n%s", out.String())
+	}
+}
@@ -0,0 +1,309 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultHARMaxBodyBytes caps each body captured by --dump-http-file.
+const DefaultHARMaxBodyBytes int64 = 1 << 20
+
+// harRoundTripper captures exchanges as HTTP Archive 1.2 entries. Each entry
+// is written over the closing brackets of the archive, which are then written
+// again after it, so the file is complete even when the command is
+// interrupted, and a long watch keeps neither the entries in memory nor
+// rewrites the whole file per exchange.
+type harRoundTripper struct {
+	base         http.RoundTripper
+	path         string
+	maxBodyBytes int64
+
+	mu      sync.Mutex
+	entries int
+}
+
+// harTrailer closes the entries array and the archive.
+const harTrailer = "\n    ]\n  }\n}\n"
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+	Comment     string         `json:"comment,omitempty"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type harContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// harTimings are in milliseconds; -1 marks a phase that did not happen,
+// such as DNS and connect on a reused connection.
+type harTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	SSL     float64 `json:"ssl"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// timings converts the trace into HAR phases for a request that started at
+// start and whose body was fully read at done.
+func (tt *requestTrace) timings(start, done time.Time) harTimings {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	span := func(from, to time.Time) float64 {
+		if from.IsZero() || to.IsZero() {
+			return -1
+		}
+		return milliseconds(to.Sub(from))
+	}
+	t := harTimings{
+		Blocked: -1,
+		DNS:     span(tt.dnsStart, tt.dnsDone),
+		Connect: span(tt.connectStart, tt.connectDone),
+		SSL:     span(tt.tlsStart, tt.tlsDone),
+		Send:    span(tt.gotConn, tt.wroteRequest),
+		Wait:    span(tt.wroteRequest, tt.first),
+		Receive: span(tt.first, done),
+	}
+	if !tt.gotConn.IsZero() {
+		connStart := tt.gotConn
+		for _, phase := range []time.Time{tt.dnsStart, tt.connectStart} {
+			if !phase.IsZero() && phase.Before(connStart) {
+				connStart = phase
+			}
+		}
+		t.Blocked = span(start, connStart)
+	}
+	// HAR counts the TLS handshake inside connect.
+	if t.Connect >= 0 && t.SSL >= 0 {
+		t.Connect += t.SSL
+	}
+	for _, phase := range []*float64{&t.Send, &t.Wait, &t.Receive} {
+		if *phase < 0 {
+			*phase = 0
+		}
+	}
+	return t
+}
+
+func newHARRoundTripper(base http.RoundTripper, path string, maxBodyBytes int64) (*harRoundTripper, error) {
+	rt := &harRoundTripper{base: base, path: path, maxBodyBytes: maxBodyBytes}
+	if err := rt.create(); err != nil {
+		return nil, fmt.Errorf("open HAR file: %w", err)
+	}
+	return rt, nil
+}
+
+func (rt *harRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	body, err := drainRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	req, times := withRequestTrace(req, nil, 0, start)
+
+	entry := harEntry{
+		StartedDateTime: start.Format(time.RFC3339Nano),
+		Request: harRequest{
+			Method:      req.Method,
+			URL:         req.URL.Redacted(),
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(req.Header),
+			QueryString: harQuery(req),
+			HeadersSize: -1,
+			BodySize:    int64(len(body)),
+		},
+	}
+	if body != nil {
+		text, comment := rt.capture(redactWSSecurity(body))
+		entry.Request.PostData = &harPostData{MimeType: req.Header.Get("Content-Type"), Text: text, Comment: comment}
+	}
+
+	resp, err := rt.base.RoundTrip(req)
+	if err != nil {
+		done := time.Now()
+		entry.Time = milliseconds(done.Sub(start))
+		entry.Timings = times.timings(start, done)
+		entry.Response = harResponse{Cookies: []harNameValue{}, Headers: []harNameValue{}, HeadersSize: -1, BodySize: -1, Comment: err.Error()}
+		entry.Comment = "request failed: " + err.Error()
+		rt.add(entry)
+		return nil, err
+	}
+	respBody, readErr := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	done := time.Now()
+
+	text, comment := rt.capture(respBody)
+	if readErr != nil {
+		comment = "body read failed: " + readErr.Error()
+	}
+	entry.Time = milliseconds(done.Sub(start))
+	entry.Timings = times.timings(start, done)
+	entry.ServerIPAddress = times.remoteAddr
+	entry.Response = harResponse{
+		Status:      resp.StatusCode,
+		StatusText:  http.StatusText(resp.StatusCode),
+		HTTPVersion: resp.Proto,
+		Cookies:     []harNameValue{},
+		Headers:     harHeaders(resp.Header),
+		Content:     harContent{Size: int64(len(respBody)), MimeType: resp.Header.Get("Content-Type"), Text: text, Comment: comment},
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    int64(len(respBody)),
+	}
+	rt.add(entry)
+	if readErr != nil {
+		return nil, readErr
+	}
+	return resp, nil
+}
+
+// capture returns body as HAR text, truncated to maxBodyBytes when that is
+// positive.
+func (rt *harRoundTripper) capture(body []byte) (string, string) {
+	if rt.maxBodyBytes > 0 && int64(len(body)) > rt.maxBodyBytes {
+		return string(body[:rt.maxBodyBytes]), fmt.Sprintf("body truncated to %d of %d bytes", rt.maxBodyBytes, len(body))
+	}
+	return string(body), ""
+}
+
+func (rt *harRoundTripper) add(entry harEntry) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if err := rt.appendLocked(entry); err != nil {
+		slog.Warn("write HAR entry", "path", rt.path, "err", err)
+	}
+}
+
+// create writes an archive without entries.
+func (rt *harRoundTripper) create() error {
+	creator, err := json.Marshal(harCreator{Name: appName, Version: "development"})
+	if err != nil {
+		return err
+	}
+	head := "{\n  \"log\": {\n    \"version\": \"1.2\",\n    \"creator\": " + string(creator) + ",\n    \"entries\": ["
+	return os.WriteFile(rt.path, []byte(head+harTrailer), 0o600)
+}
+
+// appendLocked writes entry in place of the trailer and restores it.
+func (rt *harRoundTripper) appendLocked(entry harEntry) error {
+	data, err := json.MarshalIndent(entry, "      ", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(rt.path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(-int64(len(harTrailer)), io.SeekEnd); err != nil {
+		f.Close()
+		return err
+	}
+	separator := "\n      "
+	if rt.entries > 0 {
+		separator = "," + separator
+	}
+	if _, err := f.WriteString(separator + string(data) + harTrailer); err != nil {
+		f.Close()
+		return err
+	}
+	rt.entries++
+	return f.Close()
+}
+
+func harHeaders(headers http.Header) []harNameValue {
+	redacted := redactHeaders(headers)
+	names := make([]string, 0, len(redacted))
+	for name := range redacted {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	out := []harNameValue{}
+	for _, name := range names {
+		for _, value := range redacted[name] {
+			out = append(out, harNameValue{Name: name, Value: value})
+		}
+	}
+	return out
+}
+
+func harQuery(req *http.Request) []harNameValue {
+	out := []harNameValue{}
+	query := req.URL.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range query[name] {
+			out = append(out, harNameValue{Name: name, Value: value})
+		}
+	}
+	return out
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"opc-xml-da-cli/service/servicetest"
+)
+
+// harLog is the archive layout, for decoding the files in tests.
+type harLog struct {
+	Log struct {
+		Version string     `json:"version"`
+		Creator harCreator `json:"creator"`
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+func TestDumpHTTPFileWritesHAR(t *testing.T) {
+	_, server := newFakeServer(t)
+	path := filepath.Join(t.TempDir(), "trace.har")
+	var out, errOut bytes.Buffer
+	code := NewApp(&out, &errOut).Run([]string{"read", "--endpoint", server.URL, "--item-name", "Plant.Area.Temp",
+		"--username", "user", "--password", "secret", "--dump-http-file", path, "--dump-http-max-body", "64"})
+	if code != exitSuccess {
+		t.Fatalf("Run(read --dump-http-file) = %d, stderr %q", code, errOut.String())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "dXNlcjpzZWNyZXQ") {
+		t.Fatalf("HAR file contains credentials:\n%s", data)
+	}
+	var archive harLog
+	if err := json.Unmarshal(data, &archive); err != nil {
+		t.Fatal(err)
+	}
+	if archive.Log.Version != "1.2" || len(archive.Log.Entries) != 1 {
+		t.Fatalf("HAR log = version %q, %d entries", archive.Log.Version, len(archive.Log.Entries))
+	}
+	entry := archive.Log.Entries[0]
+	if entry.Request.Method != "POST" || entry.Response.Status != 200 {
+		t.Fatalf("entry = %s %d", entry.Request.Method, entry.Response.Status)
+	}
+	var auth string
+	for _, header := range entry.Request.Headers {
+		if header.Name == "Authorization" {
+			auth = header.Value
+		}
+	}
+	if auth != "<redacted>" {
+		t.Fatalf("Authorization header = %q", auth)
+	}
+	if entry.Request.PostData == nil || len(entry.Request.PostData.Text) != 64 || !strings.Contains(entry.Request.PostData.Comment, "truncated") {
+		t.Fatalf("postData = %+v", entry.Request.PostData)
+	}
+	if entry.Response.Content.Size <= 64 || len(entry.Response.Content.Text) != 64 {
+		t.Fatalf("content = size %d, text %d bytes", entry.Response.Content.Size, len(entry.Response.Content.Text))
+	}
+	if entry.Timings.Connect < 0 || entry.Timings.Wait < 0 || entry.ServerIPAddress == "" {
+		t.Fatalf("timings = %+v, server %q", entry.Timings, entry.ServerIPAddress)
+	}
+}
+
+func TestDumpHTTPFileSharesTraceWithDumpHTTP(t *testing.T) {
+	_, server := newFakeServer(t)
+	path := filepath.Join(t.TempDir(), "trace.har")
+	var out, errOut bytes.Buffer
+	code := NewApp(&out, &errOut).Run([]string{"read", "--endpoint", server.URL, "--item-name", "Plant.Area.Temp",
+		"--dump-http", "--dump-http-file", path})
+	if code != exitSuccess {
+		t.Fatalf("Run(read --dump-http --dump-http-file) = %d, stderr %q", code, errOut.String())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var archive harLog
+	if err := json.Unmarshal(data, &archive); err != nil || len(archive.Log.Entries) != 1 {
+		t.Fatalf("HAR log = %d entries, %v", len(archive.Log.Entries), err)
+	}
+	if entry := archive.Log.Entries[0]; entry.Timings.Connect < 0 || entry.Timings.Wait < 0 || entry.ServerIPAddress == "" {
+		t.Fatalf("timings = %+v, server %q", entry.Timings, entry.ServerIPAddress)
+	}
+}
+
+func TestDumpHTTPFileAppendsEntries(t *testing.T) {
+	fake, server := newFakeServer(t)
+	path := filepath.Join(t.TempDir(), "watch.har")
+	var out, errOut bytes.Buffer
+	code := NewApp(&out, &errOut).Run([]string{"watch", "--endpoint", server.URL, "--item-name", "Plant.Area.Temp",
+		"--interval", "5ms", "--duration", "40ms", "--dump-http-file", path})
+	if code != exitSuccess {
+		t.Fatalf("Run(watch --dump-http-file) = %d, stderr %q", code, errOut.String())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var archive harLog
+	if err := json.Unmarshal(data, &archive); err != nil {
+		t.Fatalf("HAR file is not valid JSON: %v\n%s", err, data)
+	}
+	// A read cancelled when the duration ends is logged but never served.
+	if reads := fake.Calls(servicetest.OpRead); reads < 2 || len(archive.Log.Entries) < reads {
+		t.Fatalf("HAR log has %d entries for %d reads", len(archive.Log.Entries), reads)
+	}
+}
@@ -2,6 +2,7 @@ package cli
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"fmt"
 	"io"
@@ -9,16 +10,95 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptrace"
+	"regexp"
+	"sync"
 	"sync/atomic"
 	"time"
+
+	"opc-xml-da-cli/internal/config"
+	"opc-xml-da-cli/internal/httpauth"
 )
 
 // MaxDebugBodyBytes caps the number of bytes captured for HTTP debug logs.
 const MaxDebugBodyBytes int64 = 64 * 1024
 
 // NewDebugHTTPClient builds an HTTP client that logs request/response details.
-func NewDebugHTTPClient(httpTimeout, requestTimeout time.Duration) *http.Client {
+func NewDebugHTTPClient(httpTimeout, requestTimeout time.Duration, tlsConfig *tls.Config) *http.Client {
+	return &http.Client{
+		Transport: newLoggingRoundTripper(newBaseTransport(httpTimeout, tlsConfig)),
+		Timeout:   requestTimeout,
+	}
+}
+
+// newHTTPClient layers the TLS and http settings, challenge
+// authentication, and the debug, HAR, record, replay, and capture round
+// trippers that opts and the caller ask for. Authentication sits above the
+// dump layers so that each challenge leg is logged, and below recording so
+// that sessions hold only the final exchanges. Request compression sits
+// below the dump layers, which therefore show bodies uncompressed. The
+// client and its connection pool are shared by every call of a command.
+func newHTTPClient(opts commandOptions, replay *replayRoundTripper, capture *envelopeCapture) (*http.Client, error) {
+	tlsConfig, err := newTLSConfig(opts.TLS)
+	if err != nil {
+		return nil, err
+	}
+	challengeAuth := usesChallengeAuth(opts) && replay == nil
+	base := newBaseTransport(opts.HTTPTimeout, tlsConfig)
+	if err := configureTransport(base, opts.HTTP); err != nil {
+		return nil, err
+	}
+	var transport http.RoundTripper = base
+	if opts.HTTP.Compression == config.CompressionGzip {
+		transport = &gzipRoundTripper{base: transport}
+	}
+	if replay != nil {
+		transport = replay
+	}
+	if opts.DumpHTTPFile != "" {
+		har, err := newHARRoundTripper(transport, opts.DumpHTTPFile, opts.DumpHTTPMax)
+		if err != nil {
+			return nil, err
+		}
+		transport = har
+	}
+	if opts.DumpHTTP {
+		transport = newLoggingRoundTripper(transport)
+	}
+	transport = &requestOptionsRoundTripper{base: transport, headers: opts.HTTP.Headers, keepAlive: !opts.HTTP.DisableKeepAlives}
+	if challengeAuth {
+		if transport, err = httpauth.NewRoundTripper(transport, opts.Auth, opts.Username, opts.Password); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Record != "" {
+		recorder, err := newRecordingRoundTripper(transport, opts.Record)
+		if err != nil {
+			return nil, err
+		}
+		transport = recorder
+	}
+	if capture != nil {
+		capture.base = transport
+		transport = capture
+	}
+	return &http.Client{Transport: transport, Timeout: opts.RequestTimeout}, nil
+}
+
+// usesChallengeAuth reports whether opts select Digest or NTLM, which need a
+// round tripper instead of a static Basic Authorization header.
+func usesChallengeAuth(opts commandOptions) bool {
+	if opts.Username == "" {
+		return false
+	}
+	scheme, _ := httpauth.ParseScheme(opts.Auth)
+	return scheme == httpauth.SchemeDigest || scheme == httpauth.SchemeNTLM
+}
+
+func newBaseTransport(httpTimeout time.Duration, tlsConfig *tls.Config) *http.Transport {
 	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
 	if httpTimeout > 0 {
 		transport.DialContext = (&net.Dialer{
 			Timeout:   httpTimeout,
@@ -26,13 +106,14 @@ func NewDebugHTTPClient(httpTimeout, requestTimeout time.Duration) *http.Client
 		}).DialContext
 		transport.TLSHandshakeTimeout = httpTimeout
 	}
-	return &http.Client{
-		Transport: &loggingRoundTripper{
-			base:         transport,
-			maxBodyBytes: MaxDebugBodyBytes,
-			logger:       slog.Default().With("component", "net"),
-		},
-		Timeout: requestTimeout,
+	return transport
+}
+
+func newLoggingRoundTripper(base http.RoundTripper) *loggingRoundTripper {
+	return &loggingRoundTripper{
+		base:         base,
+		maxBodyBytes: MaxDebugBodyBytes,
+		logger:       slog.Default().With("component", "net"),
 	}
 }
 
@@ -67,7 +148,7 @@ func (rt *loggingRoundTripper) RoundTrip(req *http.Request) (*http.Response, err
 		_ = req.Body.Close()
 		req.Body = io.NopCloser(bytes.NewReader(bodyBytes))
 		reqBodySize = len(bodyBytes)
-		reqBodyPreview, reqBodyTruncated = bodyPreview(bodyBytes, rt.maxBodyBytes)
+		reqBodyPreview, reqBodyTruncated = bodyPreview(redactWSSecurity(bodyBytes), rt.maxBodyBytes)
 	}
 
 	logger.Info("http request",
@@ -81,26 +162,104 @@ func (rt *loggingRoundTripper) RoundTrip(req *http.Request) (*http.Response, err
 		"body_preview", reqBodyPreview,
 	)
 
-	trace := &httptrace.ClientTrace{
+	req, _ = withRequestTrace(req, logger, id, start)
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		logger.Info("http response error", "id", id, "err", err, "elapsed", time.Since(start))
+		return nil, err
+	}
+
+	respHeaders := redactHeaders(resp.Header)
+	logger.Info("http response",
+		"id", id,
+		"status", resp.Status,
+		"status_code", resp.StatusCode,
+		"headers", respHeaders,
+		"content_length", resp.ContentLength,
+		"elapsed", time.Since(start),
+	)
+
+	if resp.Body != nil && resp.Body != http.NoBody {
+		resp.Body = &loggedBody{
+			ReadCloser:   resp.Body,
+			maxBodyBytes: rt.maxBodyBytes,
+			logger:       logger,
+			id:           id,
+			start:        start,
+		}
+	}
+	return resp, nil
+}
+
+// requestTrace collects the httptrace events of one request. --dump-http
+// logs each event as it happens and --dump-http-file turns the recorded
+// times into HAR timings; with both on, they share one trace.
+type requestTrace struct {
+	logger *slog.Logger
+	id     uint64
+	start  time.Time
+
+	mu                           sync.Mutex
+	dnsStart, dnsDone            time.Time
+	connectStart, connectDone    time.Time
+	tlsStart, tlsDone            time.Time
+	gotConn, wroteRequest, first time.Time
+	remoteAddr                   string
+}
+
+type requestTraceKey struct{}
+
+// withRequestTrace attaches a trace to req, or returns the one an outer
+// round tripper already attached. A nil logger records without logging.
+func withRequestTrace(req *http.Request, logger *slog.Logger, id uint64, start time.Time) (*http.Request, *requestTrace) {
+	if trace, ok := req.Context().Value(requestTraceKey{}).(*requestTrace); ok {
+		return req, trace
+	}
+	trace := &requestTrace{logger: logger, id: id, start: start}
+	ctx := context.WithValue(req.Context(), requestTraceKey{}, trace)
+	return req.WithContext(httptrace.WithClientTrace(ctx, trace.clientTrace())), trace
+}
+
+func (t *requestTrace) mark(at *time.Time) {
+	t.mu.Lock()
+	if at.IsZero() {
+		*at = time.Now()
+	}
+	t.mu.Unlock()
+}
+
+func (t *requestTrace) log(msg string, args ...any) {
+	if t.logger != nil {
+		t.logger.Info(msg, append([]any{"id", t.id}, args...)...)
+	}
+}
+
+func (t *requestTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
 		DNSStart: func(info httptrace.DNSStartInfo) {
-			logger.Info("http trace dns start", "id", id, "host", info.Host)
+			t.mark(&t.dnsStart)
+			t.log("http trace dns start", "host", info.Host)
 		},
 		DNSDone: func(info httptrace.DNSDoneInfo) {
-			logger.Info("http trace dns done", "id", id, "addrs", formatAddrs(info.Addrs), "coalesced", info.Coalesced, "err", info.Err)
+			t.mark(&t.dnsDone)
+			t.log("http trace dns done", "addrs", formatAddrs(info.Addrs), "coalesced", info.Coalesced, "err", info.Err)
 		},
 		ConnectStart: func(network, addr string) {
-			logger.Info("http trace connect start", "id", id, "network", network, "addr", addr)
+			t.mark(&t.connectStart)
+			t.log("http trace connect start", "network", network, "addr", addr)
 		},
 		ConnectDone: func(network, addr string, err error) {
-			logger.Info("http trace connect done", "id", id, "network", network, "addr", addr, "err", err)
+			t.mark(&t.connectDone)
+			t.log("http trace connect done", "network", network, "addr", addr, "err", err)
 		},
 		TLSHandshakeStart: func() {
-			logger.Info("http trace tls handshake start", "id", id)
+			t.mark(&t.tlsStart)
+			t.log("http trace tls handshake start")
 		},
 		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
-			logger.Info(
+			t.mark(&t.tlsDone)
+			t.log(
 				"http trace tls handshake done",
-				"id", id,
 				"version", tlsVersion(state.Version),
 				"server_name", state.ServerName,
 				"negotiated_protocol", state.NegotiatedProtocol,
@@ -109,46 +268,28 @@ func (rt *loggingRoundTripper) RoundTrip(req *http.Request) (*http.Response, err
 			)
 		},
 		GotConn: func(info httptrace.GotConnInfo) {
-			logger.Info("http trace got conn", "id", id, "reused", info.Reused, "was_idle", info.WasIdle, "idle_time", info.IdleTime)
+			t.mark(&t.gotConn)
+			if info.Conn != nil {
+				if host, _, err := net.SplitHostPort(info.Conn.RemoteAddr().String()); err == nil {
+					t.mu.Lock()
+					t.remoteAddr = host
+					t.mu.Unlock()
+				}
+			}
+			t.log("http trace got conn", "reused", info.Reused, "was_idle", info.WasIdle, "idle_time", info.IdleTime)
 		},
 		WroteHeaders: func() {
-			logger.Info("http trace wrote headers", "id", id)
+			t.log("http trace wrote headers")
 		},
 		WroteRequest: func(info httptrace.WroteRequestInfo) {
-			logger.Info("http trace wrote request", "id", id, "err", info.Err)
+			t.mark(&t.wroteRequest)
+			t.log("http trace wrote request", "err", info.Err)
 		},
 		GotFirstResponseByte: func() {
-			logger.Info("http trace first response byte", "id", id, "elapsed", time.Since(start))
+			t.mark(&t.first)
+			t.log("http trace first response byte", "elapsed", time.Since(t.start))
 		},
 	}
-
-	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
-	resp, err := base.RoundTrip(req)
-	if err != nil {
-		logger.Info("http response error", "id", id, "err", err, "elapsed", time.Since(start))
-		return nil, err
-	}
-
-	respHeaders := redactHeaders(resp.Header)
-	logger.Info("http response",
-		"id", id,
-		"status", resp.Status,
-		"status_code", resp.StatusCode,
-		"headers", respHeaders,
-		"content_length", resp.ContentLength,
-		"elapsed", time.Since(start),
-	)
-
-	if resp.Body != nil && resp.Body != http.NoBody {
-		resp.Body = &loggedBody{
-			ReadCloser:   resp.Body,
-			maxBodyBytes: rt.maxBodyBytes,
-			logger:       logger,
-			id:           id,
-			start:        start,
-		}
-	}
-	return resp, nil
 }
 
 type loggedBody struct {
@@ -220,6 +361,14 @@ func isSensitiveHeader(key string) bool {
 	}
 }
 
+var wssePasswordPattern = regexp.MustCompile(`(<(?:[\w.-]+:)?Password\b[^>]*>)[^<]*(</(?:[\w.-]+:)?Password>)`)
+
+// redactWSSecurity blanks WS-Security UsernameToken passwords, plain or
+// digest, in a SOAP body.
+func redactWSSecurity(body []byte) []byte {
+	return wssePasswordPattern.ReplaceAll(body, []byte("${1}REDACTED${2}"))
+}
+
 func bodyPreview(body []byte, limit int64) (string, bool) {
 	if limit <= 0 {
 		return "", len(body) > 0
@@ -0,0 +1,415 @@
+package cli
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hooklift/gowsdl/soap"
+
+	"opc-xml-da-cli/internal/output"
+	"opc-xml-da-cli/service"
+)
+
+const defaultProxyListen = ":8090"
+
+// proxyEvent is one forwarded exchange as decoded by the proxy.
+type proxyEvent struct {
+	Time      time.Time   `json:"time"`
+	Client    string      `json:"client"`
+	Operation string      `json:"operation"`
+	Target    string      `json:"target,omitempty"`
+	Items     []proxyItem `json:"items,omitempty"`
+	Summary   string      `json:"summary,omitempty"`
+	Status    int         `json:"status"`
+	LatencyMS float64     `json:"latency_ms"`
+	Error     string      `json:"error,omitempty"`
+}
+
+type proxyItem struct {
+	ItemPath string `json:"item_path,omitempty"`
+	ItemName string `json:"item_name,omitempty"`
+	Value    string `json:"value,omitempty"`
+	Quality  string `json:"quality,omitempty"`
+	Result   string `json:"result,omitempty"`
+}
+
+// proxyExchange carries the captured request through the reverse proxy.
+type proxyExchange struct {
+	start time.Time
+	event proxyEvent
+	req   interface{}
+}
+
+type proxyExchangeKey struct{}
+
+// inspectingProxy forwards SOAP traffic to one upstream endpoint and logs
+// each exchange as it completes.
+type inspectingProxy struct {
+	upstream *url.URL
+	proxy    *httputil.ReverseProxy
+	format   string
+
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func (a *App) proxy(args []string) error {
+	listen := defaultProxyListen
+	upstream := ""
+	format := string(output.FormatText)
+	httpTimeout := defaultHTTPTimeout
+	fs := a.newFlagSet("proxy")
+	fs.StringVar(&listen, "listen", listen, "HTTP listen address for SOAP clients")
+	fs.StringVar(&upstream, "upstream", "", "OPC XML-DA endpoint URL that requests are forwarded to")
+	fs.StringVar(&format, "format", format, "log format: text or jsonl")
+	fs.DurationVar(&httpTimeout, "http-timeout", httpTimeout, "HTTP dial timeout for the upstream")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := validateProxyFormat(format); err != nil {
+		return err
+	}
+	p, err := newInspectingProxy(upstream, newBaseTransport(httpTimeout, nil), a.out, format)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{Addr: listen, Handler: p, ReadHeaderTimeout: 10 * time.Second}
+	fmt.Fprintf(a.err, "proxying %s; point clients at %s\n", p.upstream.Redacted(), simulateEndpoint(listen))
+	ctx, stop := interruptContext()
+	defer stop()
+	if err := listenAndServe(ctx, server); err != nil {
+		return fmt.Errorf("proxy listen %s: %w", listen, err)
+	}
+	return nil
+}
+
+func validateProxyFormat(format string) error {
+	switch output.NormaliseFormat(format) {
+	case output.FormatText, output.FormatJSONL:
+		return nil
+	default:
+		return fmt.Errorf("invalid output format %q; expected text or jsonl", format)
+	}
+}
+
+func newInspectingProxy(upstream string, transport http.RoundTripper, out io.Writer, format string) (*inspectingProxy, error) {
+	if upstream == "" {
+		return nil, fmt.Errorf("--upstream is required")
+	}
+	target, err := url.Parse(upstream)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, fmt.Errorf("--upstream must be an http or https URL, got %q", upstream)
+	}
+	p := &inspectingProxy{upstream: target, format: string(output.NormaliseFormat(format)), out: out}
+	p.proxy = &httputil.ReverseProxy{
+		Rewrite:        p.rewrite,
+		Transport:      transport,
+		ModifyResponse: p.inspectResponse,
+		ErrorHandler:   p.upstreamError,
+	}
+	return p, nil
+}
+
+func (p *inspectingProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	x := &proxyExchange{start: time.Now(), event: proxyEvent{Client: r.RemoteAddr}}
+	p.proxy.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), proxyExchangeKey{}, x)))
+}
+
+// rewrite sends every request to the upstream URL, whatever path the client
+// used, and decodes the SOAP request on the way.
+func (p *inspectingProxy) rewrite(pr *httputil.ProxyRequest) {
+	target := *p.upstream
+	pr.Out.URL = &target
+	pr.Out.Host = ""
+	pr.SetXForwarded()
+
+	x, _ := pr.In.Context().Value(proxyExchangeKey{}).(*proxyExchange)
+	if x == nil {
+		return
+	}
+	if pr.Out.Method != http.MethodPost {
+		x.event.Operation = pr.Out.Method
+		x.event.Target = pr.In.URL.RequestURI()
+		return
+	}
+	body, err := drainRequestBody(pr.Out)
+	if err != nil {
+		x.event.Error = "read request: " + err.Error()
+		return
+	}
+	operation, req, err := service.DecodeRequest(body)
+	x.event.Operation = operation
+	if err != nil {
+		x.event.Error = "decode request: " + err.Error()
+		return
+	}
+	x.req = req
+	x.event.Target, x.event.Items = describeProxyRequest(req)
+}
+
+func (p *inspectingProxy) inspectResponse(resp *http.Response) error {
+	x, _ := resp.Request.Context().Value(proxyExchangeKey{}).(*proxyExchange)
+	if x == nil {
+		return nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	x.event.Status = resp.StatusCode
+	if err != nil {
+		return err
+	}
+	if x.req != nil {
+		x.describeResponse(resp.Header, body)
+	}
+	p.log(x)
+	return nil
+}
+
+func (p *inspectingProxy) upstreamError(w http.ResponseWriter, r *http.Request, err error) {
+	if x, _ := r.Context().Value(proxyExchangeKey{}).(*proxyExchange); x != nil {
+		x.event.Status = http.StatusBadGateway
+		x.event.Error = "upstream: " + err.Error()
+		p.log(x)
+	}
+	http.Error(w, "upstream: "+err.Error(), http.StatusBadGateway)
+}
+
+func (p *inspectingProxy) log(x *proxyExchange) {
+	x.event.Time = x.start.UTC()
+	x.event.LatencyMS = milliseconds(time.Since(x.start))
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.format == string(output.FormatJSONL) {
+		_ = output.WriteJSONLine(p.out, x.event)
+		return
+	}
+	fmt.Fprintln(p.out, formatProxyEvent(x.event))
+}
+
+func formatProxyEvent(e proxyEvent) string {
+	parts := []string{e.Time.Format(time.RFC3339Nano), e.Client, e.Operation}
+	if e.Target != "" {
+		parts = append(parts, e.Target)
+	}
+	parts = append(parts, fmt.Sprintf("status=%d", e.Status), fmt.Sprintf("latency=%.1fms", e.LatencyMS))
+	for _, item := range e.Items {
+		name := item.ItemName
+		if item.ItemPath != "" {
+			name = item.ItemPath + "/" + name
+		}
+		switch {
+		case item.Result != "" && item.Value != "":
+			parts = append(parts, fmt.Sprintf("%s=%s(%s)", name, item.Value, item.Result))
+		case item.Result != "":
+			parts = append(parts, fmt.Sprintf("%s=%s", name, item.Result))
+		case item.Value != "":
+			parts = append(parts, fmt.Sprintf("%s=%s", name, item.Value))
+		default:
+			parts = append(parts, name)
+		}
+	}
+	if e.Summary != "" {
+		parts = append(parts, e.Summary)
+	}
+	if e.Error != "" {
+		parts = append(parts, fmt.Sprintf("error=%q", e.Error))
+	}
+	return strings.Join(parts, " ")
+}
+
+// describeProxyRequest lists the items and target a request refers to.
+func describeProxyRequest(req interface{}) (string, []proxyItem) {
+	switch req := req.(type) {
+	case *service.Browse:
+		return strings.Trim(req.ItemPath+"/"+req.ItemName, "/"), nil
+	case *service.GetProperties:
+		items := make([]proxyItem, 0, len(req.ItemIDs))
+		for _, id := range req.ItemIDs {
+			if id != nil {
+				items = append(items, proxyItem{ItemPath: id.ItemPath, ItemName: id.ItemName})
+			}
+		}
+		return "", items
+	case *service.Read:
+		if req.ItemList == nil {
+			return "", nil
+		}
+		items := make([]proxyItem, 0, len(req.ItemList.Items))
+		for _, item := range req.ItemList.Items {
+			if item != nil {
+				items = append(items, proxyItem{ItemPath: item.ItemPath, ItemName: item.ItemName})
+			}
+		}
+		return "", items
+	case *service.Write:
+		if req.ItemList == nil {
+			return "", nil
+		}
+		items := make([]proxyItem, 0, len(req.ItemList.Items))
+		for _, item := range req.ItemList.Items {
+			if item != nil {
+				items = append(items, proxyItem{ItemPath: item.ItemPath, ItemName: item.ItemName, Value: formatProxyValue(item.Value)})
+			}
+		}
+		return "", items
+	case *service.Subscribe:
+		if req.ItemList == nil {
+			return "", nil
+		}
+		items := make([]proxyItem, 0, len(req.ItemList.Items))
+		for _, item := range req.ItemList.Items {
+			if item != nil {
+				items = append(items, proxyItem{ItemPath: item.ItemPath, ItemName: item.ItemName})
+			}
+		}
+		return "", items
+	case *service.SubscriptionPolledRefresh:
+		return strings.Join(req.ServerSubHandles, ","), nil
+	case *service.SubscriptionCancel:
+		return req.ServerSubHandle, nil
+	default:
+		return "", nil
+	}
+}
+
+// describeResponse fills values, results, and errors from the response.
+// Reply items are matched to request items by position, as servers may
+// omit item names from replies.
+func (x *proxyExchange) describeResponse(header http.Header, body []byte) {
+	if strings.EqualFold(header.Get("Content-Encoding"), "gzip") {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			x.event.Error = "decode response: " + err.Error()
+			return
+		}
+		if body, err = io.ReadAll(zr); err != nil {
+			x.event.Error = "decode response: " + err.Error()
+			return
+		}
+	}
+	_, resp, err := service.DecodeResponse(body)
+	if err != nil {
+		var fault *soap.SOAPFault
+		if errors.As(err, &fault) {
+			x.event.Error = fmt.Sprintf("%s: %s", fault.Code, fault.String)
+			return
+		}
+		x.event.Error = "decode response: " + err.Error()
+		return
+	}
+	var opcErrors []*service.OPCError
+	switch resp := resp.(type) {
+	case *service.GetStatusResponse:
+		if resp.GetStatusResult != nil && resp.GetStatusResult.ServerState != nil {
+			x.event.Summary = fmt.Sprintf("state=%s", *resp.GetStatusResult.ServerState)
+		}
+	case *service.BrowseResponse:
+		opcErrors = resp.Errors
+		x.event.Summary = fmt.Sprintf("elements=%d more=%t", len(resp.Elements), resp.MoreElements)
+	case *service.GetPropertiesResponse:
+		opcErrors = resp.Errors
+		for i, list := range resp.PropertyLists {
+			if list != nil && list.ResultID != nil {
+				x.setItem(i, nil, string(*list.ResultID))
+			}
+		}
+	case *service.ReadResponse:
+		opcErrors = resp.Errors
+		if resp.RItemList != nil {
+			x.setItems(resp.RItemList.Items)
+		}
+	case *service.WriteResponse:
+		opcErrors = resp.Errors
+		if resp.RItemList != nil {
+			x.setItems(resp.RItemList.Items)
+		}
+	case *service.SubscribeResponse:
+		opcErrors = resp.Errors
+		x.event.Summary = "handle=" + resp.ServerSubHandle
+		if resp.RItemList != nil {
+			for i, item := range resp.RItemList.Items {
+				if item != nil {
+					x.setItem(i, item.ItemValue, "")
+				}
+			}
+		}
+	case *service.SubscriptionPolledRefreshResponse:
+		opcErrors = resp.Errors
+		for _, list := range resp.RItemList {
+			if list == nil {
+				continue
+			}
+			for _, item := range list.Items {
+				if item != nil {
+					x.event.Items = append(x.event.Items, proxyReplyItem(item))
+				}
+			}
+		}
+		if len(resp.InvalidServerSubHandles) > 0 {
+			x.event.Summary = "invalid=" + strings.Join(resp.InvalidServerSubHandles, ",")
+		}
+	}
+	if len(opcErrors) > 0 {
+		x.event.Error = formatOPCErrors(opcErrors)
+	}
+}
+
+func (x *proxyExchange) setItems(items []*service.ItemValue) {
+	for i, item := range items {
+		if item != nil {
+			x.setItem(i, item, "")
+		}
+	}
+}
+
+func (x *proxyExchange) setItem(i int, value *service.ItemValue, result string) {
+	reply := proxyItem{Result: result}
+	if value != nil {
+		reply = proxyReplyItem(value)
+	}
+	if i >= len(x.event.Items) {
+		x.event.Items = append(x.event.Items, reply)
+		return
+	}
+	item := &x.event.Items[i]
+	if reply.ItemName != "" {
+		item.ItemPath, item.ItemName = reply.ItemPath, reply.ItemName
+	}
+	if reply.Value != "" {
+		item.Value = reply.Value
+	}
+	item.Quality = reply.Quality
+	item.Result = reply.Result
+}
+
+func proxyReplyItem(item *service.ItemValue) proxyItem {
+	out := proxyItem{
+		ItemPath: item.ItemPath,
+		ItemName: item.ItemName,
+		Value:    formatProxyValue(item.Value),
+		Quality:  formatOPCQuality(item.Quality),
+	}
+	if item.ResultID != nil {
+		out.Result = string(*item.ResultID)
+	}
+	return out
+}
+
+func formatProxyValue(value service.AnyType) string {
+	if formatted := formatXMLDAValue(value); formatted != "<empty>" {
+		return formatted
+	}
+	return ""
+}
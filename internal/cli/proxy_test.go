@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"opc-xml-da-cli/service/servicetest"
+)
+
+func TestProxyLogsDecodedOperations(t *testing.T) {
+	fake, upstream := newFakeServer(t)
+	var log bytes.Buffer
+	p, err := newInspectingProxy(upstream.URL, http.DefaultTransport, &log, "jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := httptest.NewServer(p)
+	defer proxy.Close()
+
+	var out, errOut bytes.Buffer
+	code := NewApp(&out, &errOut).Run([]string{"read", "--endpoint", proxy.URL + "/any/path", "--item-name", "Plant.Area.Temp", "--format", "csv"})
+	if code != exitSuccess || !strings.Contains(out.String(), "21.5") {
+		t.Fatalf("Run(read via proxy) = %d, stdout %q, stderr %q", code, out.String(), errOut.String())
+	}
+	fake.SetError(servicetest.OpRead, errors.New("device offline"))
+	NewApp(&bytes.Buffer{}, &bytes.Buffer{}).Run([]string{"read", "--endpoint", proxy.URL, "--item-name", "Plant.Area.Temp"})
+
+	lines := strings.Split(strings.TrimSpace(log.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("proxy log:\n%s", log.String())
+	}
+	var ok, failed proxyEvent
+	if err := json.Unmarshal([]byte(lines[0]), &ok); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &failed); err != nil {
+		t.Fatal(err)
+	}
+	if ok.Operation != "Read" || ok.Status != 200 || len(ok.Items) != 1 || ok.Items[0].ItemName != "Plant.Area.Temp" || ok.Items[0].Value != "21.5" {
+		t.Fatalf("read event = %+v", ok)
+	}
+	if failed.Status != 500 || !strings.Contains(failed.Error, "device offline") || len(failed.Items) != 1 {
+		t.Fatalf("fault event = %+v", failed)
+	}
+}
+
+func TestProxyReportsUnreachableUpstream(t *testing.T) {
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+	var log bytes.Buffer
+	p, err := newInspectingProxy(dead.URL, http.DefaultTransport, &log, "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`<GetStatus xmlns="http://opcfoundation.org/webservices/XMLDA/1.0/"/>`)))
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	if line := log.String(); !strings.Contains(line, " GetStatus status=502 ") || !strings.Contains(line, "error=") {
+		t.Fatalf("proxy log = %q", line)
+	}
+}
+
+func TestProxyRequiresHTTPUpstream(t *testing.T) {
+	var errOut bytes.Buffer
+	code := NewApp(&bytes.Buffer{}, &errOut).Run([]string{"proxy", "--upstream", "server/OPC/DA"})
+	if code != exitConfigError {
+		t.Fatalf("Run(proxy) = %d, stderr %q", code, errOut.String())
+	}
+}
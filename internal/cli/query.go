@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"opc-xml-da-cli/internal/output"
+	"opc-xml-da-cli/internal/sink"
+)
+
+func (a *App) query(args []string) error {
+	var itemNames stringList
+	var itemPaths stringList
+	dbPath := ""
+	from := ""
+	to := ""
+	format := output.FormatCSV
+	fs := a.newFlagSet("query")
+	fs.StringVar(&dbPath, "db", "", "SQLite capture database written by watch --sink sqlite:<path>")
+	fs.StringVar(&from, "from", "", "start of the window (inclusive): RFC3339 time or a duration ago, such as 1h")
+	fs.StringVar(&to, "to", "", "end of the window (exclusive): RFC3339 time or a duration ago")
+	fs.Var(&itemNames, "item-name", "only export this item name; repeat for multiple items")
+	fs.Var(&itemPaths, "item-path", "only export this item path; repeat for multiple items")
+	fs.StringVar(&format, "format", format, "output format: csv, jsonl, or influx")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if dbPath == "" {
+		return fmt.Errorf("--db is required")
+	}
+	format = output.NormaliseFormat(format)
+	switch format {
+	case output.FormatCSV, output.FormatJSONL, output.FormatInflux:
+	default:
+		return fmt.Errorf("invalid output format %q; expected csv, jsonl, or influx", format)
+	}
+	now := time.Now()
+	var q sink.Query
+	var err error
+	if q.From, err = parseQueryTime(from, now); err != nil {
+		return fmt.Errorf("--from: %w", err)
+	}
+	if q.To, err = parseQueryTime(to, now); err != nil {
+		return fmt.Errorf("--to: %w", err)
+	}
+	items, err := readItemRefs(itemNames, itemPaths, "")
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		q.Items = append(q.Items, sink.QueryItem{ItemPath: item.ItemPath, ItemName: item.ItemName})
+	}
+	db, err := sink.OpenSQLiteReadOnly(dbPath)
+	if err != nil {
+		return fmt.Errorf("open capture database: %w", err)
+	}
+	defer db.Close()
+	return writeQuery(a.out, format, func(fn func(sink.Sample) error) error {
+		return sink.QuerySQLite(context.Background(), db, q, fn)
+	})
+}
+
+// writeQuery streams samples produced by each to w in the requested format.
+func writeQuery(w io.Writer, format string, each func(func(sink.Sample) error) error) error {
+	if format == output.FormatCSV {
+		if err := output.WriteCSV(w, queryHeaders(), nil); err != nil {
+			return fmt.Errorf("print query: %w", err)
+		}
+	}
+	err := each(func(sample sink.Sample) error {
+		var err error
+		switch format {
+		case output.FormatJSONL:
+			err = output.WriteJSONLine(w, sample)
+		case output.FormatInflux:
+			_, err = fmt.Fprintln(w, sink.InfluxLine("", sample))
+		default:
+			err = output.WriteCSVRows(w, [][]string{queryRow(sample)})
+		}
+		if err != nil {
+			return fmt.Errorf("print query: %w", err)
+		}
+		return nil
+	})
+	if err != nil && !strings.HasPrefix(err.Error(), "print query:") {
+		return fmt.Errorf("query: %w", err)
+	}
+	return err
+}
+
+func queryHeaders() []string {
+	return []string{"ItemPath", "ItemName", "Value", "NumericValue", "Quality", "ResultID", "Timestamp", "Received", "ServerState"}
+}
+
+func queryRow(sample sink.Sample) []string {
+	timestamp := ""
+	if !sample.Timestamp.IsZero() {
+		timestamp = sample.Timestamp.Format(time.RFC3339Nano)
+	}
+	numeric := ""
+	if sample.NumericValue != nil {
+		numeric = strconv.FormatFloat(*sample.NumericValue, 'g', -1, 64)
+	}
+	return []string{
+		sample.ItemPath,
+		sample.ItemName,
+		sample.Value,
+		numeric,
+		sample.Quality,
+		sample.ResultID,
+		timestamp,
+		sample.Received.Format(time.RFC3339Nano),
+		sample.ServerState,
+	}
+}
+
+// parseQueryTime accepts an RFC3339 timestamp, a YYYY-MM-DD date in local
+// time, or a duration that is subtracted from now. Empty input is the zero time.
+func parseQueryTime(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		if d < 0 {
+			d = -d
+		}
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q; expected RFC3339, YYYY-MM-DD, or a duration such as 1h", value)
+}
@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"opc-xml-da-cli/internal/sink"
+)
+
+func TestRunQueryExportsWindow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.db")
+	s, err := sink.Open("sqlite:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := s.Write(context.Background(), []sink.Sample{
+		{ItemName: "Temp", Value: "20", Quality: "good", Received: base},
+		{ItemName: "Temp", Value: "21", Quality: "good", Received: base.Add(time.Minute)},
+		{ItemName: "Temp", Value: "22", Quality: "good", Received: base.Add(2 * time.Minute)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var out, errOut bytes.Buffer
+	code := NewApp(&out, &errOut).Run([]string{"query", "--db", path,
+		"--from", base.Add(time.Minute).Format(time.RFC3339), "--to", base.Add(2 * time.Minute).Format(time.RFC3339)})
+	if code != exitSuccess {
+		t.Fatalf("Run(query) = %d, stderr %q", code, errOut.String())
+	}
+	want := "ItemPath,ItemName,Value,NumericValue,Quality,ResultID,Timestamp,Received,ServerState\n" +
+		",Temp,21,21,good,,,2026-03-01T12:01:00Z,\n"
+	if out.String() != want {
+		t.Fatalf("csv output = %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	code = NewApp(&out, &errOut).Run([]string{"query", "--db", path, "--item-name", "Temp", "--format", "jsonl"})
+	if code != exitSuccess {
+		t.Fatalf("Run(query --format jsonl) = %d, stderr %q", code, errOut.String())
+	}
+	if lines := strings.Count(out.String(), "\n"); lines != 3 {
+		t.Fatalf("jsonl output has %d lines, want 3: %q", lines, out.String())
+	}
+}
+
+func TestRunQueryExportsNumericValue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.db")
+	s, err := sink.Open("sqlite:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := s.Write(context.Background(), []sink.Sample{
+		{ItemName: "Temp", Value: "21.50", Quality: "good", Received: base},
+		{ItemName: "Mode", Value: "auto", Quality: "good", Received: base.Add(time.Second)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var out, errOut bytes.Buffer
+	if code := NewApp(&out, &errOut).Run([]string{"query", "--db", path}); code != exitSuccess {
+		t.Fatalf("Run(query) = %d, stderr %q", code, errOut.String())
+	}
+	want := "ItemPath,ItemName,Value,NumericValue,Quality,ResultID,Timestamp,Received,ServerState\n" +
+		",Temp,21.50,21.5,good,,,2026-03-01T12:00:00Z,\n" +
+		",Mode,auto,,good,,,2026-03-01T12:00:01Z,\n"
+	if out.String() != want {
+		t.Fatalf("csv output = %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	if code := NewApp(&out, &errOut).Run([]string{"query", "--db", path, "--format", "jsonl"}); code != exitSuccess {
+		t.Fatalf("Run(query --format jsonl) = %d, stderr %q", code, errOut.String())
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"numeric_value":21.5`) || strings.Contains(lines[1], "numeric_value") {
+		t.Fatalf("jsonl output = %q, want numeric_value on the numeric sample only", out.String())
+	}
+}
+
+func TestRunQueryRequiresExistingDatabase(t *testing.T) {
+	var out, errOut bytes.Buffer
+	path := filepath.Join(t.TempDir(), "missing.db")
+	code := NewApp(&out, &errOut).Run([]string{"query", "--db", path})
+	if code != exitConfigError {
+		t.Fatalf("Run(query missing db) = %d, want %d", code, exitConfigError)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("query created %s: %v", path, err)
+	}
+}
+
+func TestParseQueryTime(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	got, err := parseQueryTime("90m", now)
+	if err != nil || !got.Equal(now.Add(-90*time.Minute)) {
+		t.Fatalf("parseQueryTime(90m) = %v, %v", got, err)
+	}
+	if _, err := parseQueryTime("yesterday", now); err == nil {
+		t.Fatal("parseQueryTime accepted an invalid time")
+	}
+}
@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"opc-xml-da-cli/service"
+)
+
+func TestReadWithQuirksPreset(t *testing.T) {
+	const opcNamespace = `xmlns="http://opcfoundation.org/webservices/XMLDA/1.0/"`
+	server, _ := newCountingServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.HasPrefix(r.Header.Get("SOAPAction"), `"`) {
+				http.Error(w, "want a quoted SOAPAction", http.StatusBadRequest)
+				return
+			}
+			rec := httptest.NewRecorder()
+			next.ServeHTTP(rec, r)
+			w.Header().Set("Content-Type", rec.Header().Get("Content-Type"))
+			w.WriteHeader(rec.Code)
+			_, _ = w.Write(bytes.ReplaceAll(rec.Body.Bytes(), []byte(opcNamespace), []byte(`xmlns="urn:vendor"`)))
+		})
+	})
+	path := writeCLIConfig(t, "quirks:\n  preset: soap11-strict\n  lenient_namespaces: true\n")
+	var out, errOut bytes.Buffer
+	code := NewApp(&out, &errOut).Run([]string{"read", "--config", path, "--endpoint", server.URL, "--item-name", "Plant.Area.Temp"})
+	if code != exitSuccess || !strings.Contains(out.String(), "21.5") {
+		t.Fatalf("Run(read) = %d, stdout %q, stderr %q", code, out.String(), errOut.String())
+	}
+
+	path = writeCLIConfig(t, "quirks:\n  preset: acme\n")
+	if code := NewApp(&out, &errOut).Run([]string{"read", "--config", path, "--endpoint", server.URL, "--item-name", "Plant.Area.Temp"}); code != exitConfigError {
+		t.Fatalf("Run(read) with unknown preset = %d, want config error", code)
+	}
+}
+
+// echoingBrowse answers every Browse with the same continuation point.
+type echoingBrowse struct {
+	service.OpcXmlDASoap
+	calls int
+}
+
+func (e *echoingBrowse) BrowseContext(context.Context, *service.Browse) (*service.BrowseResponse, error) {
+	e.calls++
+	return &service.BrowseResponse{Elements: []*service.BrowseElement{{Name: "Tag"}}, ContinuationPoint: "cp", MoreElements: true}, nil
+}
+
+func TestBrowseStopsOnRepeatedContinuationPoint(t *testing.T) {
+	svc := &echoingBrowse{}
+	elements, err := fetchBrowseElements(context.Background(), svc, "", "", "", "Plant")
+	if err != nil || len(elements) != 2 || svc.calls != 2 {
+		t.Fatalf("fetchBrowseElements() = %d elements over %d calls, %v", len(elements), svc.calls, err)
+	}
+}
@@ -15,6 +15,14 @@ func FetchNodeValue(ctx context.Context, svc service.OpcXmlDASoap, locale, clien
 	if itemPath == "" && itemName == "" {
 		return nil, errors.New("read requires an item path or item name")
 	}
+	return FetchNodeValues(ctx, svc, locale, clientHandle, []itemRef{{ItemPath: itemPath, ItemName: itemName}})
+}
+
+// FetchNodeValues requests the current values of several OPC items in one Read.
+func FetchNodeValues(ctx context.Context, svc service.OpcXmlDASoap, locale, clientHandle string, items []itemRef) (*service.ReadResponse, error) {
+	if len(items) == 0 {
+		return nil, errors.New("read requires at least one item")
+	}
 	options := &service.RequestOptions{
 		ReturnErrorText:      true,
 		ReturnDiagnosticInfo: true,
@@ -24,16 +32,16 @@ func FetchNodeValue(ctx context.Context, svc service.OpcXmlDASoap, locale, clien
 		ClientRequestHandle:  clientHandle,
 		LocaleID:             locale,
 	}
+	requestItems := make([]*service.ReadRequestItem, 0, len(items))
+	for _, item := range items {
+		if item.ItemPath == "" && item.ItemName == "" {
+			return nil, errors.New("read requires an item path or item name")
+		}
+		requestItems = append(requestItems, &service.ReadRequestItem{ItemPath: item.ItemPath, ItemName: item.ItemName})
+	}
 	req := &service.Read{
-		Options: options,
-		ItemList: &service.ReadRequestItemList{
-			Items: []*service.ReadRequestItem{
-				{
-					ItemPath: itemPath,
-					ItemName: itemName,
-				},
-			},
-		},
+		Options:  options,
+		ItemList: &service.ReadRequestItemList{Items: requestItems},
 	}
 	return svc.ReadContext(ctx, req)
 }
@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"opc-xml-da-cli/service/servicetest"
+)
+
+func newFakeServer(t *testing.T) (*servicetest.Fake, *servicetest.Server) {
+	t.Helper()
+	fake := servicetest.New()
+	fake.SetValue("Plant.Area.Temp", 21.5)
+	fake.SetValue("Plant.Area.Running", true)
+	fake.SetValue("Plant.Line.Count", 7)
+	server := servicetest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func TestRunReadEndToEnd(t *testing.T) {
+	_, server := newFakeServer(t)
+	var out, errOut bytes.Buffer
+	code := NewApp(&out, &errOut).Run([]string{"read", "--endpoint", server.URL, "--item-name", "Plant.Area.Temp", "--item-name", "Plant.Line.Count", "--format", "csv"})
+	if code != exitSuccess {
+		t.Fatalf("Run(read) = %d, stderr %q", code, errOut.String())
+	}
+	for _, want := range []string{"Plant.Area.Temp,21.5,", "Plant.Line.Count,7,"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("read output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRunReadShowsItemResult(t *testing.T) {
+	fake, server := newFakeServer(t)
+	fake.SetItemResult("Plant.Area.Broken", "E_BADTYPE")
+	var out, errOut bytes.Buffer
+	code := NewApp(&out, &errOut).Run([]string{"read", "--endpoint", server.URL, "--item-name", "Plant.Area.Broken", "--format", "json"})
+	if code != exitSuccess {
+		t.Fatalf("Run(read) = %d, stderr %q", code, errOut.String())
+	}
+	if !strings.Contains(out.String(), "E_BADTYPE") {
+		t.Fatalf("read output missing result id:\n%s", out.String())
+	}
+}
+
+func TestRunReadFailsWhenServerFails(t *testing.T) {
+	fake, server := newFakeServer(t)
+	fake.SetError(servicetest.OpRead, errors.New("device offline"))
+	var out, errOut bytes.Buffer
+	code := NewApp(&out, &errOut).Run([]string{"read", "--endpoint", server.URL, "--item-name", "Plant.Area.Temp"})
+	if code == exitSuccess || !strings.Contains(errOut.String(), "device offline") {
+		t.Fatalf("Run(read) = %d, stderr %q", code, errOut.String())
+	}
+}
+
+// runUntilReads runs a command that polls until interrupted and sends the
+// test process Ctrl-C once fake has received reads Read calls, so tests do
+// not depend on how many polls fit in a fixed duration.
+func runUntilReads(t *testing.T, fake *servicetest.Fake, reads int, args ...string) (int, string, string) {
+	t.Helper()
+	done := make(chan int, 1)
+	var out, errOut bytes.Buffer
+	go func() { done <- NewApp(&out, &errOut).Run(args) }()
+	deadline := time.Now().Add(5 * time.Second)
+	for fake.Calls(servicetest.OpRead) < reads {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d reads after 5s, want %d", fake.Calls(servicetest.OpRead), reads)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	self, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := self.Signal(os.Interrupt); err != nil {
+		t.Skipf("cannot interrupt the test process: %v", err)
+	}
+	select {
+	case code := <-done:
+		return code, out.String(), errOut.String()
+	case <-time.After(5 * time.Second):
+		t.Fatal("command did not stop on Ctrl-C")
+		return 0, "", ""
+	}
+}
+
+func TestRunWatchEndToEnd(t *testing.T) {
+	fake, server := newFakeServer(t)
+	code, out, errOut := runUntilReads(t, fake, 3, "watch", "--endpoint", server.URL, "--item-name", "Plant.Area.Temp", "--interval", "1ms", "--format", "jsonl")
+	if code != exitSuccess {
+		t.Fatalf("Run(watch) = %d, stderr %q", code, errOut)
+	}
+	// The read in flight when Ctrl-C arrives is cancelled, not printed.
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) < 2 || !strings.Contains(lines[0], "21.5") {
+		t.Fatalf("watch output:\n%s", out)
+	}
+	if got := fake.Calls(servicetest.OpRead); got < len(lines) {
+		t.Fatalf("Calls(Read) = %d, want at least one per line (%d)", got, len(lines))
+	}
+}
+
+func TestBrowseOpcTreeFollowsContinuationPoints(t *testing.T) {
+	fake, _ := newFakeServer(t)
+	fake.SetPageSize(1)
+	var out bytes.Buffer
+	if err := BrowseOpcTree(context.Background(), &out, fake, "", "", "", "", 3); err != nil {
+		t.Fatalf("BrowseOpcTree() error = %v", err)
+	}
+	want := "<root>\n  Plant/\n    Area/\n      Running\n      Temp\n    Line/\n      Count\n"
+	if out.String() != want {
+		t.Fatalf("BrowseOpcTree() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestTUIBackendAgainstFake(t *testing.T) {
+	fake, server := newFakeServer(t)
+	backend := &xmlDATUIBackend{svc: server.Service()}
+	children, err := backend.Children(context.Background(), tuiNode{ItemName: "Plant.Area"})
+	if err != nil || len(children) != 2 || children[0].Label != "Running" {
+		t.Fatalf("Children() = %+v, %v", children, err)
+	}
+	value, err := backend.Read(context.Background(), children[1])
+	if err != nil || !strings.HasPrefix(value.Value, "21.5") {
+		t.Fatalf("Read() = %+v, %v", value, err)
+	}
+
+	fake.SetError(servicetest.OpBrowse, errors.New("browse unavailable"))
+	if _, err := backend.Children(context.Background(), tuiNode{ItemName: "Plant"}); err == nil {
+		t.Fatal("Children() succeeded while Browse fails")
+	}
+}
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"opc-xml-da-cli/service/servicetest"
+)
+
+func TestRecordThenReplayRead(t *testing.T) {
+	fake := servicetest.New()
+	fake.SetValue("Plant.Area.Temp", 21.5)
+	server := servicetest.NewServer(fake)
+	session := filepath.Join(t.TempDir(), "session.jsonl")
+
+	var recorded, errOut bytes.Buffer
+	args := []string{"read", "--item-name", "Plant.Area.Temp", "--format", "csv", "--username", "user", "--password", "secret"}
+	code := NewApp(&recorded, &errOut).Run(append(args, "--endpoint", server.URL, "--record", session))
+	server.Close()
+	if code != exitSuccess {
+		t.Fatalf("Run(read --record) = %d, stderr %q", code, errOut.String())
+	}
+
+	data, err := os.ReadFile(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret") || strings.Contains(string(data), "dXNlcjpzZWNyZXQ") {
+		t.Fatalf("session file contains credentials:\n%s", data)
+	}
+	var entry recordEntry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	if !scanner.Scan() {
+		t.Fatal("session file is empty")
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(entry.SOAPAction, "/Read") || entry.Status != 200 || !strings.Contains(entry.ResponseBody, "21.5") {
+		t.Fatalf("recorded entry = %+v", entry)
+	}
+
+	// The server is gone and no endpoint is given: replay must answer alone.
+	var replayed bytes.Buffer
+	errOut.Reset()
+	code = NewApp(&replayed, &errOut).Run(append(args, "--replay", session))
+	if code != exitSuccess {
+		t.Fatalf("Run(read --replay) = %d, stderr %q", code, errOut.String())
+	}
+	if replayed.String() != recorded.String() {
+		t.Fatalf("replayed output %q, recorded %q", replayed.String(), recorded.String())
+	}
+
+	errOut.Reset()
+	code = NewApp(&bytes.Buffer{}, &errOut).Run([]string{"read", "--item-name", "Plant.Other", "--replay", session})
+	if code == exitSuccess || !strings.Contains(errOut.String(), "no recorded response") {
+		t.Fatalf("Run(read unmatched --replay) = %d, stderr %q", code, errOut.String())
+	}
+}
+
+func TestRecordAndReplayAreExclusive(t *testing.T) {
+	var errOut bytes.Buffer
+	dir := t.TempDir()
+	code := NewApp(&bytes.Buffer{}, &errOut).Run([]string{"status", "--endpoint", "http://localhost:1", "--record", filepath.Join(dir, "a.jsonl"), "--replay", filepath.Join(dir, "b.jsonl")})
+	if code != exitConfigError {
+		t.Fatalf("Run(status --record --replay) = %d, want %d", code, exitConfigError)
+	}
+}
+
+func TestReplayKeyIgnoresFormatting(t *testing.T) {
+	compact := replayKey("Read", []byte(`<a><b x="1">v</b></a>`))
+	pretty := replayKey("Read", []byte("<a>\n  <b x=\"1\">v</b>\n</a>\n"))
+	if compact != pretty {
+		t.Fatalf("replayKey differs: %q vs %q", compact, pretty)
+	}
+}
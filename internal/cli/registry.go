@@ -2,6 +2,10 @@ package cli
 
 import "github.com/DishanRajapaksha/industrial-cli-kit/command"
 
+// connectionGlobalFlags lists the globals accepted by commands that connect to
+// a server but do not take --format.
+var connectionGlobalFlags = []string{"config", "profile", "endpoint", "verbose", "debug", "dump-http", "dump-http-file", "dump-http-max-body", "record", "replay", "locale", "client-handle", "http-timeout", "timeout", "retry-attempts", "username", "password", "password-stdin", "auth", "tls-ca-file", "tls-cert-file", "tls-key-file", "tls-server-name", "tls-min-version", "tls-insecure-skip-verify", "tls-pin"}
+
 var cliRegistry = command.Registry{
 	Binary: appName,
 	GlobalFlags: []command.Flag{
@@ -12,29 +16,93 @@ var cliRegistry = command.Registry{
 		{Name: "verbose", Summary: "print request decisions"},
 		{Name: "debug", Summary: "enable debug logging"},
 		{Name: "dump-http", Summary: "dump HTTP requests and responses"},
+		{Name: "dump-http-file", TakesValue: true, Summary: "write HTTP exchanges to a HAR file"},
+		{Name: "dump-http-max-body", TakesValue: true, Summary: "cap bodies kept in the HAR file"},
+		{Name: "record", TakesValue: true, Summary: "record SOAP traffic to a session file"},
+		{Name: "replay", TakesValue: true, Summary: "replay SOAP responses from a session file"},
 		{Name: "locale", TakesValue: true, Summary: "requested locale"},
 		{Name: "client-handle", TakesValue: true, Summary: "client handle"},
 		{Name: "http-timeout", TakesValue: true, Summary: "HTTP transport timeout"},
 		{Name: "timeout", TakesValue: true, Summary: "request timeout"},
+		{Name: "retry-attempts", TakesValue: true, Summary: "tries per idempotent request"},
 		{Name: "username", TakesValue: true, Summary: "HTTP username"},
 		{Name: "password", TakesValue: true, Summary: "HTTP password"},
+		{Name: "password-stdin", Summary: "read the password from stdin"},
+		{Name: "auth", TakesValue: true, Summary: "auth scheme: basic, digest, ntlm, wsse, or wsse-digest"},
+		{Name: "tls-ca-file", TakesValue: true, Summary: "trusted CA bundle"},
+		{Name: "tls-cert-file", TakesValue: true, Summary: "client certificate"},
+		{Name: "tls-key-file", TakesValue: true, Summary: "client private key"},
+		{Name: "tls-server-name", TakesValue: true, Summary: "server name to verify"},
+		{Name: "tls-min-version", TakesValue: true, Summary: "minimum TLS version"},
+		{Name: "tls-insecure-skip-verify", Summary: "skip certificate verification"},
+		{Name: "tls-pin", TakesValue: true, Summary: "SHA-256 public key pin"},
 	},
 	Commands: []command.Command{
-		{Name: "status", Summary: "Get server status"},
+		{Name: "status", Summary: "Get server status", Flags: registryFlags("watch", "interval", "duration", "sink")},
 		{Name: "browse", Summary: "Browse items", Flags: registryFlags("item-name", "item-path", "depth")},
 		{Name: "tui", Summary: "Browse items interactively", Flags: registryFlags("item-name", "item-path", "interval")},
 		{Name: "read", Summary: "Read item values", Flags: registryFlags("item-name", "item-path", "items")},
-		{Name: "watch", Summary: "Poll item values", Flags: registryFlags("item-name", "item-path", "items", "interval", "duration")},
+		{Name: "watch", Summary: "Poll item values", Flags: registryFlags("item-name", "item-path", "items", "interval", "duration", "sink")},
+		{
+			Name:        "exporter",
+			Summary:     "Serve item values as Prometheus metrics",
+			Flags:       registryFlags("listen", "item-name", "item-path", "items", "interval"),
+			GlobalFlags: connectionGlobalFlags,
+		},
+		{
+			Name:        "serve",
+			Summary:     "Serve a JSON HTTP API and live value stream",
+			Flags:       registryFlags("listen", "allow-origin", "stream-interval", "yes", "dry-run"),
+			GlobalFlags: connectionGlobalFlags,
+		},
+		{
+			Name:        "query",
+			Summary:     "Export samples captured by a SQLite sink",
+			Flags:       registryFlags("db", "from", "to", "item-name", "item-path"),
+			GlobalFlags: []string{"format"},
+		},
+		{
+			Name:        "simulate",
+			Summary:     "Run a simulated OPC XML-DA server",
+			Flags:       registryFlags("listen", "model"),
+			GlobalFlags: []string{},
+		},
+		{
+			Name:        "call",
+			Summary:     "Send a raw OPC XML-DA operation",
+			Flags:       registryFlags("operation", "body", "raw"),
+			GlobalFlags: connectionGlobalFlags,
+		},
+		{
+			Name:        "proxy",
+			Summary:     "Forward SOAP traffic and log decoded operations",
+			Flags:       registryFlags("listen", "upstream", "http-timeout"),
+			GlobalFlags: []string{"format"},
+		},
 		{
 			Name:        "test-connection",
 			Summary:     "Run connection diagnostics",
-			GlobalFlags: []string{"config", "profile", "endpoint", "verbose", "debug", "dump-http", "locale", "client-handle", "http-timeout", "timeout", "username", "password"},
+			GlobalFlags: connectionGlobalFlags,
 		},
 		{
 			Name:        "validate-config",
 			Summary:     "Validate local config",
+			Flags:       registryFlags("sources"),
 			GlobalFlags: []string{"config", "profile"},
 		},
+		{
+			Name:        "config",
+			Summary:     "Show, get, set, and describe config file values",
+			LeadingArgs: 1,
+			Subcommands: []command.Command{
+				{Name: "show", Summary: "Print the effective config", Flags: registryFlags("profile", "sources")},
+				{Name: "profiles", Summary: "List profiles"},
+				{Name: "get", Summary: "Print one value from the config file", LeadingArgs: 1},
+				{Name: "set", Summary: "Set one value in the config file, keeping comments", LeadingArgs: 2},
+				{Name: "schema", Summary: "Print the config file JSON Schema"},
+			},
+			GlobalFlags: []string{"config"},
+		},
 		{Name: "init-config", Summary: "Write a starter YAML config", Flags: registryFlags("output", "force"), GlobalFlags: []string{}},
 		{Name: "completions", Summary: "Generate shell completion scripts", LeadingArgs: 1, GlobalFlags: []string{}},
 		{Name: "help", Summary: "Print help", GlobalFlags: []string{}},
@@ -42,10 +110,13 @@ var cliRegistry = command.Registry{
 	},
 }
 
+// registryBoolFlags lists command flags that do not take a value.
+var registryBoolFlags = map[string]bool{"force": true, "watch": true, "yes": true, "dry-run": true, "raw": true, "sources": true}
+
 func registryFlags(names ...string) []command.Flag {
 	flags := make([]command.Flag, 0, len(names))
 	for _, name := range names {
-		flags = append(flags, command.Flag{Name: name, TakesValue: name != "force"})
+		flags = append(flags, command.Flag{Name: name, TakesValue: !registryBoolFlags[name]})
 	}
 	return flags
 }
@@ -8,12 +8,21 @@ func (a *App) writeRegistryUsage() {
 		Usage: []string{"opc-xml-da-cli [global flags] <command> [flags]"},
 		Examples: []string{
 			"opc-xml-da-cli status --profile local",
+			"opc-xml-da-cli status --profile local --watch --sink webhooks://alerts.example.com/opc",
 			"opc-xml-da-cli browse --profile local --item-name Plant --depth 2",
 			"opc-xml-da-cli tui --profile local --item-name Plant --interval 1s",
 			"opc-xml-da-cli read --profile local --item-name Plant.Temperature --format json",
 			"opc-xml-da-cli watch --profile local --item-name Plant.Temperature --interval 1s --format jsonl",
+			"opc-xml-da-cli exporter --profile local --listen :9108 --items items.txt",
+			"opc-xml-da-cli serve --profile local --listen :8080",
+			"opc-xml-da-cli query --db capture.db --from 1h --format jsonl",
+			"opc-xml-da-cli simulate --listen :8081 --model model.yaml",
+			"opc-xml-da-cli call --profile local --operation Read --body request.xml --raw",
+			"opc-xml-da-cli proxy --listen :8090 --upstream http://server/OPC/DA --format jsonl",
 			"opc-xml-da-cli test-connection --profile local",
 			"opc-xml-da-cli validate-config --profile local",
+			"opc-xml-da-cli config set profiles.site-a.endpoint http://192.168.1.50/OPC/DA",
+			"opc-xml-da-cli config schema > config.schema.json",
 			"opc-xml-da-cli init-config --output site.yaml",
 			"opc-xml-da-cli completions zsh",
 		},
@@ -12,8 +12,8 @@ import (
 
 func TestRegistryMatchesDispatcher(t *testing.T) {
 	dispatched := []string{
-		"status", "browse", "tui", "read", "watch", "test-connection",
-		"validate-config", "init-config", "completions", "help", "version",
+		"status", "browse", "tui", "read", "watch", "exporter", "serve", "query", "simulate", "proxy", "call", "test-connection",
+		"validate-config", "config", "init-config", "completions", "help", "version",
 	}
 	registered := map[string]bool{}
 	for _, registeredCommand := range cliRegistry.Commands {
@@ -0,0 +1,183 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/hooklift/gowsdl/soap"
+
+	"opc-xml-da-cli/internal/config"
+	"opc-xml-da-cli/service"
+)
+
+// retryService retries the idempotent calls of the service it wraps,
+// GetStatus, GetProperties, Browse, and Read, after transient failures:
+// transport errors, HTTP 5xx responses that are not SOAP faults, and
+// replies reporting a commFault server state. Writes and subscription
+// calls pass through untouched. Waits between attempts grow exponentially
+// with jitter and end early when the call's context does.
+type retryService struct {
+	service.OpcXmlDASoap
+	attempts       int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	// jitter picks the wait before a retry from the nominal backoff.
+	jitter func(time.Duration) time.Duration
+	sleep  func(context.Context, time.Duration) error
+}
+
+// withRetry wraps svc when retries are configured.
+func withRetry(svc service.OpcXmlDASoap, cfg config.RetryConfig) service.OpcXmlDASoap {
+	if cfg.MaxAttempts <= 1 {
+		return svc
+	}
+	r := &retryService{
+		OpcXmlDASoap:   svc,
+		attempts:       cfg.MaxAttempts,
+		initialBackoff: cfg.InitialBackoff,
+		maxBackoff:     cfg.MaxBackoff,
+		jitter:         equalJitter,
+		sleep:          sleepContext,
+	}
+	if r.initialBackoff == 0 {
+		r.initialBackoff = config.DefaultRetryInitialBackoff
+	}
+	if r.maxBackoff == 0 {
+		r.maxBackoff = config.DefaultRetryMaxBackoff
+	}
+	return r
+}
+
+// backoff returns the nominal wait after the given failed attempt.
+func (r *retryService) backoff(attempt int) time.Duration {
+	wait := r.initialBackoff
+	for i := 1; i < attempt && wait < r.maxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, r.maxBackoff)
+}
+
+// equalJitter waits at least half of d, so retries from many clients spread
+// out without collapsing to zero.
+func equalJitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	return d/2 + rand.N(d/2)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func retryCall[T any](ctx context.Context, r *retryService, op string, call func() (T, *service.ReplyBase, error)) (T, error) {
+	for attempt := 1; ; attempt++ {
+		resp, reply, err := call()
+		reason := retryReason(reply, err)
+		if reason == "" || attempt >= r.attempts || ctx.Err() != nil {
+			return resp, err
+		}
+		wait := r.jitter(r.backoff(attempt))
+		slog.Info("retrying request", "op", op, "attempt", attempt+1, "max_attempts", r.attempts, "wait", wait, "reason", reason)
+		if r.sleep(ctx, wait) != nil {
+			return resp, err
+		}
+	}
+}
+
+// retryReason explains why a call is worth retrying, or returns "" when it
+// is not.
+func retryReason(reply *service.ReplyBase, err error) string {
+	if err != nil {
+		var httpErr *soap.HTTPError
+		if errors.As(err, &httpErr) {
+			if httpErr.StatusCode >= 500 && !bytes.Contains(httpErr.ResponseBody, []byte("Fault>")) {
+				return err.Error()
+			}
+			return ""
+		}
+		if isTransportError(err) {
+			return err.Error()
+		}
+		return ""
+	}
+	if reply != nil && reply.ServerState != nil && *reply.ServerState == service.ServerStateCommFault {
+		return "server state " + string(*reply.ServerState)
+	}
+	return ""
+}
+
+// unwrapRetry returns the service a retryService wraps, or svc itself.
+func unwrapRetry(svc service.OpcXmlDASoap) service.OpcXmlDASoap {
+	if r, ok := svc.(*retryService); ok {
+		return r.OpcXmlDASoap
+	}
+	return svc
+}
+
+func (r *retryService) GetStatusContext(ctx context.Context, request *service.GetStatus) (*service.GetStatusResponse, error) {
+	return retryCall(ctx, r, "GetStatus", func() (*service.GetStatusResponse, *service.ReplyBase, error) {
+		resp, err := r.OpcXmlDASoap.GetStatusContext(ctx, request)
+		if resp == nil {
+			return nil, nil, err
+		}
+		return resp, resp.GetStatusResult, err
+	})
+}
+
+func (r *retryService) GetPropertiesContext(ctx context.Context, request *service.GetProperties) (*service.GetPropertiesResponse, error) {
+	return retryCall(ctx, r, "GetProperties", func() (*service.GetPropertiesResponse, *service.ReplyBase, error) {
+		resp, err := r.OpcXmlDASoap.GetPropertiesContext(ctx, request)
+		if resp == nil {
+			return nil, nil, err
+		}
+		return resp, resp.GetPropertiesResult, err
+	})
+}
+
+func (r *retryService) BrowseContext(ctx context.Context, request *service.Browse) (*service.BrowseResponse, error) {
+	return retryCall(ctx, r, "Browse", func() (*service.BrowseResponse, *service.ReplyBase, error) {
+		resp, err := r.OpcXmlDASoap.BrowseContext(ctx, request)
+		if resp == nil {
+			return nil, nil, err
+		}
+		return resp, resp.BrowseResult, err
+	})
+}
+
+func (r *retryService) ReadContext(ctx context.Context, request *service.Read) (*service.ReadResponse, error) {
+	return retryCall(ctx, r, "Read", func() (*service.ReadResponse, *service.ReplyBase, error) {
+		resp, err := r.OpcXmlDASoap.ReadContext(ctx, request)
+		if resp == nil {
+			return nil, nil, err
+		}
+		return resp, resp.ReadResult, err
+	})
+}
+
+func (r *retryService) GetStatus(request *service.GetStatus) (*service.GetStatusResponse, error) {
+	return r.GetStatusContext(context.Background(), request)
+}
+
+func (r *retryService) GetProperties(request *service.GetProperties) (*service.GetPropertiesResponse, error) {
+	return r.GetPropertiesContext(context.Background(), request)
+}
+
+func (r *retryService) Browse(request *service.Browse) (*service.BrowseResponse, error) {
+	return r.BrowseContext(context.Background(), request)
+}
+
+func (r *retryService) Read(request *service.Read) (*service.ReadResponse, error) {
+	return r.ReadContext(context.Background(), request)
+}
@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hooklift/gowsdl/soap"
+
+	"opc-xml-da-cli/internal/config"
+	"opc-xml-da-cli/service"
+	"opc-xml-da-cli/service/servicetest"
+)
+
+func newTestRetry(fake *servicetest.Fake, attempts int) (*retryService, *[]time.Duration) {
+	var waits []time.Duration
+	r := withRetry(fake, config.RetryConfig{MaxAttempts: attempts, InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}).(*retryService)
+	r.jitter = func(d time.Duration) time.Duration { return d }
+	r.sleep = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	return r, &waits
+}
+
+func TestRetryServiceRetriesTransientFailures(t *testing.T) {
+	fake := servicetest.New()
+	fake.SetValue("Plant.Area.Temp", 21.5)
+	dropped := &url.Error{Op: "Post", URL: "http://site/opc", Err: errors.New("connection reset by peer")}
+	fake.FailNext(servicetest.OpRead, dropped, &soap.HTTPError{StatusCode: http.StatusServiceUnavailable})
+	r, waits := newTestRetry(fake, 3)
+
+	resp, err := FetchNodeValue(context.Background(), r, "", "", "", "Plant.Area.Temp")
+	if err != nil || resp.RItemList.Items[0].Value.InnerXML != "21.5" {
+		t.Fatalf("FetchNodeValue = %+v, %v", resp, err)
+	}
+	if fake.Calls(servicetest.OpRead) != 3 {
+		t.Fatalf("Read calls = %d, want 3", fake.Calls(servicetest.OpRead))
+	}
+	if len(*waits) != 2 || (*waits)[0] != 100*time.Millisecond || (*waits)[1] != 200*time.Millisecond {
+		t.Fatalf("waits = %v", *waits)
+	}
+	if got := r.backoff(5); got != 300*time.Millisecond {
+		t.Fatalf("backoff(5) = %s, want the 300ms cap", got)
+	}
+}
+
+func TestRetryServiceGivesUpAndSkipsNonRetryable(t *testing.T) {
+	fake := servicetest.New()
+	dropped := &url.Error{Op: "Post", URL: "http://site/opc", Err: errors.New("connection reset by peer")}
+	r, _ := newTestRetry(fake, 3)
+
+	fake.SetError(servicetest.OpGetStatus, dropped)
+	if _, err := r.GetStatusContext(context.Background(), &service.GetStatus{}); err == nil || fake.Calls(servicetest.OpGetStatus) != 3 {
+		t.Fatalf("GetStatus error = %v after %d calls, want 3", err, fake.Calls(servicetest.OpGetStatus))
+	}
+
+	fault := &soap.HTTPError{StatusCode: http.StatusInternalServerError, ResponseBody: []byte("<soap:Fault><faultstring>E_UNKNOWNITEMNAME</faultstring></soap:Fault>")}
+	fake.SetError(servicetest.OpBrowse, fault)
+	if _, err := r.BrowseContext(context.Background(), &service.Browse{}); err == nil || fake.Calls(servicetest.OpBrowse) != 1 {
+		t.Fatalf("Browse error = %v after %d calls, want 1", err, fake.Calls(servicetest.OpBrowse))
+	}
+
+	fake.SetError(servicetest.OpWrite, dropped)
+	if _, err := r.WriteContext(context.Background(), &service.Write{}); err == nil || fake.Calls(servicetest.OpWrite) != 1 {
+		t.Fatalf("Write error = %v after %d calls, want 1", err, fake.Calls(servicetest.OpWrite))
+	}
+
+	fake.SetStatus(service.ServerStateCommFault, "")
+	resp, err := r.ReadContext(context.Background(), &service.Read{})
+	if err != nil || resp == nil || fake.Calls(servicetest.OpRead) != 3 {
+		t.Fatalf("Read with commFault = %v after %d calls, want the last reply after 3", err, fake.Calls(servicetest.OpRead))
+	}
+}
+
+func TestReadRetriesOverFlakyLink(t *testing.T) {
+	_, upstream := newFakeServer(t)
+	target, _ := url.Parse(upstream.URL)
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	var requests atomic.Int32
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			http.Error(w, "upstream link down", http.StatusBadGateway)
+			return
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	defer flaky.Close()
+	path := writeCLIConfig(t, "retry:\n  initial_backoff: 1ms\n")
+
+	var out, errOut bytes.Buffer
+	code := NewApp(&out, &errOut).Run([]string{"read", "--config", path, "--endpoint", flaky.URL, "--item-name", "Plant.Area.Temp"})
+	if code == exitSuccess {
+		t.Fatalf("Run(read) without retries succeeded: %q", out.String())
+	}
+	out.Reset()
+	requests.Store(0)
+	code = NewApp(&out, &errOut).Run([]string{"read", "--config", path, "--endpoint", flaky.URL, "--retry-attempts", "2", "--item-name", "Plant.Area.Temp"})
+	if code != exitSuccess || !strings.Contains(out.String(), "21.5") || requests.Load() != 2 {
+		t.Fatalf("Run(read --retry-attempts 2) = %d after %d requests, stdout %q, stderr %q", code, requests.Load(), out.String(), errOut.String())
+	}
+}
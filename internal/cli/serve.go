@@ -0,0 +1,261 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/DishanRajapaksha/industrial-cli-kit/safety"
+
+	"opc-xml-da-cli/internal/output"
+	"opc-xml-da-cli/service"
+)
+
+const (
+	defaultServeListen = ":8080"
+	maxServeBodyBytes  = 1 << 20
+)
+
+// gateway exposes an OpcXmlDASoap client as a JSON HTTP API. Responses are
+// produced by the same renderers as the CLI, selected with ?format=.
+type gateway struct {
+	svc            service.OpcXmlDASoap
+	locale         string
+	clientHandle   string
+	requestTimeout time.Duration
+	writeMode      safety.Mode
+	allowOrigin    string
+	stream         *streamHub
+}
+
+type gatewayReadRequest struct {
+	Items []itemRef `json:"items"`
+}
+
+type gatewayWriteRequest struct {
+	Items        []writeItem `json:"items"`
+	ReturnValues bool        `json:"return_values,omitempty"`
+}
+
+func (a *App) serve(args []string) error {
+	opts := defaultCommandOptions()
+	listen := defaultServeListen
+	allowOrigin := ""
+	streamInterval := time.Second
+	yes := false
+	dryRun := false
+	fs := a.newFlagSet("serve")
+	addCommonFlagsWithoutFormat(fs, &opts)
+	fs.StringVar(&listen, "listen", listen, "HTTP listen address")
+	fs.StringVar(&allowOrigin, "allow-origin", "", "value for Access-Control-Allow-Origin, such as * or https://dashboard.example")
+	fs.DurationVar(&streamInterval, "stream-interval", streamInterval, "upstream poll interval shared by all GET /stream clients")
+	fs.BoolVar(&yes, "yes", false, "allow POST /write to transmit writes to the server")
+	fs.BoolVar(&dryRun, "dry-run", false, "answer POST /write without transmitting (default)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if streamInterval <= 0 {
+		return fmt.Errorf("--stream-interval must be greater than zero")
+	}
+	mode, err := safety.Resolve(yes, dryRun)
+	if err != nil {
+		return err
+	}
+	if err := opts.applyConfig(fs); err != nil {
+		return err
+	}
+	_, opcService, err := a.newService(opts)
+	if err != nil {
+		return err
+	}
+	gw := &gateway{
+		svc:            opcService,
+		locale:         opts.Locale,
+		clientHandle:   opts.ClientHandle,
+		requestTimeout: opts.RequestTimeout,
+		writeMode:      mode,
+		allowOrigin:    allowOrigin,
+		stream:         newStreamHub(opcService, opts.Locale, opts.ClientHandle, opts.RequestTimeout, streamInterval),
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	go gw.stream.run(ctx)
+	server := &http.Server{Addr: listen, Handler: gw.routes(), ReadHeaderTimeout: 10 * time.Second}
+	slog.Info("gateway listening", "listen", listen, "writes", mode)
+	fmt.Fprintf(a.err, "serving JSON API on %s (writes: %s)\n", listen, mode)
+	if err := listenAndServe(ctx, server); err != nil {
+		return fmt.Errorf("serve listen %s: %w", listen, err)
+	}
+	return nil
+}
+
+func (g *gateway) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", g.handleStatus)
+	mux.HandleFunc("GET /browse", g.handleBrowse)
+	mux.HandleFunc("POST /read", g.handleRead)
+	mux.HandleFunc("POST /write", g.handleWrite)
+	if g.stream != nil {
+		mux.Handle("GET /stream", g.stream)
+	}
+	return g.cors(mux)
+}
+
+func (g *gateway) cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if g.allowOrigin != "" {
+			w.Header().Set("Access-Control-Allow-Origin", g.allowOrigin)
+			if r.Method == http.MethodOptions {
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (g *gateway) handleStatus(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := withOptionalTimeout(r.Context(), g.requestTimeout)
+	defer cancel()
+	resp, err := FetchServerStatus(ctx, g.svc, g.locale, g.clientHandle)
+	if err != nil {
+		writeGatewayError(w, upstreamStatus(err), fmt.Errorf("get status: %w", err))
+		return
+	}
+	g.render(w, r, func(a *App, format string) error { return a.renderStatus(format, resp) })
+}
+
+func (g *gateway) handleBrowse(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	ctx, cancel := withOptionalTimeout(r.Context(), g.requestTimeout)
+	defer cancel()
+	elements, err := fetchBrowseElements(ctx, g.svc, g.locale, g.clientHandle, query.Get("item_path"), query.Get("item"))
+	if err != nil {
+		writeGatewayError(w, upstreamStatus(err), err)
+		return
+	}
+	g.render(w, r, func(a *App, format string) error { return a.renderBrowse(format, elements) })
+}
+
+func (g *gateway) handleRead(w http.ResponseWriter, r *http.Request) {
+	var body gatewayReadRequest
+	if err := decodeGatewayBody(r, &body); err != nil {
+		writeGatewayError(w, http.StatusBadRequest, err)
+		return
+	}
+	if len(body.Items) == 0 {
+		writeGatewayError(w, http.StatusBadRequest, errors.New("items is required"))
+		return
+	}
+	ctx, cancel := withOptionalTimeout(r.Context(), g.requestTimeout)
+	defer cancel()
+	resp, err := FetchNodeValues(ctx, g.svc, g.locale, g.clientHandle, body.Items)
+	if err != nil {
+		writeGatewayError(w, upstreamStatus(err), fmt.Errorf("read: %w", err))
+		return
+	}
+	g.render(w, r, func(a *App, format string) error {
+		if output.NormaliseFormat(format) == output.FormatCSV {
+			if err := output.WriteCSV(a.out, readHeaders(), nil); err != nil {
+				return err
+			}
+		}
+		return a.renderRead(format, resp)
+	})
+}
+
+func (g *gateway) handleWrite(w http.ResponseWriter, r *http.Request) {
+	var body gatewayWriteRequest
+	if err := decodeGatewayBody(r, &body); err != nil {
+		writeGatewayError(w, http.StatusBadRequest, err)
+		return
+	}
+	req, err := buildWriteRequest(g.locale, g.clientHandle, body.Items, body.ReturnValues)
+	if err != nil {
+		writeGatewayError(w, http.StatusBadRequest, err)
+		return
+	}
+	if g.writeMode != safety.Execute {
+		slog.Info("gateway write not transmitted", "mode", g.writeMode, "items", len(body.Items))
+		writeGatewayJSON(w, http.StatusForbidden, map[string]interface{}{
+			"error":   "writes are disabled; start serve with --yes to transmit them",
+			"mode":    g.writeMode.String(),
+			"request": req,
+		})
+		return
+	}
+	slog.Info("gateway write requested", "items", len(body.Items))
+	ctx, cancel := withOptionalTimeout(r.Context(), g.requestTimeout)
+	defer cancel()
+	resp, err := g.svc.WriteContext(ctx, req)
+	if err != nil {
+		writeGatewayError(w, upstreamStatus(err), fmt.Errorf("write: %w", err))
+		return
+	}
+	writeGatewayJSON(w, http.StatusOK, resp)
+}
+
+// render runs a CLI renderer into a buffer so a failure can still become an
+// error response, then writes it with a content type matching the format.
+func (g *gateway) render(w http.ResponseWriter, r *http.Request, fn func(a *App, format string) error) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = output.FormatJSON
+	}
+	format = output.NormaliseFormat(format)
+	if err := validateSnapshotFormat(format); err != nil {
+		writeGatewayError(w, http.StatusBadRequest, err)
+		return
+	}
+	var buf bytes.Buffer
+	if err := fn(NewApp(&buf, io.Discard), format); err != nil {
+		writeGatewayError(w, http.StatusInternalServerError, err)
+		return
+	}
+	switch format {
+	case output.FormatJSON:
+		w.Header().Set("Content-Type", "application/json")
+	case output.FormatCSV:
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	default:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	_, _ = w.Write(buf.Bytes())
+}
+
+func decodeGatewayBody(r *http.Request, value interface{}) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxServeBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(value); err != nil {
+		return fmt.Errorf("invalid JSON body: %w", err)
+	}
+	return nil
+}
+
+// upstreamStatus maps a failed server request to an HTTP status.
+func upstreamStatus(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusBadGateway
+}
+
+func writeGatewayError(w http.ResponseWriter, status int, err error) {
+	writeGatewayJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeGatewayJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := output.WriteJSON(w, value); err != nil {
+		slog.Warn("gateway response write failed", "err", err)
+	}
+}
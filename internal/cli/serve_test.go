@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DishanRajapaksha/industrial-cli-kit/safety"
+
+	"opc-xml-da-cli/service"
+)
+
+type fakeGatewayService struct {
+	fakeExporterService
+	writes []*service.Write
+}
+
+func (f *fakeGatewayService) BrowseContext(_ context.Context, req *service.Browse) (*service.BrowseResponse, error) {
+	return &service.BrowseResponse{Elements: []*service.BrowseElement{
+		{Name: "Temp", ItemName: req.ItemName + ".Temp", IsItem: true},
+	}}, nil
+}
+
+func (f *fakeGatewayService) WriteContext(_ context.Context, req *service.Write) (*service.WriteResponse, error) {
+	f.writes = append(f.writes, req)
+	return &service.WriteResponse{RItemList: &service.ReplyItemList{Items: req.ItemList.Items}}, nil
+}
+
+func newTestGateway(mode safety.Mode) (*fakeGatewayService, http.Handler) {
+	svc := &fakeGatewayService{fakeExporterService: fakeExporterService{
+		values: map[string]string{"Plant.Temp": "24.5"},
+		state:  service.ServerStateRunning,
+	}}
+	gw := &gateway{svc: svc, writeMode: mode, allowOrigin: "*"}
+	return svc, gw.routes()
+}
+
+func serveTestRequest(handler http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return rec
+}
+
+func TestGatewayStatusAndBrowse(t *testing.T) {
+	_, handler := newTestGateway(safety.DryRun)
+
+	rec := serveTestRequest(handler, http.MethodGet, "/status", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("GET /status = %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rec.Body.String(), `"ServerState": "running"`) {
+		t.Fatalf("status body = %s", rec.Body.String())
+	}
+	if rec.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Fatal("missing CORS header")
+	}
+
+	rec = serveTestRequest(handler, http.MethodGet, "/browse?item=Plant&format=csv", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Temp,,Plant.Temp,true,false") {
+		t.Fatalf("GET /browse = %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = serveTestRequest(handler, http.MethodPost, "/status", "")
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("POST /status = %d, want 405", rec.Code)
+	}
+}
+
+func TestGatewayRead(t *testing.T) {
+	_, handler := newTestGateway(safety.DryRun)
+	rec := serveTestRequest(handler, http.MethodPost, "/read", `{"items":[{"item_name":"Plant.Temp"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /read = %d %s", rec.Code, rec.Body.String())
+	}
+	var resp service.ReadResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid read JSON: %v", err)
+	}
+	if resp.RItemList.Items[0].Value.InnerXML != "24.5" {
+		t.Fatalf("read value = %+v", resp.RItemList.Items[0].Value)
+	}
+
+	rec = serveTestRequest(handler, http.MethodPost, "/read", `{"items":[{"name":"Plant.Temp"}]}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("POST /read with unknown field = %d, want 400", rec.Code)
+	}
+}
+
+func TestGatewayWriteRequiresYes(t *testing.T) {
+	body := `{"items":[{"item_name":"Plant.Setpoint","value":42},{"item_name":"Plant.Mode","value":"auto"}]}`
+
+	svc, handler := newTestGateway(safety.DryRun)
+	rec := serveTestRequest(handler, http.MethodPost, "/write", body)
+	if rec.Code != http.StatusForbidden || len(svc.writes) != 0 {
+		t.Fatalf("dry-run POST /write = %d with %d writes", rec.Code, len(svc.writes))
+	}
+
+	svc, handler = newTestGateway(safety.Execute)
+	rec = serveTestRequest(handler, http.MethodPost, "/write", body)
+	if rec.Code != http.StatusOK || len(svc.writes) != 1 {
+		t.Fatalf("POST /write = %d %s", rec.Code, rec.Body.String())
+	}
+	items := svc.writes[0].ItemList.Items
+	if items[0].Value.Type != "int" || items[0].Value.InnerXML != "42" || items[1].Value.Type != "string" {
+		t.Fatalf("write values = %+v, %+v", items[0].Value, items[1].Value)
+	}
+}
+
+func TestWriteValueValidatesType(t *testing.T) {
+	if _, err := writeValue("int", json.RawMessage(`"abc"`)); err == nil {
+		t.Fatal("writeValue accepted a non-integer int")
+	}
+	value, err := writeValue("xsd:double", json.RawMessage(`"1.5"`))
+	if err != nil || value.Type != "double" || value.InnerXML != "1.5" {
+		t.Fatalf("writeValue = %+v, %v", value, err)
+	}
+	value, err = writeValue("", json.RawMessage(`"a<b"`))
+	if err != nil || value.InnerXML != "a&lt;b" {
+		t.Fatalf("writeValue escaped = %+v, %v", value, err)
+	}
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// serverShutdownTimeout is how long a server waits for in-flight requests
+// after Ctrl-C or SIGTERM.
+const serverShutdownTimeout = 5 * time.Second
+
+// interruptContext returns a context cancelled by Ctrl-C or SIGTERM, so that
+// long-running commands flush their sinks and close their servers before
+// exiting. The default handling is restored as soon as the first signal
+// arrives, so a second Ctrl-C still kills a command that hangs while
+// shutting down.
+func interruptContext() (ctx context.Context, stop context.CancelFunc) {
+	ctx, stop = signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	context.AfterFunc(ctx, stop)
+	return ctx, stop
+}
+
+// listenAndServe runs server until ctx is cancelled and then shuts it down.
+// Request contexts derive from ctx, so streaming handlers end too.
+func listenAndServe(ctx context.Context, server *http.Server) error {
+	server.BaseContext = func(net.Listener) context.Context { return ctx }
+	errc := make(chan error, 1)
+	go func() { errc <- server.ListenAndServe() }()
+	select {
+	case err := <-errc:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+	}
+	slog.Info("shutting down", "listen", server.Addr)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return nil
+}
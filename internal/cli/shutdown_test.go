@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"opc-xml-da-cli/service/servicetest"
+)
+
+func TestWatchFlushesSinkOnInterrupt(t *testing.T) {
+	fake, server := newFakeServer(t)
+	var mu sync.Mutex
+	var written string
+	influx := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		written += string(body)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer influx.Close()
+	sinkURL := strings.Replace(influx.URL, "http://", "influx://", 1) + "/write?db=opc&batch_size=1000&flush_interval=1h"
+
+	done := make(chan int, 1)
+	var out, errOut bytes.Buffer
+	go func() {
+		done <- NewApp(&out, &errOut).Run([]string{"watch", "--endpoint", server.URL, "--item-name", "Plant.Area.Temp", "--interval", "10ms", "--sink", sinkURL})
+	}()
+	for fake.Calls(servicetest.OpRead) == 0 {
+		time.Sleep(5 * time.Millisecond)
+	}
+	self, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := self.Signal(os.Interrupt); err != nil {
+		t.Skipf("cannot interrupt the test process: %v", err)
+	}
+	select {
+	case code := <-done:
+		if code != exitSuccess {
+			t.Fatalf("Run(watch) = %d after Ctrl-C, stderr %q", code, errOut.String())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch did not stop on Ctrl-C")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !strings.Contains(written, "item_name=Plant.Area.Temp,quality=good value=21.5") {
+		t.Fatalf("influx received %q, want the buffered samples flushed", written)
+	}
+}
+
+func TestListenAndServeShutsDownOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	server := &http.Server{Addr: "127.0.0.1:0", Handler: http.NotFoundHandler()}
+	done := make(chan error, 1)
+	go func() { done <- listenAndServe(ctx, server) }()
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("listenAndServe = %v after cancel", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("listenAndServe did not return after cancel")
+	}
+}
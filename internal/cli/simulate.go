@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"opc-xml-da-cli/internal/simulator"
+	"opc-xml-da-cli/service"
+)
+
+const defaultSimulateListen = ":8081"
+
+func (a *App) simulate(args []string) error {
+	listen := defaultSimulateListen
+	modelPath := ""
+	fs := a.newFlagSet("simulate")
+	fs.StringVar(&listen, "listen", listen, "HTTP listen address for the simulated endpoint")
+	fs.StringVar(&modelPath, "model", "", "YAML model describing the tag tree; a built-in demo plant is used when omitted")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	model := simulator.DefaultModel()
+	if modelPath != "" {
+		var err error
+		if model, err = simulator.LoadModel(modelPath); err != nil {
+			return fmt.Errorf("load model %s: %w", modelPath, err)
+		}
+	}
+
+	server := &http.Server{Addr: listen, Handler: service.NewHandler(simulator.New(model)), ReadHeaderTimeout: 10 * time.Second}
+	fmt.Fprintf(a.err, "simulating OPC XML-DA server; use --endpoint %s\n", simulateEndpoint(listen))
+	ctx, stop := interruptContext()
+	defer stop()
+	if err := listenAndServe(ctx, server); err != nil {
+		return fmt.Errorf("simulate listen %s: %w", listen, err)
+	}
+	return nil
+}
+
+// simulateEndpoint is the URL clients on this host use to reach listen.
+func simulateEndpoint(listen string) string {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return "http://" + listen + "/"
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port) + "/"
+}
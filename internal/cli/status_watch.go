@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"opc-xml-da-cli/internal/output"
+	"opc-xml-da-cli/internal/sink"
+	"opc-xml-da-cli/service"
+)
+
+// runStatusWatch polls GetStatus until the duration elapses. Failed requests
+// are reported as status events with an error rather than ending the loop, so
+// outages reach the output and any sink.
+func (a *App) runStatusWatch(opts commandOptions, interval, duration time.Duration) error {
+	_, opcService, err := a.newService(opts)
+	if err != nil {
+		return err
+	}
+	a.reportEndpointSwitches(opcService, opts.Format)
+	runCtx, stop := interruptContext()
+	defer stop()
+	if duration > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(runCtx, duration)
+		defer cancel()
+	}
+	var statuses sink.StatusWriter
+	if opts.Sink != "" {
+		opened, err := sink.Open(opts.Sink)
+		if err != nil {
+			return fmt.Errorf("open sink: %w", err)
+		}
+		defer opened.Close()
+		writer, ok := opened.(sink.StatusWriter)
+		if !ok {
+			return fmt.Errorf("open sink: %q does not accept status events", opts.Sink)
+		}
+		statuses = writer
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	if output.NormaliseFormat(opts.Format) == output.FormatCSV {
+		if err := output.WriteCSV(a.out, statusEventHeaders(), nil); err != nil {
+			return fmt.Errorf("print status: %w", err)
+		}
+	}
+	for {
+		reqCtx, cancel := withOptionalTimeout(runCtx, opts.RequestTimeout)
+		resp, err := FetchServerStatus(reqCtx, opcService, opts.Locale, opts.ClientHandle)
+		cancel()
+		if err != nil && runCtx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			slog.Warn("get status failed", "err", err)
+		}
+		event := statusEvent(resp, err, time.Now())
+		if err := writeStatusEvent(a.out, opts.Format, event); err != nil {
+			return fmt.Errorf("print status: %w", err)
+		}
+		if statuses != nil {
+			if err := statuses.WriteStatus(runCtx, []sink.Status{event}); err != nil {
+				return fmt.Errorf("watch: %w", err)
+			}
+		}
+		select {
+		case <-runCtx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func statusEvent(resp *service.GetStatusResponse, err error, received time.Time) sink.Status {
+	event := sink.Status{Received: received}
+	if err != nil {
+		event.Error = err.Error()
+		return event
+	}
+	if resp == nil {
+		return event
+	}
+	if resp.GetStatusResult != nil && resp.GetStatusResult.ServerState != nil {
+		event.ServerState = string(*resp.GetStatusResult.ServerState)
+	}
+	if resp.Status != nil {
+		event.StatusInfo = resp.Status.StatusInfo
+		event.VendorInfo = resp.Status.VendorInfo
+		event.ProductVersion = resp.Status.ProductVersion
+		event.StartTime = resp.Status.StartTime.ToGoTime()
+	}
+	return event
+}
+
+func writeStatusEvent(w io.Writer, format string, event sink.Status) error {
+	switch output.NormaliseFormat(format) {
+	case output.FormatJSONL:
+		return output.WriteJSONLine(w, event)
+	case output.FormatCSV:
+		return output.WriteCSVRows(w, [][]string{statusEventRow(event)})
+	case output.FormatText:
+		received := event.Received.Format(time.RFC3339)
+		if event.Error != "" {
+			_, err := fmt.Fprintf(w, "%s error: %s\n", received, event.Error)
+			return err
+		}
+		_, err := fmt.Fprintf(w, "%s %s\n", received, firstNonEmpty(event.ServerState, "unknown"))
+		return err
+	default:
+		return invalidStatusWatchFormat(format)
+	}
+}
+
+func statusEventHeaders() []string {
+	return []string{"Received", "ServerState", "StatusInfo", "VendorInfo", "ProductVersion", "StartTime", "Error"}
+}
+
+func statusEventRow(event sink.Status) []string {
+	startTime := ""
+	if !event.StartTime.IsZero() {
+		startTime = event.StartTime.Format(time.RFC3339Nano)
+	}
+	return []string{
+		event.Received.Format(time.RFC3339Nano),
+		event.ServerState,
+		event.StatusInfo,
+		event.VendorInfo,
+		event.ProductVersion,
+		startTime,
+		event.Error,
+	}
+}
+
+func validateStatusWatchFormat(format string) error {
+	switch output.NormaliseFormat(format) {
+	case output.FormatText, output.FormatJSONL, output.FormatCSV:
+		return nil
+	default:
+		return invalidStatusWatchFormat(format)
+	}
+}
+
+func invalidStatusWatchFormat(format string) error {
+	return fmt.Errorf("invalid output format %q; expected text, jsonl, or csv with --watch", format)
+}
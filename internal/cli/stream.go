@@ -0,0 +1,257 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"opc-xml-da-cli/internal/sink"
+	"opc-xml-da-cli/service"
+)
+
+const (
+	streamClientBuffer = 64
+	streamHeartbeat    = 15 * time.Second
+)
+
+// streamHub multiplexes Server-Sent Events clients onto one upstream poll
+// loop. Each cycle reads the union of all subscribed items in a single Read
+// request, so the server sees one client however many browsers are open.
+type streamHub struct {
+	svc            service.OpcXmlDASoap
+	locale         string
+	clientHandle   string
+	requestTimeout time.Duration
+	interval       time.Duration
+
+	mu      sync.Mutex
+	clients map[*streamClient]struct{}
+	wake    chan struct{}
+}
+
+type streamClient struct {
+	items  map[string]itemRef
+	events chan streamEvent
+
+	// last and resync are only touched by the poll loop.
+	last   map[string]string
+	resync bool
+}
+
+type streamEvent struct {
+	Name string
+	Data []byte
+}
+
+func newStreamHub(svc service.OpcXmlDASoap, locale, clientHandle string, requestTimeout, interval time.Duration) *streamHub {
+	return &streamHub{
+		svc:            svc,
+		locale:         locale,
+		clientHandle:   clientHandle,
+		requestTimeout: requestTimeout,
+		interval:       interval,
+		clients:        map[*streamClient]struct{}{},
+		wake:           make(chan struct{}, 1),
+	}
+}
+
+func (h *streamHub) run(ctx context.Context) {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-h.wake:
+		}
+		pollCtx, cancel := withOptionalTimeout(ctx, h.requestTimeout)
+		h.poll(pollCtx)
+		cancel()
+	}
+}
+
+func (h *streamHub) subscribe(items []itemRef) *streamClient {
+	client := &streamClient{
+		items:  map[string]itemRef{},
+		events: make(chan streamEvent, streamClientBuffer),
+		last:   map[string]string{},
+	}
+	for _, item := range items {
+		client.items[makeBrowseKey(item.ItemPath, item.ItemName)] = item
+	}
+	h.mu.Lock()
+	h.clients[client] = struct{}{}
+	total := len(h.clients)
+	h.mu.Unlock()
+	slog.Info("stream client connected", "items", len(client.items), "clients", total)
+	// Poll straight away so a new client does not wait a full interval.
+	select {
+	case h.wake <- struct{}{}:
+	default:
+	}
+	return client
+}
+
+func (h *streamHub) unsubscribe(client *streamClient) {
+	h.mu.Lock()
+	delete(h.clients, client)
+	total := len(h.clients)
+	h.mu.Unlock()
+	slog.Info("stream client disconnected", "clients", total)
+}
+
+// poll reads every subscribed item once and sends each client the samples
+// that changed since it last heard about them.
+func (h *streamHub) poll(ctx context.Context) {
+	h.mu.Lock()
+	clients := make([]*streamClient, 0, len(h.clients))
+	union := map[string]itemRef{}
+	for client := range h.clients {
+		clients = append(clients, client)
+		for key, item := range client.items {
+			union[key] = item
+		}
+	}
+	h.mu.Unlock()
+	if len(union) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(union))
+	for key := range union {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	items := make([]itemRef, 0, len(keys))
+	for _, key := range keys {
+		items = append(items, union[key])
+	}
+
+	resp, err := FetchNodeValues(ctx, h.svc, h.locale, h.clientHandle, items)
+	received := time.Now()
+	if err != nil {
+		slog.Warn("stream read failed", "items", len(items), "err", err)
+		data, _ := json.Marshal(map[string]interface{}{"error": err.Error(), "received": received})
+		for _, client := range clients {
+			h.send(client, streamEvent{Name: "error", Data: data})
+			client.resync = true
+		}
+		return
+	}
+	samples := streamSamples(items, resp, received)
+	for _, client := range clients {
+		resync := client.resync
+		client.resync = false
+		for key := range client.items {
+			sample, ok := samples[key]
+			if !ok {
+				continue
+			}
+			signature := sample.Value + "\x00" + sample.Quality + "\x00" + sample.ResultID + "\x00" + sample.Timestamp.String()
+			if !resync && client.last[key] == signature {
+				continue
+			}
+			data, err := json.Marshal(sample)
+			if err != nil {
+				continue
+			}
+			if h.send(client, streamEvent{Name: "sample", Data: data}) {
+				client.last[key] = signature
+			}
+		}
+	}
+}
+
+// send queues an event without blocking the poll loop. A client that falls
+// behind loses events and is sent a full snapshot once it catches up.
+func (h *streamHub) send(client *streamClient, event streamEvent) bool {
+	select {
+	case client.events <- event:
+		return true
+	default:
+		client.resync = true
+		return false
+	}
+}
+
+// streamSamples keys the samples in a multi-item read response. Servers are
+// not required to echo item names or paths, so a missing one is taken from
+// the request at the same position.
+func streamSamples(items []itemRef, resp *service.ReadResponse, received time.Time) map[string]sink.Sample {
+	samples := map[string]sink.Sample{}
+	if resp == nil || resp.RItemList == nil {
+		return samples
+	}
+	for i, value := range resp.RItemList.Items {
+		if value == nil {
+			continue
+		}
+		requested := itemRef{}
+		if i < len(items) {
+			requested = items[i]
+		}
+		// A server may echo only some identifiers, so each one falls back to
+		// the request on its own.
+		requested = itemRef{
+			ItemPath: firstNonEmpty(value.ItemPath, requested.ItemPath),
+			ItemName: firstNonEmpty(value.ItemName, requested.ItemName),
+		}
+		single := &service.ReadResponse{ReadResult: resp.ReadResult, RItemList: &service.ReplyItemList{Items: []*service.ItemValue{value}}}
+		for _, sample := range readResponseSamples(requested, single, received) {
+			samples[makeBrowseKey(requested.ItemPath, requested.ItemName)] = sample
+		}
+	}
+	return samples
+}
+
+// ServeHTTP streams samples for ?item=NAME (repeatable) with an optional
+// ?item_path= applied to every item.
+func (h *streamHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeGatewayError(w, http.StatusInternalServerError, fmt.Errorf("streaming is not supported by this connection"))
+		return
+	}
+	query := r.URL.Query()
+	names := query["item"]
+	if len(names) == 0 {
+		writeGatewayError(w, http.StatusBadRequest, fmt.Errorf("at least one item is required"))
+		return
+	}
+	items := make([]itemRef, 0, len(names))
+	for _, name := range names {
+		items = append(items, itemRef{ItemPath: query.Get("item_path"), ItemName: name})
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+	flusher.Flush()
+
+	client := h.subscribe(items)
+	defer h.unsubscribe(client)
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-client.events:
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Name, event.Data); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
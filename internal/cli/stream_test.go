@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"opc-xml-da-cli/internal/sink"
+	"opc-xml-da-cli/service"
+)
+
+type fakeStreamService struct {
+	service.OpcXmlDASoap
+	mu     sync.Mutex
+	values map[string]string
+	reads  [][]string
+}
+
+func (f *fakeStreamService) ReadContext(_ context.Context, req *service.Read) (*service.ReadResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var names []string
+	items := []*service.ItemValue{}
+	for _, item := range req.ItemList.Items {
+		names = append(names, item.ItemName)
+		// Leave names off the reply so matching by position is exercised.
+		items = append(items, &service.ItemValue{Value: service.AnyType{InnerXML: f.values[item.ItemName]}})
+	}
+	f.reads = append(f.reads, names)
+	return &service.ReadResponse{RItemList: &service.ReplyItemList{Items: items}}, nil
+}
+
+func (f *fakeStreamService) set(name, value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.values[name] = value
+}
+
+func drainStreamEvents(client *streamClient) []sink.Sample {
+	var samples []sink.Sample
+	for {
+		select {
+		case event := <-client.events:
+			var sample sink.Sample
+			_ = json.Unmarshal(event.Data, &sample)
+			samples = append(samples, sample)
+		default:
+			return samples
+		}
+	}
+}
+
+func TestStreamHubMultiplexesClients(t *testing.T) {
+	svc := &fakeStreamService{values: map[string]string{"A": "1", "B": "2", "C": "3"}}
+	hub := newStreamHub(svc, "", "", 0, time.Second)
+	first := hub.subscribe([]itemRef{{ItemName: "A"}, {ItemName: "B"}})
+	second := hub.subscribe([]itemRef{{ItemName: "B"}, {ItemName: "C"}})
+
+	hub.poll(context.Background())
+	if len(svc.reads) != 1 || strings.Join(svc.reads[0], ",") != "A,B,C" {
+		t.Fatalf("upstream reads = %v, want one read of A,B,C", svc.reads)
+	}
+	if got := drainStreamEvents(first); len(got) != 2 {
+		t.Fatalf("first client got %d samples, want 2", len(got))
+	}
+	if got := drainStreamEvents(second); len(got) != 2 {
+		t.Fatalf("second client got %d samples, want 2", len(got))
+	}
+
+	svc.set("C", "4")
+	hub.poll(context.Background())
+	if got := drainStreamEvents(first); len(got) != 0 {
+		t.Fatalf("first client got unchanged samples: %+v", got)
+	}
+	got := drainStreamEvents(second)
+	if len(got) != 1 || got[0].ItemName != "C" || got[0].Value != "4" {
+		t.Fatalf("second client samples = %+v, want C=4", got)
+	}
+
+	hub.unsubscribe(first)
+	hub.poll(context.Background())
+	if last := svc.reads[len(svc.reads)-1]; strings.Join(last, ",") != "B,C" {
+		t.Fatalf("read after unsubscribe = %v, want B,C", last)
+	}
+}
+
+func TestStreamHubServesEvents(t *testing.T) {
+	svc := &fakeStreamService{values: map[string]string{"A": "1"}}
+	hub := newStreamHub(svc, "", "", 0, time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hub.run(ctx)
+	server := httptest.NewServer(hub)
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL + "?item=A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", resp.Header.Get("Content-Type"))
+	}
+	reader := bufio.NewReader(resp.Body)
+	deadline := time.After(5 * time.Second)
+	lines := make(chan string)
+	go func() {
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				close(lines)
+				return
+			}
+			lines <- strings.TrimSpace(line)
+		}
+	}()
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatal("stream closed before a sample arrived")
+			}
+			if strings.HasPrefix(line, "data: ") {
+				if !strings.Contains(line, `"item_name":"A"`) || !strings.Contains(line, `"value":"1"`) {
+					t.Fatalf("sample event = %s", line)
+				}
+				return
+			}
+		case <-deadline:
+			t.Fatal("timed out waiting for a sample event")
+		}
+	}
+}
+
+func TestStreamSamplesFallsBackPerIdentifier(t *testing.T) {
+	items := []itemRef{{ItemPath: "Plant", ItemName: "Area.Temp"}, {ItemPath: "Plant", ItemName: "Line.Count"}}
+	resp := &service.ReadResponse{RItemList: &service.ReplyItemList{Items: []*service.ItemValue{
+		{ItemName: "Area.Temp", Value: service.AnyType{InnerXML: "21.5"}},
+		{Value: service.AnyType{InnerXML: "7"}},
+	}}}
+	samples := streamSamples(items, resp, time.Now())
+	for _, item := range items {
+		sample, ok := samples[makeBrowseKey(item.ItemPath, item.ItemName)]
+		if !ok || sample.ItemPath != "Plant" || sample.ItemName != item.ItemName {
+			t.Fatalf("samples[%s] = %+v, %v; got %+v", item.ItemName, sample, ok, samples)
+		}
+	}
+}
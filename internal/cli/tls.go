@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"opc-xml-da-cli/internal/config"
+)
+
+// certificateExpiryWarning is how close to expiry test-connection starts
+// warning about a certificate.
+const certificateExpiryWarning = 30 * 24 * time.Hour
+
+// newTLSConfig builds the client TLS configuration for cfg. It returns nil
+// when nothing is configured, leaving Go's defaults in place.
+func newTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	if cfg.IsZero() {
+		return nil, nil
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	minVersion, _ := config.ParseTLSVersion(cfg.MinVersion)
+	tlsConfig := &tls.Config{
+		ServerName:         cfg.ServerName,
+		MinVersion:         minVersion,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("load TLS CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("load TLS CA bundle: %s contains no PEM certificates", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load TLS client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if len(cfg.PinSHA256) > 0 {
+		pins := make([][]byte, 0, len(cfg.PinSHA256))
+		for _, pin := range cfg.PinSHA256 {
+			sum, _ := config.ParsePin(pin)
+			pins = append(pins, sum)
+		}
+		// VerifyConnection also runs with InsecureSkipVerify, so a pin can
+		// stand in for chain verification on a lab box. Then only the leaf
+		// counts: the server can send any public CA certificate after its
+		// own. Otherwise the pin may name any certificate of a verified chain.
+		skipVerify := cfg.InsecureSkipVerify
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			var candidates []*x509.Certificate
+			if skipVerify {
+				candidates = state.PeerCertificates[:min(1, len(state.PeerCertificates))]
+			} else {
+				for _, chain := range state.VerifiedChains {
+					candidates = append(candidates, chain...)
+				}
+			}
+			for _, cert := range candidates {
+				sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+				for _, pin := range pins {
+					if bytes.Equal(sum[:], pin) {
+						return nil
+					}
+				}
+			}
+			return errors.New("tls: no server certificate matches the configured pin_sha256")
+		}
+	}
+	return tlsConfig, nil
+}
+
+// certificatePin formats the SHA-256 public key pin accepted by
+// tls.pin_sha256 and --tls-pin.
+func certificatePin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return "sha256/" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// printTLSState reports the negotiated connection and each presented
+// certificate, warning about certificates close to expiry.
+func printTLSState(out io.Writer, state *tls.ConnectionState, now time.Time) {
+	if state == nil {
+		return
+	}
+	fmt.Fprintf(out, "TLS: %s, %s\n", tlsVersion(state.Version), tls.CipherSuiteName(state.CipherSuite))
+	for i, cert := range state.PeerCertificates {
+		remaining := cert.NotAfter.Sub(now)
+		fmt.Fprintf(out, "Certificate %d: %s\n", i, cert.Subject)
+		fmt.Fprintf(out, "  Issuer: %s\n", cert.Issuer)
+		if i == 0 && len(cert.DNSNames) > 0 {
+			fmt.Fprintf(out, "  DNS names: %v\n", cert.DNSNames)
+		}
+		fmt.Fprintf(out, "  Valid: %s to %s (%d days left)\n", cert.NotBefore.UTC().Format(time.DateOnly), cert.NotAfter.UTC().Format(time.DateOnly), int(remaining.Hours()/24))
+		fmt.Fprintf(out, "  Pin: %s\n", certificatePin(cert))
+		switch {
+		case remaining <= 0:
+			fmt.Fprintf(out, "  WARNING: certificate expired on %s\n", cert.NotAfter.UTC().Format(time.DateOnly))
+		case remaining < certificateExpiryWarning:
+			fmt.Fprintf(out, "  WARNING: certificate expires in %d days\n", int(remaining.Hours()/24))
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"opc-xml-da-cli/internal/config"
+	"opc-xml-da-cli/service"
+	"opc-xml-da-cli/service/servicetest"
+)
+
+func newTLSFakeServer(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+	server := httptest.NewTLSServer(service.NewHandler(servicetest.New()))
+	t.Cleanup(server.Close)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, cert, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return server, caFile
+}
+
+func TestTestConnectionTrustsCABundleAndReportsCertificate(t *testing.T) {
+	server, caFile := newTLSFakeServer(t)
+	var out, errOut bytes.Buffer
+	code := NewApp(&out, &errOut).Run([]string{"test-connection", "--endpoint", server.URL, "--tls-ca-file", caFile, "--tls-min-version", "1.2"})
+	if code != exitSuccess {
+		t.Fatalf("Run(test-connection) = %d, stdout %q, stderr %q", code, out.String(), errOut.String())
+	}
+	pin := certificatePin(server.Certificate())
+	for _, want := range []string{"TLS: TLS1.3", "Certificate 0: O=Acme Co", "days left", "Pin: " + pin, "RESULT: PASS"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("test-connection output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	code = NewApp(&bytes.Buffer{}, &bytes.Buffer{}).Run([]string{"test-connection", "--endpoint", server.URL})
+	if code == exitSuccess {
+		t.Fatal("test-connection trusted a self-signed server without --tls-ca-file")
+	}
+}
+
+func TestTLSPinning(t *testing.T) {
+	server, _ := newTLSFakeServer(t)
+	pin := certificatePin(server.Certificate())
+	var errOut bytes.Buffer
+	code := NewApp(&bytes.Buffer{}, &errOut).Run([]string{"status", "--endpoint", server.URL, "--tls-insecure-skip-verify", "--tls-pin", pin})
+	if code != exitSuccess {
+		t.Fatalf("Run(status matching pin) = %d, stderr %q", code, errOut.String())
+	}
+	errOut.Reset()
+	wrong := "sha256/" + strings.Repeat("A", 43) + "="
+	code = NewApp(&bytes.Buffer{}, &errOut).Run([]string{"status", "--endpoint", server.URL, "--tls-insecure-skip-verify", "--tls-pin", wrong})
+	if code == exitSuccess || !strings.Contains(errOut.String(), "pin_sha256") {
+		t.Fatalf("Run(status wrong pin) = %d, stderr %q", code, errOut.String())
+	}
+}
+
+func TestTLSFlagsAreValidated(t *testing.T) {
+	var errOut bytes.Buffer
+	code := NewApp(&bytes.Buffer{}, &errOut).Run([]string{"status", "--endpoint", "https://localhost:1", "--tls-cert-file", "client.pem"})
+	if code != exitConfigError || !strings.Contains(errOut.String(), "key_file") {
+		t.Fatalf("Run(status --tls-cert-file) = %d, stderr %q", code, errOut.String())
+	}
+}
+
+// newTestCertificate creates a certificate for 127.0.0.1 signed by parent,
+// or self-signed when parent is nil.
+func newTestCertificate(t *testing.T, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, isCA bool) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "opc test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+// dialPinned completes a TLS handshake against a server presenting chain.
+func dialPinned(t *testing.T, chain []*x509.Certificate, key *ecdsa.PrivateKey, cfg config.TLSConfig) error {
+	t.Helper()
+	served := tls.Certificate{PrivateKey: key}
+	for _, cert := range chain {
+		served.Certificate = append(served.Certificate, cert.Raw)
+	}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{served}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			_ = conn.(*tls.Conn).Handshake()
+			_ = conn.Close()
+		}
+	}()
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := tls.Dial("tcp", listener.Addr().String(), tlsConfig)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func TestTLSPinIgnoresForgedLeafWithRealCA(t *testing.T) {
+	ca, caKey := newTestCertificate(t, nil, nil, true)
+	leaf, leafKey := newTestCertificate(t, ca, caKey, false)
+	forged, forgedKey := newTestCertificate(t, nil, nil, false)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	caPin := []string{certificatePin(ca)}
+
+	err := dialPinned(t, []*x509.Certificate{forged, ca}, forgedKey, config.TLSConfig{InsecureSkipVerify: true, PinSHA256: caPin})
+	if err == nil || !strings.Contains(err.Error(), "pin_sha256") {
+		t.Fatalf("forged leaf followed by the pinned CA = %v, want a pin error", err)
+	}
+	if err := dialPinned(t, []*x509.Certificate{leaf, ca}, leafKey, config.TLSConfig{InsecureSkipVerify: true, PinSHA256: []string{certificatePin(leaf)}}); err != nil {
+		t.Fatalf("pinned leaf without verification: %v", err)
+	}
+	if err := dialPinned(t, []*x509.Certificate{leaf, ca}, leafKey, config.TLSConfig{CAFile: caFile, PinSHA256: caPin}); err != nil {
+		t.Fatalf("pinned CA of a verified chain: %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+
+	"opc-xml-da-cli/internal/config"
+)
+
+// configureTransport applies the http section of the config to transport:
+// the proxy and its credentials, the connection pool, and whether to ask
+// for compressed responses. Without http.proxy the transport keeps the
+// HTTP_PROXY, HTTPS_PROXY, and NO_PROXY environment variables.
+func configureTransport(transport *http.Transport, cfg config.HTTPConfig) error {
+	if cfg.Proxy != "" {
+		proxyURL, err := config.ParseProxyURL(cfg.Proxy)
+		if err != nil {
+			return err
+		}
+		if cfg.ProxyUsername != "" {
+			proxyURL.User = url.UserPassword(cfg.ProxyUsername, cfg.ProxyPassword)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	if cfg.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	}
+	if cfg.MaxConnsPerHost > 0 {
+		transport.MaxConnsPerHost = cfg.MaxConnsPerHost
+	}
+	if cfg.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = cfg.IdleConnTimeout
+	}
+	transport.DisableKeepAlives = cfg.DisableKeepAlives
+	transport.DisableCompression = cfg.Compression == config.CompressionNone
+	return nil
+}
+
+// requestOptionsRoundTripper adds the configured headers to every request.
+// Unless keep-alives are disabled it also drops the Connection: close the
+// SOAP library asks for, so that the calls of a watch loop or a long
+// browse reuse one connection instead of dialing, and shaking hands over
+// TLS, for each request.
+type requestOptionsRoundTripper struct {
+	base      http.RoundTripper
+	headers   map[string]string
+	keepAlive bool
+}
+
+func (rt *requestOptionsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if rt.keepAlive {
+		req.Close = false
+	}
+	for name, value := range rt.headers {
+		req.Header.Set(name, value)
+	}
+	return rt.base.RoundTrip(req)
+}
+
+// Request compression states of a gzipRoundTripper.
+const (
+	gzipUnknown int32 = iota
+	gzipAccepted
+	gzipUnsupported
+)
+
+// gzipRoundTripper gzips request bodies. Until the server has accepted a
+// gzipped request, any 4xx or 5xx answer is retried uncompressed, since
+// servers reject an unknown Content-Encoding with all sorts of statuses.
+// If the uncompressed retry succeeds, later requests are sent uncompressed
+// too; once a gzipped request succeeds, errors are returned as they are.
+type gzipRoundTripper struct {
+	base  http.RoundTripper
+	state atomic.Int32
+}
+
+func (rt *gzipRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rt.state.Load() == gzipUnsupported || req.Body == nil || req.Body == http.NoBody {
+		return rt.base.RoundTrip(req)
+	}
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := zw.Write(body); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	gzipped := withBody(req, compressed.Bytes())
+	gzipped.Header.Set("Content-Encoding", "gzip")
+	resp, err := rt.base.RoundTrip(gzipped)
+	if err != nil || rt.state.Load() == gzipAccepted {
+		return resp, err
+	}
+	if resp.StatusCode < http.StatusBadRequest {
+		rt.state.CompareAndSwap(gzipUnknown, gzipAccepted)
+		return resp, nil
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	resp, err = rt.base.RoundTrip(withBody(req, body))
+	if err == nil && resp.StatusCode < http.StatusBadRequest && rt.state.CompareAndSwap(gzipUnknown, gzipUnsupported) {
+		slog.Info("server does not accept gzip requests, sending them uncompressed", "url", req.URL.String())
+	}
+	return resp, err
+}
+
+// withBody clones req with body as its replayable body.
+func withBody(req *http.Request, body []byte) *http.Request {
+	clone := req.Clone(req.Context())
+	clone.Body = io.NopCloser(bytes.NewReader(body))
+	clone.ContentLength = int64(len(body))
+	clone.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return clone
+}
@@ -0,0 +1,212 @@
+package cli
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"opc-xml-da-cli/service"
+	"opc-xml-da-cli/service/servicetest"
+)
+
+// newCountingServer serves a fake with handler wrapped by wrap and counts
+// the connections clients open.
+func newCountingServer(t *testing.T, wrap func(http.Handler) http.Handler) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	fake := servicetest.New()
+	fake.SetValue("Plant.Area.Temp", 21.5)
+	var conns atomic.Int32
+	server := httptest.NewUnstartedServer(wrap(service.NewHandler(fake)))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	server.Start()
+	t.Cleanup(server.Close)
+	return server, &conns
+}
+
+func TestWatchReusesConnection(t *testing.T) {
+	var requests atomic.Int32
+	server, conns := newCountingServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			next.ServeHTTP(w, r)
+		})
+	})
+	args := []string{"watch", "--endpoint", server.URL, "--item-name", "Plant.Area.Temp", "--interval", "5ms", "--duration", "40ms", "--format", "jsonl"}
+
+	var out, errOut bytes.Buffer
+	if code := NewApp(&out, &errOut).Run(args); code != exitSuccess {
+		t.Fatalf("Run(watch) = %d, stderr %q", code, errOut.String())
+	}
+	if requests.Load() < 3 || conns.Load() != 1 {
+		t.Fatalf("watch made %d requests over %d connections, want one connection", requests.Load(), conns.Load())
+	}
+
+	requests.Store(0)
+	conns.Store(0)
+	path := writeCLIConfig(t, "http:\n  disable_keep_alives: true\n")
+	if code := NewApp(&out, &errOut).Run(append(args, "--config", path)); code != exitSuccess {
+		t.Fatalf("Run(watch) = %d, stderr %q", code, errOut.String())
+	}
+	if conns.Load() < 3 {
+		t.Fatalf("watch without keep-alives made %d requests over %d connections, want one each", requests.Load(), conns.Load())
+	}
+}
+
+func TestNTLMRejectsDisabledKeepAlives(t *testing.T) {
+	_, server := newFakeServer(t)
+	path := writeCLIConfig(t, "http:\n  disable_keep_alives: true\n")
+	var out, errOut bytes.Buffer
+	code := NewApp(&out, &errOut).Run([]string{"read", "--endpoint", server.URL, "--config", path, "--auth", "ntlm", "--username", "u", "--password", "p", "--item-name", "Plant.Area.Temp"})
+	if code != exitConfigError || !strings.Contains(errOut.String(), "http.disable_keep_alives") {
+		t.Fatalf("Run(read) = %d, stderr %q; want config error", code, errOut.String())
+	}
+}
+
+func TestReadSendsHeadersAndGzip(t *testing.T) {
+	server, _ := newCountingServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Site") != "line-3" || r.Header.Get("X-Api-Key") != "k3y" {
+				http.Error(w, "missing headers", http.StatusForbidden)
+				return
+			}
+			if r.Header.Get("Content-Encoding") != "gzip" || !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+				http.Error(w, "want gzip", http.StatusBadRequest)
+				return
+			}
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(zr)
+			r.Header.Del("Content-Encoding")
+			rec := httptest.NewRecorder()
+			next.ServeHTTP(rec, r)
+			w.Header().Set("Content-Type", rec.Header().Get("Content-Type"))
+			w.Header().Set("Content-Encoding", "gzip")
+			zw := gzip.NewWriter(w)
+			_, _ = zw.Write(rec.Body.Bytes())
+			_ = zw.Close()
+		})
+	})
+	t.Setenv("TEST_API_KEY", "k3y")
+	path := writeCLIConfig(t, `
+vars:
+  line: line-3
+http:
+  compression: gzip
+  headers:
+    X-Site: ${line}
+    X-Api-Key: env:TEST_API_KEY
+`)
+	var out, errOut bytes.Buffer
+	code := NewApp(&out, &errOut).Run([]string{"read", "--config", path, "--endpoint", server.URL, "--item-name", "Plant.Area.Temp"})
+	if code != exitSuccess || !strings.Contains(out.String(), "21.5") {
+		t.Fatalf("Run(read) = %d, stdout %q, stderr %q", code, out.String(), errOut.String())
+	}
+}
+
+func TestGzipRequestsFallBackWhenUnsupported(t *testing.T) {
+	var rejected atomic.Int32
+	server, _ := newCountingServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Content-Encoding") != "" {
+				rejected.Add(1)
+				http.Error(w, "malformed request", http.StatusBadRequest)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	path := writeCLIConfig(t, "http:\n  compression: gzip\n")
+	var out, errOut bytes.Buffer
+	code := NewApp(&out, &errOut).Run([]string{"watch", "--config", path, "--endpoint", server.URL, "--item-name", "Plant.Area.Temp", "--interval", "5ms", "--duration", "30ms"})
+	if code != exitSuccess || !strings.Contains(out.String(), "21.5") || rejected.Load() != 1 {
+		t.Fatalf("Run(watch) = %d after %d rejected requests, stderr %q", code, rejected.Load(), errOut.String())
+	}
+}
+
+func TestGzipRequestsRetryOnlyUntilAccepted(t *testing.T) {
+	var statuses []int
+	var sent []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = append(sent, r.Header.Get("Content-Encoding"))
+		status := statuses[0]
+		statuses = statuses[1:]
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	post := func(rt http.RoundTripper) int {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader("<Read/>"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// A failure either way leaves the mode undecided; after a gzipped
+	// request succeeds, errors are no longer retried.
+	rt := &gzipRoundTripper{base: http.DefaultTransport}
+	statuses = []int{500, 500, 200, 500}
+	if got := []int{post(rt), post(rt), post(rt)}; !reflect.DeepEqual(got, []int{500, 200, 500}) {
+		t.Fatalf("statuses = %v", got)
+	}
+	if want := []string{"gzip", "", "gzip", "gzip"}; !reflect.DeepEqual(sent, want) {
+		t.Fatalf("Content-Encoding sent = %q, want %q", sent, want)
+	}
+}
+
+func TestReadThroughAuthenticatingProxy(t *testing.T) {
+	_, upstream := newFakeServer(t)
+	var proxied atomic.Int32
+	forward := &httputil.ReverseProxy{Rewrite: func(r *httputil.ProxyRequest) {
+		r.Out.URL = r.In.URL
+		r.Out.Header.Del("Proxy-Authorization")
+	}}
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := (&http.Request{Header: http.Header{"Authorization": r.Header.Values("Proxy-Authorization")}}).BasicAuth()
+		if !ok || user != "svc" || pass != "s3cret" {
+			w.Header().Set("Proxy-Authenticate", `Basic realm="proxy"`)
+			http.Error(w, "proxy auth required", http.StatusProxyAuthRequired)
+			return
+		}
+		proxied.Add(1)
+		forward.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+	t.Setenv("TEST_PROXY_PASS", "s3cret")
+	path := writeCLIConfig(t, `
+http:
+  proxy: `+proxy.URL+`
+  proxy_username: svc
+  proxy_password: env:TEST_PROXY_PASS
+`)
+	var out, errOut bytes.Buffer
+	code := NewApp(&out, &errOut).Run([]string{"read", "--config", path, "--endpoint", upstream.URL, "--item-name", "Plant.Area.Temp"})
+	if code != exitSuccess || !strings.Contains(out.String(), "21.5") || proxied.Load() != 1 {
+		t.Fatalf("Run(read) = %d via %d proxied requests, stdout %q, stderr %q", code, proxied.Load(), out.String(), errOut.String())
+	}
+
+	path = writeCLIConfig(t, "http:\n  compression: brotli\n")
+	if code := NewApp(&out, &errOut).Run([]string{"read", "--config", path, "--endpoint", upstream.URL, "--item-name", "Plant.Area.Temp"}); code != exitConfigError {
+		t.Fatalf("Run(read) with bad compression = %d, want config error", code)
+	}
+}
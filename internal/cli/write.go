@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+
+	"opc-xml-da-cli/service"
+)
+
+// writeItem is one value to write. Type is an XML Schema type name such as
+// int, double, boolean, or string; when empty it is inferred from Value.
+type writeItem struct {
+	ItemPath string          `json:"item_path,omitempty"`
+	ItemName string          `json:"item_name,omitempty"`
+	Type     string          `json:"type,omitempty"`
+	Value    json.RawMessage `json:"value"`
+}
+
+// buildWriteRequest converts write items into an XML-DA Write request.
+func buildWriteRequest(locale, clientHandle string, items []writeItem, returnValues bool) (*service.Write, error) {
+	if len(items) == 0 {
+		return nil, errors.New("write requires at least one item")
+	}
+	values := make([]*service.ItemValue, 0, len(items))
+	for _, item := range items {
+		if item.ItemPath == "" && item.ItemName == "" {
+			return nil, errors.New("write requires an item path or item name")
+		}
+		value, err := writeValue(item.Type, item.Value)
+		if err != nil {
+			return nil, fmt.Errorf("item %q: %w", firstNonEmpty(item.ItemName, item.ItemPath), err)
+		}
+		values = append(values, &service.ItemValue{ItemPath: item.ItemPath, ItemName: item.ItemName, Value: value})
+	}
+	return &service.Write{
+		Options: &service.RequestOptions{
+			ReturnErrorText:      true,
+			ReturnDiagnosticInfo: true,
+			ReturnItemTime:       true,
+			ReturnItemPath:       true,
+			ReturnItemName:       true,
+			ClientRequestHandle:  clientHandle,
+			LocaleID:             locale,
+		},
+		ItemList:            &service.WriteRequestItemList{Items: values},
+		ReturnValuesOnReply: returnValues,
+	}, nil
+}
+
+// writeValue encodes a JSON scalar as an xsd typed value.
+func writeValue(xsdType string, raw json.RawMessage) (service.AnyType, error) {
+	var decoded interface{}
+	if len(raw) == 0 {
+		return service.AnyType{}, errors.New("value is required")
+	}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return service.AnyType{}, fmt.Errorf("invalid value: %w", err)
+	}
+	text := ""
+	switch v := decoded.(type) {
+	case bool:
+		text = strconv.FormatBool(v)
+		if xsdType == "" {
+			xsdType = "boolean"
+		}
+	case float64:
+		text = string(raw)
+		if xsdType == "" {
+			xsdType = "double"
+			if !strings.ContainsAny(text, ".eE") {
+				xsdType = "int"
+			}
+		}
+	case string:
+		text = v
+		if xsdType == "" {
+			xsdType = "string"
+		}
+	default:
+		return service.AnyType{}, errors.New("value must be a string, number, or boolean")
+	}
+	xsdType = strings.TrimPrefix(xsdType, "xsd:")
+	if err := service.CheckValue(xsdType, text); err != nil {
+		return service.AnyType{}, err
+	}
+	return service.AnyType{InnerXML: html.EscapeString(text), Type: xsdType}, nil
+}
@@ -3,22 +3,32 @@ package config
 import (
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"time"
 
-	"gopkg.in/yaml.v3"
+	"opc-xml-da-cli/internal/httpauth"
 )
 
 const DefaultConfigPath = "config.yaml"
 
 type ClientConfig struct {
-	Endpoint       string        `yaml:"endpoint"`
-	Username       string        `yaml:"username,omitempty"`
-	Password       string        `yaml:"password,omitempty"`
-	Locale         string        `yaml:"locale,omitempty"`
-	ClientHandle   string        `yaml:"client_handle,omitempty"`
-	HTTPTimeout    time.Duration `yaml:"http_timeout"`
-	RequestTimeout time.Duration `yaml:"request_timeout"`
+	Endpoint       string            `yaml:"endpoint"`
+	Endpoints      []string          `yaml:"endpoints,omitempty"`
+	Username       string            `yaml:"username,omitempty"`
+	Password       string            `yaml:"password,omitempty"`
+	Auth           string            `yaml:"auth,omitempty"`
+	Locale         string            `yaml:"locale,omitempty"`
+	ClientHandle   string            `yaml:"client_handle,omitempty"`
+	HTTPTimeout    time.Duration     `yaml:"http_timeout"`
+	RequestTimeout time.Duration     `yaml:"request_timeout"`
+	TLS            TLSConfig         `yaml:"tls,omitempty"`
+	HTTP           HTTPConfig        `yaml:"http,omitempty"`
+	Quirks         QuirksConfig      `yaml:"quirks,omitempty"`
+	Failover       FailoverConfig    `yaml:"failover,omitempty"`
+	Retry          RetryConfig       `yaml:"retry,omitempty"`
+	Extends        string            `yaml:"extends,omitempty"`
+	Vars           map[string]string `yaml:"vars,omitempty"`
 }
 
 type FileConfig struct {
@@ -34,30 +44,12 @@ func DefaultClientConfig() ClientConfig {
 	}
 }
 
+// LoadClientConfigForProfile loads the effective config for profile from
+// path, with the OPCXMLDA_* environment overlay applied and secrets
+// resolved.
 func LoadClientConfigForProfile(path, profile string) (ClientConfig, error) {
-	cfg, err := LoadFile(path)
-	if err != nil {
-		return ClientConfig{}, err
-	}
-	selected := cfg.ClientConfig
-	if selected.HTTPTimeout == 0 {
-		selected.HTTPTimeout = DefaultClientConfig().HTTPTimeout
-	}
-	if selected.RequestTimeout == 0 {
-		selected.RequestTimeout = DefaultClientConfig().RequestTimeout
-	}
-
-	if profile == "" {
-		profile = cfg.DefaultProfile
-	}
-	if profile != "" {
-		profileCfg, ok := cfg.Profiles[profile]
-		if !ok {
-			return ClientConfig{}, fmt.Errorf("profile %q not found", profile)
-		}
-		selected = mergeClientConfig(selected, profileCfg)
-	}
-	return selected, nil
+	effective, err := Load(LoadOptions{Path: path, Profile: profile, LookupEnv: os.LookupEnv, ResolveSecrets: true})
+	return effective.ClientConfig, err
 }
 
 func LoadFile(path string) (FileConfig, error) {
@@ -66,28 +58,75 @@ func LoadFile(path string) (FileConfig, error) {
 		return FileConfig{}, fmt.Errorf("read config %q: %w", path, err)
 	}
 	cfg := FileConfig{ClientConfig: DefaultClientConfig()}
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+	if err := decodeStrict(data, &cfg); err != nil {
+		return FileConfig{}, fmt.Errorf("parse config %q: %w", path, err)
+	}
+	if err := checkProfileRefs(cfg, data); err != nil {
 		return FileConfig{}, fmt.Errorf("parse config %q: %w", path, err)
 	}
 	return cfg, nil
 }
 
 func ValidateClientConfig(cfg ClientConfig) error {
-	if cfg.Endpoint == "" {
+	if cfg.Endpoint != "" && len(cfg.Endpoints) > 0 {
+		return errors.New("set either endpoint or endpoints, not both")
+	}
+	endpoints := cfg.EndpointList()
+	if len(endpoints) == 0 {
 		return errors.New("endpoint is required")
 	}
+	for _, endpoint := range endpoints {
+		if err := ValidateEndpoint(endpoint); err != nil {
+			return err
+		}
+	}
+	if err := cfg.Failover.Validate(); err != nil {
+		return err
+	}
+	if err := cfg.Retry.Validate(); err != nil {
+		return err
+	}
+	if err := cfg.HTTP.Validate(); err != nil {
+		return err
+	}
+	if err := cfg.Quirks.Validate(); err != nil {
+		return err
+	}
 	if cfg.HTTPTimeout < 0 {
 		return errors.New("http_timeout must be zero or greater")
 	}
 	if cfg.RequestTimeout < 0 {
 		return errors.New("request_timeout must be zero or greater")
 	}
+	if _, err := httpauth.ParseScheme(cfg.Auth); err != nil {
+		return err
+	}
+	if err := cfg.HTTP.ValidateAuth(cfg.Auth); err != nil {
+		return err
+	}
+	return cfg.TLS.Validate()
+}
+
+// ValidateEndpoint checks that endpoint is an absolute http or https URL.
+func ValidateEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("endpoint %q is not a valid URL: %w", endpoint, errors.Unwrap(err))
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("endpoint %q must use http or https", endpoint)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("endpoint %q has no host", endpoint)
+	}
 	return nil
 }
 
 func mergeClientConfig(base, override ClientConfig) ClientConfig {
-	if override.Endpoint != "" {
-		base.Endpoint = override.Endpoint
+	// endpoint and endpoints are one setting: a layer that sets either
+	// replaces both.
+	if override.Endpoint != "" || len(override.Endpoints) > 0 {
+		base.Endpoint, base.Endpoints = override.Endpoint, override.Endpoints
 	}
 	if override.Username != "" {
 		base.Username = override.Username
@@ -95,6 +134,9 @@ func mergeClientConfig(base, override ClientConfig) ClientConfig {
 	if override.Password != "" {
 		base.Password = override.Password
 	}
+	if override.Auth != "" {
+		base.Auth = override.Auth
+	}
 	if override.Locale != "" {
 		base.Locale = override.Locale
 	}
@@ -107,5 +149,11 @@ func mergeClientConfig(base, override ClientConfig) ClientConfig {
 	if override.RequestTimeout != 0 {
 		base.RequestTimeout = override.RequestTimeout
 	}
+	base.TLS = mergeTLSConfig(base.TLS, override.TLS)
+	base.HTTP = mergeHTTPConfig(base.HTTP, override.HTTP)
+	base.Quirks = mergeQuirksConfig(base.Quirks, override.Quirks)
+	base.Failover = mergeFailoverConfig(base.Failover, override.Failover)
+	base.Retry = mergeRetryConfig(base.Retry, override.Retry)
+	base.Vars = mergeVars(base.Vars, override.Vars)
 	return base
 }
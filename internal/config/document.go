@@ -0,0 +1,218 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ClientConfigKeys lists the settable keys of a client config, in config
+// file order. Nested keys use dots; http.headers.<name> and vars.<name> are
+// accepted besides these.
+var ClientConfigKeys = []string{
+	"endpoint", "endpoints", "username", "password", "auth", "locale", "client_handle", "http_timeout", "request_timeout",
+	"tls.ca_file", "tls.cert_file", "tls.key_file", "tls.server_name", "tls.min_version", "tls.insecure_skip_verify", "tls.pin_sha256",
+	"http.proxy", "http.proxy_username", "http.proxy_password", "http.max_idle_conns_per_host", "http.max_conns_per_host",
+	"http.idle_conn_timeout", "http.disable_keep_alives", "http.compression",
+	"quirks.preset", "quirks.soap_action", "quirks.namespace_prefix", "quirks.send_empty_item_path", "quirks.lenient_namespaces",
+	"quirks.timestamp_zone", "quirks.continuation_without_more_elements", "quirks.continuation_omits_item",
+	"failover.failback", "failover.health_interval",
+	"retry.max_attempts", "retry.initial_backoff", "retry.max_backoff",
+}
+
+// Document is a config file held as a YAML node tree, so that edits keep
+// comments, key order, and layout.
+type Document struct {
+	path string
+	root yaml.Node
+}
+
+// LoadDocument reads the config file at path for editing. A missing file
+// yields an empty document that Save creates.
+func LoadDocument(path string) (*Document, error) {
+	doc := &Document{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		doc.root = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+		return doc, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read config %q: %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, &doc.root); err != nil {
+		return nil, fmt.Errorf("parse config %q: %w", path, err)
+	}
+	if doc.root.Kind == 0 {
+		doc.root.Kind = yaml.DocumentNode
+	}
+	if doc.root.Kind == yaml.DocumentNode && len(doc.root.Content) == 0 {
+		doc.root.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+	if doc.root.Kind != yaml.DocumentNode || len(doc.root.Content) != 1 || doc.root.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("parse config %q: top level is not a mapping", path)
+	}
+	return doc, nil
+}
+
+// Get returns the value at a dotted key such as "profiles.site-a.endpoint"
+// or "vars.host".
+// Scalars are returned as written; mappings and lists as YAML.
+func (d *Document) Get(key string) (string, bool, error) {
+	path, err := splitKey(key)
+	if err != nil {
+		return "", false, err
+	}
+	node := d.root.Content[0]
+	for _, name := range path {
+		if node = mappingValue(node, name); node == nil {
+			return "", false, nil
+		}
+	}
+	if node.Kind == yaml.ScalarNode {
+		return node.Value, true, nil
+	}
+	data, err := yaml.Marshal(node)
+	if err != nil {
+		return "", false, err
+	}
+	return strings.TrimRight(string(data), "\n"), true, nil
+}
+
+// Set stores value at a dotted key, creating profiles and nested mappings
+// as needed. endpoints and tls.pin_sha256 take comma-separated lists.
+func (d *Document) Set(key, value string) error {
+	path, err := splitKey(key)
+	if err != nil {
+		return err
+	}
+	node := d.root.Content[0]
+	for _, name := range path[:len(path)-1] {
+		child := mappingValue(node, name)
+		if child == nil {
+			child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name}, child)
+		} else if child.Kind != yaml.MappingNode {
+			return fmt.Errorf("config key %q: %s is not a mapping", key, name)
+		}
+		node = child
+	}
+	leaf := path[len(path)-1]
+	replacement := scalarNode(leaf, value)
+	if existing := mappingValue(node, leaf); existing != nil {
+		replacement.HeadComment, replacement.LineComment, replacement.FootComment = existing.HeadComment, existing.LineComment, existing.FootComment
+		*existing = *replacement
+		return nil
+	}
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: leaf}, replacement)
+	return nil
+}
+
+// Bytes encodes the document with two-space indentation.
+func (d *Document) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&d.root); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Save checks that the edited document still loads with LoadFile and then
+// replaces the file atomically.
+func (d *Document) Save() error {
+	data, err := d.Bytes()
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(d.path), "."+filepath.Base(d.path)+".*")
+	if err != nil {
+		return fmt.Errorf("write config %q: %w", d.path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write config %q: %w", d.path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write config %q: %w", d.path, err)
+	}
+	if _, err := LoadFile(tmp.Name()); err != nil {
+		return fmt.Errorf("config %q would not load after the change: %w", d.path, errors.Unwrap(err))
+	}
+	if info, err := os.Stat(d.path); err == nil {
+		_ = os.Chmod(tmp.Name(), info.Mode().Perm())
+	}
+	if err := os.Rename(tmp.Name(), d.path); err != nil {
+		return fmt.Errorf("write config %q: %w", d.path, err)
+	}
+	return nil
+}
+
+// splitKey validates a dotted key against the config layout.
+func splitKey(key string) ([]string, error) {
+	parts := strings.Split(key, ".")
+	settings := parts
+	if parts[0] == "profiles" {
+		if len(parts) < 3 || parts[1] == "" {
+			return nil, fmt.Errorf("config key %q: use profiles.<name>.<setting>", key)
+		}
+		settings = parts[2:]
+		if len(settings) == 1 && settings[0] == "extends" {
+			return parts, nil
+		}
+	} else if key == "default_profile" {
+		return parts, nil
+	}
+	if len(settings) == 2 && settings[0] == "vars" && settings[1] != "" {
+		return parts, nil
+	}
+	if len(settings) == 3 && settings[0] == "http" && settings[1] == "headers" && settings[2] != "" {
+		return parts, nil
+	}
+	if !slices.Contains(ClientConfigKeys, strings.Join(settings, ".")) {
+		return nil, fmt.Errorf("unknown config key %q", key)
+	}
+	return parts, nil
+}
+
+func mappingValue(node *yaml.Node, name string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == name {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func scalarNode(leaf, value string) *yaml.Node {
+	switch leaf {
+	case "insecure_skip_verify", "disable_keep_alives", "send_empty_item_path", "lenient_namespaces",
+		"continuation_without_more_elements", "continuation_omits_item":
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: value}
+	case "max_attempts", "max_idle_conns_per_host", "max_conns_per_host":
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: value}
+	case "endpoints", "pin_sha256":
+		list := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, pin := range strings.Split(value, ",") {
+			if pin = strings.TrimSpace(pin); pin != "" {
+				list.Content = append(list.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: pin})
+			}
+		}
+		return list
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+	}
+}
@@ -7,23 +7,41 @@ import (
 
 func (c *ClientConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	type rawClientConfig struct {
-		Endpoint       string `yaml:"endpoint"`
-		Username       string `yaml:"username,omitempty"`
-		Password       string `yaml:"password,omitempty"`
-		Locale         string `yaml:"locale,omitempty"`
-		ClientHandle   string `yaml:"client_handle,omitempty"`
-		HTTPTimeout    string `yaml:"http_timeout"`
-		RequestTimeout string `yaml:"request_timeout"`
+		Endpoint       string            `yaml:"endpoint"`
+		Endpoints      []string          `yaml:"endpoints,omitempty"`
+		Username       string            `yaml:"username,omitempty"`
+		Password       string            `yaml:"password,omitempty"`
+		Auth           string            `yaml:"auth,omitempty"`
+		Locale         string            `yaml:"locale,omitempty"`
+		ClientHandle   string            `yaml:"client_handle,omitempty"`
+		HTTPTimeout    string            `yaml:"http_timeout"`
+		RequestTimeout string            `yaml:"request_timeout"`
+		TLS            TLSConfig         `yaml:"tls,omitempty"`
+		HTTP           HTTPConfig        `yaml:"http,omitempty"`
+		Quirks         QuirksConfig      `yaml:"quirks,omitempty"`
+		Failover       FailoverConfig    `yaml:"failover,omitempty"`
+		Retry          RetryConfig       `yaml:"retry,omitempty"`
+		Extends        string            `yaml:"extends,omitempty"`
+		Vars           map[string]string `yaml:"vars,omitempty"`
 	}
 	var raw rawClientConfig
 	if err := unmarshal(&raw); err != nil {
 		return err
 	}
 	c.Endpoint = raw.Endpoint
+	c.Endpoints = raw.Endpoints
 	c.Username = raw.Username
 	c.Password = raw.Password
+	c.Auth = raw.Auth
 	c.Locale = raw.Locale
 	c.ClientHandle = raw.ClientHandle
+	c.TLS = raw.TLS
+	c.HTTP = raw.HTTP
+	c.Quirks = raw.Quirks
+	c.Failover = raw.Failover
+	c.Retry = raw.Retry
+	c.Extends = raw.Extends
+	c.Vars = raw.Vars
 	var err error
 	c.HTTPTimeout, err = parseOptionalDuration("http_timeout", raw.HTTPTimeout)
 	if err != nil {
@@ -39,12 +57,20 @@ func (c *ClientConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 func (f *FileConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	type rawFileConfig struct {
 		Endpoint       string                  `yaml:"endpoint"`
+		Endpoints      []string                `yaml:"endpoints,omitempty"`
 		Username       string                  `yaml:"username,omitempty"`
 		Password       string                  `yaml:"password,omitempty"`
+		Auth           string                  `yaml:"auth,omitempty"`
 		Locale         string                  `yaml:"locale,omitempty"`
 		ClientHandle   string                  `yaml:"client_handle,omitempty"`
 		HTTPTimeout    string                  `yaml:"http_timeout"`
 		RequestTimeout string                  `yaml:"request_timeout"`
+		TLS            TLSConfig               `yaml:"tls,omitempty"`
+		HTTP           HTTPConfig              `yaml:"http,omitempty"`
+		Quirks         QuirksConfig            `yaml:"quirks,omitempty"`
+		Failover       FailoverConfig          `yaml:"failover,omitempty"`
+		Retry          RetryConfig             `yaml:"retry,omitempty"`
+		Vars           map[string]string       `yaml:"vars,omitempty"`
 		DefaultProfile string                  `yaml:"default_profile,omitempty"`
 		Profiles       map[string]ClientConfig `yaml:"profiles,omitempty"`
 	}
@@ -62,12 +88,20 @@ func (f *FileConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	}
 	f.ClientConfig = ClientConfig{
 		Endpoint:       raw.Endpoint,
+		Endpoints:      raw.Endpoints,
 		Username:       raw.Username,
 		Password:       raw.Password,
+		Auth:           raw.Auth,
 		Locale:         raw.Locale,
 		ClientHandle:   raw.ClientHandle,
 		HTTPTimeout:    httpTimeout,
 		RequestTimeout: requestTimeout,
+		TLS:            raw.TLS,
+		HTTP:           raw.HTTP,
+		Quirks:         raw.Quirks,
+		Failover:       raw.Failover,
+		Retry:          raw.Retry,
+		Vars:           raw.Vars,
 	}
 	f.DefaultProfile = raw.DefaultProfile
 	f.Profiles = raw.Profiles
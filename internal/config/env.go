@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix starts the name of every environment variable the CLI reads.
+const EnvPrefix = "OPCXMLDA_"
+
+// Environment variables that choose the config file and profile rather than
+// a setting.
+const (
+	EnvConfig  = EnvPrefix + "CONFIG"
+	EnvProfile = EnvPrefix + "PROFILE"
+)
+
+// EnvVar maps an environment variable onto a config key.
+type EnvVar struct {
+	Name string
+	Key  string
+	set  func(*ClientConfig, string) error
+}
+
+// EnvVars lists the settings that can come from the environment. Durations
+// use Go syntax such as 30s, and OPCXMLDA_ENDPOINTS and OPCXMLDA_TLS_PIN
+// take comma-separated lists.
+var EnvVars = []EnvVar{
+	{EnvPrefix + "ENDPOINT", "endpoint", func(c *ClientConfig, v string) error { c.Endpoint, c.Endpoints = v, nil; return nil }},
+	{EnvPrefix + "ENDPOINTS", "endpoints", func(c *ClientConfig, v string) error { c.Endpoint, c.Endpoints = "", splitEnvList(v); return nil }},
+	{EnvPrefix + "USERNAME", "username", func(c *ClientConfig, v string) error { c.Username = v; return nil }},
+	{EnvPrefix + "PASSWORD", "password", func(c *ClientConfig, v string) error { c.Password = v; return nil }},
+	{EnvPrefix + "AUTH", "auth", func(c *ClientConfig, v string) error { c.Auth = v; return nil }},
+	{EnvPrefix + "LOCALE", "locale", func(c *ClientConfig, v string) error { c.Locale = v; return nil }},
+	{EnvPrefix + "CLIENT_HANDLE", "client_handle", func(c *ClientConfig, v string) error { c.ClientHandle = v; return nil }},
+	{EnvPrefix + "HTTP_TIMEOUT", "http_timeout", func(c *ClientConfig, v string) error { return setEnvDuration(&c.HTTPTimeout, v) }},
+	{EnvPrefix + "TIMEOUT", "request_timeout", func(c *ClientConfig, v string) error { return setEnvDuration(&c.RequestTimeout, v) }},
+	{EnvPrefix + "TLS_CA_FILE", "tls.ca_file", func(c *ClientConfig, v string) error { c.TLS.CAFile = v; return nil }},
+	{EnvPrefix + "TLS_CERT_FILE", "tls.cert_file", func(c *ClientConfig, v string) error { c.TLS.CertFile = v; return nil }},
+	{EnvPrefix + "TLS_KEY_FILE", "tls.key_file", func(c *ClientConfig, v string) error { c.TLS.KeyFile = v; return nil }},
+	{EnvPrefix + "TLS_SERVER_NAME", "tls.server_name", func(c *ClientConfig, v string) error { c.TLS.ServerName = v; return nil }},
+	{EnvPrefix + "TLS_MIN_VERSION", "tls.min_version", func(c *ClientConfig, v string) error { c.TLS.MinVersion = v; return nil }},
+	{EnvPrefix + "TLS_INSECURE_SKIP_VERIFY", "tls.insecure_skip_verify", func(c *ClientConfig, v string) error {
+		skip, err := strconv.ParseBool(v)
+		c.TLS.InsecureSkipVerify = skip
+		return err
+	}},
+	{EnvPrefix + "TLS_PIN", "tls.pin_sha256", func(c *ClientConfig, v string) error { c.TLS.PinSHA256 = splitEnvList(v); return nil }},
+	{EnvPrefix + "PROXY", "http.proxy", func(c *ClientConfig, v string) error { c.HTTP.Proxy = v; return nil }},
+	{EnvPrefix + "PROXY_USERNAME", "http.proxy_username", func(c *ClientConfig, v string) error { c.HTTP.ProxyUsername = v; return nil }},
+	{EnvPrefix + "PROXY_PASSWORD", "http.proxy_password", func(c *ClientConfig, v string) error { c.HTTP.ProxyPassword = v; return nil }},
+	{EnvPrefix + "COMPRESSION", "http.compression", func(c *ClientConfig, v string) error { c.HTTP.Compression = v; return nil }},
+	{EnvPrefix + "QUIRKS", "quirks.preset", func(c *ClientConfig, v string) error { c.Quirks.Preset = v; return nil }},
+	{EnvPrefix + "FAILBACK", "failover.failback", func(c *ClientConfig, v string) error { c.Failover.Failback = v; return nil }},
+	{EnvPrefix + "RETRY_ATTEMPTS", "retry.max_attempts", func(c *ClientConfig, v string) error {
+		attempts, err := strconv.Atoi(v)
+		c.Retry.MaxAttempts = attempts
+		return err
+	}},
+}
+
+func splitEnvList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func setEnvDuration(target *time.Duration, value string) error {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*target = d
+	return nil
+}
+
+// applyEnv overlays the non-empty EnvVars on cfg and records them in
+// sources.
+func applyEnv(cfg ClientConfig, sources Sources, lookupEnv func(string) (string, bool)) (ClientConfig, error) {
+	for _, env := range EnvVars {
+		value, ok := lookupEnv(env.Name)
+		if !ok || value == "" {
+			continue
+		}
+		if err := env.set(&cfg, value); err != nil {
+			return ClientConfig{}, fmt.Errorf("%s: %w", env.Name, err)
+		}
+		sources[env.Key] = "env " + env.Name
+	}
+	return cfg, nil
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Failback policies for clients with several endpoints.
+const (
+	FailbackAuto  = "auto"
+	FailbackNever = "never"
+)
+
+// DefaultHealthInterval is how often an earlier endpoint is checked while
+// failed over, unless failover.health_interval says otherwise.
+const DefaultHealthInterval = 30 * time.Second
+
+// FailoverConfig controls how a client moves between the endpoints of an
+// endpoints list. Failback auto returns to an earlier endpoint once its
+// GetStatus health check passes; never stays on the backup for the rest of
+// the run.
+type FailoverConfig struct {
+	Failback       string        `yaml:"failback,omitempty"`
+	HealthInterval time.Duration `yaml:"health_interval,omitempty"`
+}
+
+func (f *FailoverConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type rawFailoverConfig struct {
+		Failback       string `yaml:"failback,omitempty"`
+		HealthInterval string `yaml:"health_interval,omitempty"`
+	}
+	var raw rawFailoverConfig
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	interval, err := parseOptionalDuration("failover.health_interval", raw.HealthInterval)
+	if err != nil {
+		return err
+	}
+	f.Failback = raw.Failback
+	f.HealthInterval = interval
+	return nil
+}
+
+// Validate checks the failback policy and health interval.
+func (f FailoverConfig) Validate() error {
+	switch f.Failback {
+	case "", FailbackAuto, FailbackNever:
+	default:
+		return fmt.Errorf("failover.failback %q is not one of auto, never", f.Failback)
+	}
+	if f.HealthInterval < 0 {
+		return fmt.Errorf("failover.health_interval must be zero or greater")
+	}
+	return nil
+}
+
+func mergeFailoverConfig(base, override FailoverConfig) FailoverConfig {
+	if override.Failback != "" {
+		base.Failback = override.Failback
+	}
+	if override.HealthInterval != 0 {
+		base.HealthInterval = override.HealthInterval
+	}
+	return base
+}
+
+// EndpointList returns the endpoints to try in order: the endpoints list
+// when set, else the single endpoint.
+func (c ClientConfig) EndpointList() []string {
+	if len(c.Endpoints) > 0 {
+		return c.Endpoints
+	}
+	if c.Endpoint != "" {
+		return []string{c.Endpoint}
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"strings"
+	"time"
+
+	"opc-xml-da-cli/internal/httpauth"
+)
+
+// Compression modes for http.compression.
+const (
+	// CompressionAuto keeps Go's default of asking for gzip responses and
+	// sends requests as is.
+	CompressionAuto = "auto"
+	// CompressionGzip also gzips request bodies, the only part Go does not
+	// do on its own.
+	CompressionGzip = "gzip"
+	// CompressionNone neither asks for nor sends compressed bodies.
+	CompressionNone = "none"
+)
+
+// HTTPConfig tunes the HTTP client that carries SOAP calls: the outbound
+// proxy, the connection pool, compression, and extra request headers.
+type HTTPConfig struct {
+	Proxy               string            `yaml:"proxy,omitempty"`
+	ProxyUsername       string            `yaml:"proxy_username,omitempty"`
+	ProxyPassword       string            `yaml:"proxy_password,omitempty"`
+	MaxIdleConnsPerHost int               `yaml:"max_idle_conns_per_host,omitempty"`
+	MaxConnsPerHost     int               `yaml:"max_conns_per_host,omitempty"`
+	IdleConnTimeout     time.Duration     `yaml:"idle_conn_timeout,omitempty"`
+	DisableKeepAlives   bool              `yaml:"disable_keep_alives,omitempty"`
+	Compression         string            `yaml:"compression,omitempty"`
+	Headers             map[string]string `yaml:"headers,omitempty"`
+}
+
+func (h *HTTPConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type rawHTTPConfig struct {
+		Proxy               string            `yaml:"proxy,omitempty"`
+		ProxyUsername       string            `yaml:"proxy_username,omitempty"`
+		ProxyPassword       string            `yaml:"proxy_password,omitempty"`
+		MaxIdleConnsPerHost int               `yaml:"max_idle_conns_per_host,omitempty"`
+		MaxConnsPerHost     int               `yaml:"max_conns_per_host,omitempty"`
+		IdleConnTimeout     string            `yaml:"idle_conn_timeout,omitempty"`
+		DisableKeepAlives   bool              `yaml:"disable_keep_alives,omitempty"`
+		Compression         string            `yaml:"compression,omitempty"`
+		Headers             map[string]string `yaml:"headers,omitempty"`
+	}
+	var raw rawHTTPConfig
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	idle, err := parseOptionalDuration("http.idle_conn_timeout", raw.IdleConnTimeout)
+	if err != nil {
+		return err
+	}
+	*h = HTTPConfig{
+		Proxy:               raw.Proxy,
+		ProxyUsername:       raw.ProxyUsername,
+		ProxyPassword:       raw.ProxyPassword,
+		MaxIdleConnsPerHost: raw.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     raw.MaxConnsPerHost,
+		IdleConnTimeout:     idle,
+		DisableKeepAlives:   raw.DisableKeepAlives,
+		Compression:         raw.Compression,
+		Headers:             raw.Headers,
+	}
+	return nil
+}
+
+// Validate checks the proxy URL, pool limits, compression mode, and header
+// names.
+func (h HTTPConfig) Validate() error {
+	if h.Proxy != "" {
+		if _, err := ParseProxyURL(h.Proxy); err != nil {
+			return err
+		}
+	} else if h.ProxyUsername != "" || h.ProxyPassword != "" {
+		return errors.New("http.proxy_username and http.proxy_password need http.proxy")
+	}
+	if h.MaxIdleConnsPerHost < 0 || h.MaxConnsPerHost < 0 {
+		return errors.New("http.max_idle_conns_per_host and http.max_conns_per_host must be zero or greater")
+	}
+	if h.IdleConnTimeout < 0 {
+		return errors.New("http.idle_conn_timeout must be zero or greater")
+	}
+	switch h.Compression {
+	case "", CompressionAuto, CompressionGzip, CompressionNone:
+	default:
+		return fmt.Errorf("http.compression %q is not one of auto, gzip, none", h.Compression)
+	}
+	for name := range h.Headers {
+		if name == "" || strings.ContainsAny(name, " \t:\r\n") {
+			return fmt.Errorf("http.headers: %q is not a valid header name", name)
+		}
+	}
+	return nil
+}
+
+// ValidateAuth checks the settings that depend on the auth scheme. NTLM
+// authenticates the connection rather than each request, so it cannot work
+// with keep-alives disabled.
+func (h HTTPConfig) ValidateAuth(auth string) error {
+	if scheme, _ := httpauth.ParseScheme(auth); scheme == httpauth.SchemeNTLM && h.DisableKeepAlives {
+		return errors.New("http.disable_keep_alives cannot be used with auth ntlm, which authenticates the connection")
+	}
+	return nil
+}
+
+// ParseProxyURL parses an http, https, or socks5 proxy URL.
+func ParseProxyURL(proxy string) (*url.URL, error) {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("http.proxy %q is not a valid URL: %w", proxy, errors.Unwrap(err))
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("http.proxy %q must use http, https, or socks5", proxy)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("http.proxy %q has no host", proxy)
+	}
+	return u, nil
+}
+
+// redactProxy hides a password written into the proxy URL.
+func redactProxy(proxy string) string {
+	if u, err := url.Parse(proxy); err == nil && u.User != nil {
+		return u.Redacted()
+	}
+	return proxy
+}
+
+// resolveHTTPSecrets resolves secret references in the proxy password and
+// the header values.
+func resolveHTTPSecrets(h HTTPConfig) (HTTPConfig, error) {
+	var err error
+	if h.ProxyPassword, err = ResolveSecret(h.ProxyPassword); err != nil {
+		return HTTPConfig{}, fmt.Errorf("resolve http.proxy_password: %w", err)
+	}
+	if len(h.Headers) == 0 {
+		return h, nil
+	}
+	headers := maps.Clone(h.Headers)
+	for name, value := range headers {
+		if headers[name], err = ResolveSecret(value); err != nil {
+			return HTTPConfig{}, fmt.Errorf("resolve http.headers.%s: %w", name, err)
+		}
+	}
+	h.Headers = headers
+	return h, nil
+}
+
+func mergeHTTPConfig(base, override HTTPConfig) HTTPConfig {
+	if override.Proxy != "" {
+		base.Proxy = override.Proxy
+	}
+	if override.ProxyUsername != "" {
+		base.ProxyUsername = override.ProxyUsername
+	}
+	if override.ProxyPassword != "" {
+		base.ProxyPassword = override.ProxyPassword
+	}
+	if override.MaxIdleConnsPerHost != 0 {
+		base.MaxIdleConnsPerHost = override.MaxIdleConnsPerHost
+	}
+	if override.MaxConnsPerHost != 0 {
+		base.MaxConnsPerHost = override.MaxConnsPerHost
+	}
+	if override.IdleConnTimeout != 0 {
+		base.IdleConnTimeout = override.IdleConnTimeout
+	}
+	if override.DisableKeepAlives {
+		base.DisableKeepAlives = true
+	}
+	if override.Compression != "" {
+		base.Compression = override.Compression
+	}
+	base.Headers = mergeVars(base.Headers, override.Headers)
+	return base
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ProfileChain returns the profiles that name extends, root first and
+// ending with name itself.
+func (f FileConfig) ProfileChain(name string) ([]string, error) {
+	var chain []string
+	for current := name; current != ""; current = f.Profiles[current].Extends {
+		if slices.Contains(chain, current) {
+			cycle := append(chain[slices.Index(chain, current):], current)
+			return nil, fmt.Errorf("profile %q: extends cycle %s", name, strings.Join(cycle, " -> "))
+		}
+		if _, ok := f.Profiles[current]; !ok {
+			if current == name {
+				return nil, fmt.Errorf("profile %q not found", name)
+			}
+			return nil, fmt.Errorf("profile %q extends unknown profile %q", chain[len(chain)-1], current)
+		}
+		chain = append(chain, current)
+	}
+	slices.Reverse(chain)
+	return chain, nil
+}
+
+// ResolveProfile merges the top-level settings with the profile chain of
+// name and expands ${var} references. An empty name resolves the top level
+// alone. Defaults, the environment, and secret references are not applied.
+func (f FileConfig) ResolveProfile(name string) (ClientConfig, error) {
+	cfg := f.ClientConfig
+	if name != "" {
+		chain, err := f.ProfileChain(name)
+		if err != nil {
+			return ClientConfig{}, err
+		}
+		for _, link := range chain {
+			cfg = mergeClientConfig(cfg, f.Profiles[link])
+		}
+	}
+	return expandVars(cfg)
+}
+
+var varPattern = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// expandVars replaces ${name} in every string setting except the passwords
+// with the merged vars, so that a template profile can be reused across
+// sites.
+func expandVars(cfg ClientConfig) (ClientConfig, error) {
+	var missing []string
+	expand := func(key string, value *string) {
+		*value = varPattern.ReplaceAllStringFunc(*value, func(ref string) string {
+			name := ref[2 : len(ref)-1]
+			if v, ok := cfg.Vars[name]; ok {
+				return v
+			}
+			missing = append(missing, fmt.Sprintf("${%s} in %s", name, key))
+			return ref
+		})
+	}
+	expand("endpoint", &cfg.Endpoint)
+	cfg.Endpoints = slices.Clone(cfg.Endpoints)
+	for i := range cfg.Endpoints {
+		expand("endpoints", &cfg.Endpoints[i])
+	}
+	expand("username", &cfg.Username)
+	expand("auth", &cfg.Auth)
+	expand("locale", &cfg.Locale)
+	expand("client_handle", &cfg.ClientHandle)
+	expand("tls.ca_file", &cfg.TLS.CAFile)
+	expand("tls.cert_file", &cfg.TLS.CertFile)
+	expand("tls.key_file", &cfg.TLS.KeyFile)
+	expand("tls.server_name", &cfg.TLS.ServerName)
+	expand("tls.min_version", &cfg.TLS.MinVersion)
+	cfg.TLS.PinSHA256 = slices.Clone(cfg.TLS.PinSHA256)
+	for i := range cfg.TLS.PinSHA256 {
+		expand("tls.pin_sha256", &cfg.TLS.PinSHA256[i])
+	}
+	expand("quirks.timestamp_zone", &cfg.Quirks.TimestampZone)
+	expand("http.proxy", &cfg.HTTP.Proxy)
+	expand("http.proxy_username", &cfg.HTTP.ProxyUsername)
+	cfg.HTTP.Headers = maps.Clone(cfg.HTTP.Headers)
+	for name, value := range cfg.HTTP.Headers {
+		expand("http.headers."+name, &value)
+		cfg.HTTP.Headers[name] = value
+	}
+	if len(missing) > 0 {
+		return ClientConfig{}, fmt.Errorf("undefined variable %s", strings.Join(missing, ", "))
+	}
+	return cfg, nil
+}
+
+func mergeVars(base, override map[string]string) map[string]string {
+	if len(override) == 0 {
+		return base
+	}
+	merged := maps.Clone(base)
+	if merged == nil {
+		merged = map[string]string{}
+	}
+	maps.Copy(merged, override)
+	return merged
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"opc-xml-da-cli/service"
+)
+
+// QuirksConfig adapts the client to a server that deviates from the OPC
+// XML-DA WSDL. Preset names a built-in quirk set; the other settings are
+// added on top of it.
+type QuirksConfig struct {
+	Preset                          string `yaml:"preset,omitempty"`
+	SOAPAction                      string `yaml:"soap_action,omitempty"`
+	NamespacePrefix                 string `yaml:"namespace_prefix,omitempty"`
+	SendEmptyItemPath               bool   `yaml:"send_empty_item_path,omitempty"`
+	LenientNamespaces               bool   `yaml:"lenient_namespaces,omitempty"`
+	TimestampZone                   string `yaml:"timestamp_zone,omitempty"`
+	ContinuationWithoutMoreElements bool   `yaml:"continuation_without_more_elements,omitempty"`
+	ContinuationOmitsItem           bool   `yaml:"continuation_omits_item,omitempty"`
+}
+
+// Validate checks that the settings resolve to service quirks.
+func (q QuirksConfig) Validate() error {
+	_, err := q.Quirks()
+	return err
+}
+
+// Quirks resolves the preset, the time zone, and the overrides into the
+// quirks the service layer applies.
+func (q QuirksConfig) Quirks() (service.Quirks, error) {
+	var quirks service.Quirks
+	if q.Preset != "" {
+		preset, ok := service.QuirksPresets[q.Preset]
+		if !ok {
+			return service.Quirks{}, fmt.Errorf("quirks.preset %q is not one of %s", q.Preset, strings.Join(service.QuirksPresetNames(), ", "))
+		}
+		quirks = preset
+	}
+	switch q.SOAPAction {
+	case "":
+	case service.SOAPActionURI, service.SOAPActionQuoted, service.SOAPActionOperation:
+		quirks.SOAPAction = q.SOAPAction
+	default:
+		return service.Quirks{}, fmt.Errorf("quirks.soap_action %q is not one of uri, quoted, operation", q.SOAPAction)
+	}
+	if q.NamespacePrefix != "" {
+		if strings.ContainsAny(q.NamespacePrefix, ": \t<>\"'=/") {
+			return service.Quirks{}, fmt.Errorf("quirks.namespace_prefix %q is not an XML name", q.NamespacePrefix)
+		}
+		quirks.NamespacePrefix = q.NamespacePrefix
+	}
+	if q.TimestampZone != "" {
+		loc := time.Local
+		if !strings.EqualFold(q.TimestampZone, "local") {
+			var err error
+			if loc, err = time.LoadLocation(q.TimestampZone); err != nil {
+				return service.Quirks{}, fmt.Errorf("quirks.timestamp_zone %q: %w", q.TimestampZone, err)
+			}
+		}
+		quirks.TimestampZone = loc
+	}
+	quirks.SendEmptyItemPath = quirks.SendEmptyItemPath || q.SendEmptyItemPath
+	quirks.LenientNamespaces = quirks.LenientNamespaces || q.LenientNamespaces
+	quirks.ContinuationWithoutMoreElements = quirks.ContinuationWithoutMoreElements || q.ContinuationWithoutMoreElements
+	quirks.ContinuationOmitsItem = quirks.ContinuationOmitsItem || q.ContinuationOmitsItem
+	return quirks, nil
+}
+
+func mergeQuirksConfig(base, override QuirksConfig) QuirksConfig {
+	if override.Preset != "" {
+		base.Preset = override.Preset
+	}
+	if override.SOAPAction != "" {
+		base.SOAPAction = override.SOAPAction
+	}
+	if override.NamespacePrefix != "" {
+		base.NamespacePrefix = override.NamespacePrefix
+	}
+	if override.SendEmptyItemPath {
+		base.SendEmptyItemPath = true
+	}
+	if override.LenientNamespaces {
+		base.LenientNamespaces = true
+	}
+	if override.TimestampZone != "" {
+		base.TimestampZone = override.TimestampZone
+	}
+	if override.ContinuationWithoutMoreElements {
+		base.ContinuationWithoutMoreElements = true
+	}
+	if override.ContinuationOmitsItem {
+		base.ContinuationOmitsItem = true
+	}
+	return base
+}
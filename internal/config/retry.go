@@ -0,0 +1,67 @@
+package config
+
+import (
+	"errors"
+	"time"
+)
+
+// Retry defaults used when the retry section leaves a setting out.
+const (
+	DefaultRetryInitialBackoff = 500 * time.Millisecond
+	DefaultRetryMaxBackoff     = 10 * time.Second
+)
+
+// RetryConfig controls how often idempotent requests are retried after a
+// transient failure. MaxAttempts counts the first try, so zero and one both
+// mean no retries. Backoff doubles from InitialBackoff up to MaxBackoff.
+type RetryConfig struct {
+	MaxAttempts    int           `yaml:"max_attempts,omitempty"`
+	InitialBackoff time.Duration `yaml:"initial_backoff,omitempty"`
+	MaxBackoff     time.Duration `yaml:"max_backoff,omitempty"`
+}
+
+func (r *RetryConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type rawRetryConfig struct {
+		MaxAttempts    int    `yaml:"max_attempts,omitempty"`
+		InitialBackoff string `yaml:"initial_backoff,omitempty"`
+		MaxBackoff     string `yaml:"max_backoff,omitempty"`
+	}
+	var raw rawRetryConfig
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	initial, err := parseOptionalDuration("retry.initial_backoff", raw.InitialBackoff)
+	if err != nil {
+		return err
+	}
+	maxBackoff, err := parseOptionalDuration("retry.max_backoff", raw.MaxBackoff)
+	if err != nil {
+		return err
+	}
+	*r = RetryConfig{MaxAttempts: raw.MaxAttempts, InitialBackoff: initial, MaxBackoff: maxBackoff}
+	return nil
+}
+
+// Validate checks that the retry settings are not negative.
+func (r RetryConfig) Validate() error {
+	if r.MaxAttempts < 0 {
+		return errors.New("retry.max_attempts must be zero or greater")
+	}
+	if r.InitialBackoff < 0 || r.MaxBackoff < 0 {
+		return errors.New("retry.initial_backoff and retry.max_backoff must be zero or greater")
+	}
+	return nil
+}
+
+func mergeRetryConfig(base, override RetryConfig) RetryConfig {
+	if override.MaxAttempts != 0 {
+		base.MaxAttempts = override.MaxAttempts
+	}
+	if override.InitialBackoff != 0 {
+		base.InitialBackoff = override.InitialBackoff
+	}
+	if override.MaxBackoff != 0 {
+		base.MaxBackoff = override.MaxBackoff
+	}
+	return base
+}
@@ -0,0 +1,193 @@
+package config
+
+import (
+	"opc-xml-da-cli/internal/httpauth"
+	"opc-xml-da-cli/service"
+)
+
+const durationPattern = `^(0|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)$`
+
+// Schema returns a JSON Schema (draft 2020-12) for the config file, for
+// editors and CI checks. It describes the same keys LoadFile accepts.
+func Schema() map[string]any {
+	file := settingsSchema()
+	file["vars"] = varsSchema()
+	file["default_profile"] = map[string]any{"type": "string", "description": "profile used when neither --profile nor OPCXMLDA_PROFILE is set"}
+	file["profiles"] = map[string]any{
+		"type":                 "object",
+		"description":          "named profiles layered over the top-level settings",
+		"additionalProperties": map[string]any{"$ref": "#/$defs/profile"},
+	}
+
+	profile := settingsSchema()
+	profile["extends"] = map[string]any{"type": "string", "description": "parent profile whose settings this profile inherits"}
+	profile["vars"] = varsSchema()
+
+	return map[string]any{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"title":                "opc-xml-da-cli config",
+		"type":                 "object",
+		"properties":           file,
+		"additionalProperties": false,
+		"$defs": map[string]any{
+			"profile": map[string]any{
+				"type":                 "object",
+				"properties":           profile,
+				"additionalProperties": false,
+			},
+			"endpoint": map[string]any{
+				"type":        "string",
+				"description": "OPC XML-DA service URL",
+				"pattern":     `^(https?://[^/?#]+|.*\$\{[^}]*\}.*)`,
+			},
+			"duration": map[string]any{
+				"type":        "string",
+				"description": "Go duration such as 500ms, 30s, or 1m30s",
+				"pattern":     durationPattern,
+			},
+		},
+	}
+}
+
+func settingsSchema() map[string]any {
+	str := func(description string) map[string]any {
+		return map[string]any{"type": "string", "description": description}
+	}
+	return map[string]any{
+		"endpoint": map[string]any{"$ref": "#/$defs/endpoint"},
+		"endpoints": map[string]any{
+			"type":        "array",
+			"description": "primary and backup service URLs, tried in order; replaces endpoint",
+			"minItems":    1,
+			"items":       map[string]any{"$ref": "#/$defs/endpoint"},
+		},
+		"username": str("user name for HTTP or WS-Security authentication"),
+		"password": str("password, or an env:, file:, or cmd: secret reference"),
+		"auth": map[string]any{
+			"type":        "string",
+			"description": "authentication scheme",
+			"enum":        []string{httpauth.SchemeBasic, httpauth.SchemeDigest, httpauth.SchemeNTLM, httpauth.SchemeWSSE, httpauth.SchemeWSSEDigest},
+		},
+		"locale":          str("SOAP LocaleID"),
+		"client_handle":   str("SOAP ClientRequestHandle"),
+		"http_timeout":    map[string]any{"$ref": "#/$defs/duration"},
+		"request_timeout": map[string]any{"$ref": "#/$defs/duration"},
+		"failover": map[string]any{
+			"type":                 "object",
+			"additionalProperties": false,
+			"properties": map[string]any{
+				"failback": map[string]any{
+					"type":        "string",
+					"description": "return to an earlier endpoint once healthy (auto) or stay on the backup (never)",
+					"enum":        []string{FailbackAuto, FailbackNever},
+				},
+				"health_interval": map[string]any{"$ref": "#/$defs/duration"},
+			},
+		},
+		"retry": map[string]any{
+			"type":                 "object",
+			"additionalProperties": false,
+			"properties": map[string]any{
+				"max_attempts": map[string]any{
+					"type":        "integer",
+					"description": "tries per idempotent request, including the first; 0 or 1 disables retries",
+					"minimum":     0,
+				},
+				"initial_backoff": map[string]any{"$ref": "#/$defs/duration"},
+				"max_backoff":     map[string]any{"$ref": "#/$defs/duration"},
+			},
+		},
+		"http": map[string]any{
+			"type":                 "object",
+			"additionalProperties": false,
+			"properties": map[string]any{
+				"proxy": map[string]any{
+					"type":        "string",
+					"description": "outbound proxy URL; defaults to HTTP_PROXY, HTTPS_PROXY, and NO_PROXY",
+					"pattern":     `^((https?|socks5)://[^/?#]+|.*\$\{[^}]*\}.*)`,
+				},
+				"proxy_username": str("proxy user name"),
+				"proxy_password": str("proxy password, or an env:, file:, or cmd: secret reference"),
+				"max_idle_conns_per_host": map[string]any{
+					"type":        "integer",
+					"description": "idle connections kept open per server; 0 keeps the Go default of 2",
+					"minimum":     0,
+				},
+				"max_conns_per_host": map[string]any{
+					"type":        "integer",
+					"description": "connections open at once per server; 0 means no limit",
+					"minimum":     0,
+				},
+				"idle_conn_timeout":   map[string]any{"$ref": "#/$defs/duration"},
+				"disable_keep_alives": map[string]any{"type": "boolean", "description": "open a new connection for every request"},
+				"compression": map[string]any{
+					"type":        "string",
+					"description": "gzip responses only, as Go does by default (auto), also gzip request bodies (gzip), or neither (none)",
+					"enum":        []string{CompressionAuto, CompressionGzip, CompressionNone},
+				},
+				"headers": map[string]any{
+					"type":                 "object",
+					"description":          "extra HTTP request headers; values may be secret references",
+					"additionalProperties": map[string]any{"type": "string"},
+				},
+			},
+		},
+		"quirks": map[string]any{
+			"type":                 "object",
+			"additionalProperties": false,
+			"properties": map[string]any{
+				"preset": map[string]any{
+					"type":        "string",
+					"description": "built-in quirk set; the other quirks settings are added on top",
+					"enum":        service.QuirksPresetNames(),
+				},
+				"soap_action": map[string]any{
+					"type":        "string",
+					"description": "SOAPAction header as the action URI (uri), the quoted URI (quoted), or the operation name (operation)",
+					"enum":        []string{service.SOAPActionURI, service.SOAPActionQuoted, service.SOAPActionOperation},
+				},
+				"namespace_prefix":     str("write request elements as prefix:Name instead of in a default namespace"),
+				"send_empty_item_path": map[string]any{"type": "boolean", "description": "send ItemPath=\"\" instead of omitting an empty item path"},
+				"lenient_namespaces":   map[string]any{"type": "boolean", "description": "accept response elements in any namespace"},
+				"timestamp_zone":       str("zone of response timestamps without an offset, such as UTC or Europe/Berlin; defaults to local"),
+				"continuation_without_more_elements": map[string]any{
+					"type":        "boolean",
+					"description": "keep browsing while a ContinuationPoint comes back, even if MoreElements is false",
+				},
+				"continuation_omits_item": map[string]any{
+					"type":        "boolean",
+					"description": "leave ItemPath and ItemName out of Browse requests that carry a ContinuationPoint",
+				},
+			},
+		},
+		"tls": map[string]any{
+			"type":                 "object",
+			"additionalProperties": false,
+			"properties": map[string]any{
+				"ca_file":     str("PEM bundle of trusted CAs"),
+				"cert_file":   str("PEM client certificate; requires key_file"),
+				"key_file":    str("PEM client key; requires cert_file"),
+				"server_name": str("server name for SNI and verification"),
+				"min_version": map[string]any{
+					"type":        "string",
+					"description": "minimum TLS version",
+					"pattern":     `^([Tt][Ll][Ss])?1\.?[0-3]$`,
+				},
+				"insecure_skip_verify": map[string]any{"type": "boolean", "description": "skip certificate verification"},
+				"pin_sha256": map[string]any{
+					"type":        "array",
+					"description": "SHA-256 public key pins as sha256/<base64> or hex",
+					"items":       map[string]any{"type": "string"},
+				},
+			},
+		},
+	}
+}
+
+func varsSchema() map[string]any {
+	return map[string]any{
+		"type":                 "object",
+		"description":          "values for ${name} references in string settings",
+		"additionalProperties": map[string]any{"type": []string{"string", "number", "boolean"}},
+	}
+}
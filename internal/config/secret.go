@@ -0,0 +1,114 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// Secret reference prefixes accepted in passwords and HTTP header values.
+const (
+	secretEnvPrefix  = "env:"
+	secretFilePrefix = "file:"
+	secretCmdPrefix  = "cmd:"
+	// secretLiteralPrefix escapes a literal secret that itself starts with
+	// one of the prefixes above.
+	secretLiteralPrefix = "literal:"
+)
+
+// IsSecretRef reports whether value names a secret source instead of
+// holding the secret itself.
+func IsSecretRef(value string) bool {
+	return strings.HasPrefix(value, secretEnvPrefix) || strings.HasPrefix(value, secretFilePrefix) || strings.HasPrefix(value, secretCmdPrefix)
+}
+
+// ResolveSecret returns the secret value refers to:
+//
+//	env:NAME          the environment variable NAME
+//	file:/path        the file contents, without a trailing newline
+//	cmd:prog args...  the first line printed by prog, run without a shell
+//	literal:text      text itself, for a secret that starts with a prefix
+//
+// Any other value is returned unchanged as a literal.
+func ResolveSecret(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, secretLiteralPrefix):
+		return strings.TrimPrefix(value, secretLiteralPrefix), nil
+	case strings.HasPrefix(value, secretEnvPrefix):
+		name := strings.TrimPrefix(value, secretEnvPrefix)
+		secret, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return secret, nil
+	case strings.HasPrefix(value, secretFilePrefix):
+		data, err := os.ReadFile(strings.TrimPrefix(value, secretFilePrefix))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	case strings.HasPrefix(value, secretCmdPrefix):
+		args := strings.Fields(strings.TrimPrefix(value, secretCmdPrefix))
+		if len(args) == 0 {
+			return "", errors.New("cmd: needs a command")
+		}
+		var stderr bytes.Buffer
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return "", fmt.Errorf("run %s: %w: %s", args[0], err, msg)
+			}
+			return "", fmt.Errorf("run %s: %w", args[0], err)
+		}
+		line, _, _ := strings.Cut(string(out), "\n")
+		return strings.TrimRight(line, "\r"), nil
+	default:
+		return value, nil
+	}
+}
+
+// PlaintextSecrets lists the config keys, such as "password" or
+// "profiles.site-a.password", whose secrets are written literally. Header
+// values count when the header name suggests a credential.
+func PlaintextSecrets(cfg FileConfig) []string {
+	keys := plaintextSecrets("", cfg.ClientConfig)
+	for name, profile := range cfg.Profiles {
+		keys = append(keys, plaintextSecrets("profiles."+name+".", profile)...)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func plaintextSecrets(prefix string, cfg ClientConfig) []string {
+	var keys []string
+	if cfg.Password != "" && !IsSecretRef(cfg.Password) {
+		keys = append(keys, prefix+"password")
+	}
+	if cfg.HTTP.ProxyPassword != "" && !IsSecretRef(cfg.HTTP.ProxyPassword) {
+		keys = append(keys, prefix+"http.proxy_password")
+	}
+	for name, value := range cfg.HTTP.Headers {
+		if value != "" && !IsSecretRef(value) && credentialHeader(name) {
+			keys = append(keys, prefix+"http.headers."+name)
+		}
+	}
+	return keys
+}
+
+// credentialHeader reports whether a header name looks like it carries a
+// credential, such as Authorization, Cookie, or X-Api-Key.
+func credentialHeader(name string) bool {
+	name = strings.ToLower(name)
+	for _, hint := range []string{"auth", "cookie", "key", "token", "secret", "password"} {
+		if strings.Contains(name, hint) {
+			return true
+		}
+	}
+	return false
+}
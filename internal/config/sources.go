@@ -0,0 +1,205 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Sources maps config keys, such as "endpoint" or "tls.ca_file", to the
+// layer that supplied their effective value: "default", "file", "profile
+// <name>", "env <VAR>", or "flag --<name>" once the CLI applies its flags.
+type Sources map[string]string
+
+// Field is one effective setting for display.
+type Field struct {
+	Key   string
+	Value string
+}
+
+// Fields lists the settings of cfg that are set, in config file order.
+// Passwords are redacted, and headers are listed by name only because their
+// values often carry API keys.
+func Fields(cfg ClientConfig) []Field {
+	var fields []Field
+	add := func(key, value string) {
+		if value != "" {
+			fields = append(fields, Field{Key: key, Value: value})
+		}
+	}
+	add("endpoint", cfg.Endpoint)
+	add("endpoints", strings.Join(cfg.Endpoints, ","))
+	add("username", cfg.Username)
+	if cfg.Password != "" {
+		add("password", "<redacted>")
+	}
+	add("auth", cfg.Auth)
+	add("locale", cfg.Locale)
+	add("client_handle", cfg.ClientHandle)
+	if cfg.HTTPTimeout != 0 {
+		add("http_timeout", cfg.HTTPTimeout.String())
+	}
+	if cfg.RequestTimeout != 0 {
+		add("request_timeout", cfg.RequestTimeout.String())
+	}
+	add("tls.ca_file", cfg.TLS.CAFile)
+	add("tls.cert_file", cfg.TLS.CertFile)
+	add("tls.key_file", cfg.TLS.KeyFile)
+	add("tls.server_name", cfg.TLS.ServerName)
+	add("tls.min_version", cfg.TLS.MinVersion)
+	if cfg.TLS.InsecureSkipVerify {
+		add("tls.insecure_skip_verify", strconv.FormatBool(true))
+	}
+	add("tls.pin_sha256", strings.Join(cfg.TLS.PinSHA256, ","))
+	add("http.proxy", redactProxy(cfg.HTTP.Proxy))
+	add("http.proxy_username", cfg.HTTP.ProxyUsername)
+	if cfg.HTTP.ProxyPassword != "" {
+		add("http.proxy_password", "<redacted>")
+	}
+	if cfg.HTTP.MaxIdleConnsPerHost != 0 {
+		add("http.max_idle_conns_per_host", strconv.Itoa(cfg.HTTP.MaxIdleConnsPerHost))
+	}
+	if cfg.HTTP.MaxConnsPerHost != 0 {
+		add("http.max_conns_per_host", strconv.Itoa(cfg.HTTP.MaxConnsPerHost))
+	}
+	if cfg.HTTP.IdleConnTimeout != 0 {
+		add("http.idle_conn_timeout", cfg.HTTP.IdleConnTimeout.String())
+	}
+	if cfg.HTTP.DisableKeepAlives {
+		add("http.disable_keep_alives", strconv.FormatBool(true))
+	}
+	add("http.compression", cfg.HTTP.Compression)
+	add("http.headers", strings.Join(slices.Sorted(maps.Keys(cfg.HTTP.Headers)), ","))
+	add("quirks.preset", cfg.Quirks.Preset)
+	add("quirks.soap_action", cfg.Quirks.SOAPAction)
+	add("quirks.namespace_prefix", cfg.Quirks.NamespacePrefix)
+	if cfg.Quirks.SendEmptyItemPath {
+		add("quirks.send_empty_item_path", strconv.FormatBool(true))
+	}
+	if cfg.Quirks.LenientNamespaces {
+		add("quirks.lenient_namespaces", strconv.FormatBool(true))
+	}
+	add("quirks.timestamp_zone", cfg.Quirks.TimestampZone)
+	if cfg.Quirks.ContinuationWithoutMoreElements {
+		add("quirks.continuation_without_more_elements", strconv.FormatBool(true))
+	}
+	if cfg.Quirks.ContinuationOmitsItem {
+		add("quirks.continuation_omits_item", strconv.FormatBool(true))
+	}
+	add("failover.failback", cfg.Failover.Failback)
+	if cfg.Failover.HealthInterval != 0 {
+		add("failover.health_interval", cfg.Failover.HealthInterval.String())
+	}
+	if cfg.Retry.MaxAttempts != 0 {
+		add("retry.max_attempts", strconv.Itoa(cfg.Retry.MaxAttempts))
+	}
+	if cfg.Retry.InitialBackoff != 0 {
+		add("retry.initial_backoff", cfg.Retry.InitialBackoff.String())
+	}
+	if cfg.Retry.MaxBackoff != 0 {
+		add("retry.max_backoff", cfg.Retry.MaxBackoff.String())
+	}
+	return fields
+}
+
+// Effective is a loaded client config with its selected profile and the
+// source of every value. File is the config file as parsed, before profiles,
+// variables, and the environment are applied.
+type Effective struct {
+	ClientConfig
+	Profile string
+	Sources Sources
+	File    FileConfig
+}
+
+// LoadOptions selects the layers Load combines.
+type LoadOptions struct {
+	Path    string
+	Profile string
+	// OptionalFile treats a missing config file as empty.
+	OptionalFile bool
+	// LookupEnv reads the OPCXMLDA_* overlay; nil skips it.
+	LookupEnv func(string) (string, bool)
+	// ResolveSecrets resolves env:, file:, and cmd: references. Commands
+	// that only inspect the config leave it off, so they neither run cmd:
+	// programs nor need the referenced variables and files.
+	ResolveSecrets bool
+}
+
+// Load builds the effective client config with precedence env > profile >
+// file > defaults, and reports where each value came from. The profile is
+// opts.Profile, else OPCXMLDA_PROFILE, else default_profile, layered over
+// the profiles it extends. ${var} references are expanded before the
+// environment overlay, and secret references in passwords and header values
+// are resolved last when opts.ResolveSecrets is set.
+func Load(opts LoadOptions) (Effective, error) {
+	fileCfg, err := LoadFile(opts.Path)
+	if err != nil {
+		if !opts.OptionalFile || !errors.Is(err, fs.ErrNotExist) {
+			return Effective{}, err
+		}
+		fileCfg = FileConfig{}
+	}
+	sources := Sources{}
+	selected := fileCfg.ClientConfig
+	markSources(sources, selected, "file")
+	defaults := DefaultClientConfig()
+	if selected.HTTPTimeout == 0 {
+		selected.HTTPTimeout = defaults.HTTPTimeout
+		sources["http_timeout"] = "default"
+	}
+	if selected.RequestTimeout == 0 {
+		selected.RequestTimeout = defaults.RequestTimeout
+		sources["request_timeout"] = "default"
+	}
+
+	profile, profileSource := opts.Profile, "flag --profile"
+	if profile == "" && opts.LookupEnv != nil {
+		if value, ok := opts.LookupEnv(EnvProfile); ok && value != "" {
+			profile, profileSource = value, "env "+EnvProfile
+		}
+	}
+	if profile == "" {
+		profile, profileSource = fileCfg.DefaultProfile, "file default_profile"
+	}
+	if profile != "" {
+		chain, err := fileCfg.ProfileChain(profile)
+		if err != nil {
+			return Effective{}, err
+		}
+		for _, name := range chain {
+			profileCfg := fileCfg.Profiles[name]
+			selected = mergeClientConfig(selected, profileCfg)
+			markSources(sources, profileCfg, "profile "+name)
+		}
+		sources["profile"] = profileSource
+	}
+	if selected, err = expandVars(selected); err != nil {
+		return Effective{}, err
+	}
+
+	if opts.LookupEnv != nil {
+		if selected, err = applyEnv(selected, sources, opts.LookupEnv); err != nil {
+			return Effective{}, err
+		}
+	}
+	if opts.ResolveSecrets {
+		if selected.Password, err = ResolveSecret(selected.Password); err != nil {
+			return Effective{}, fmt.Errorf("resolve password: %w", err)
+		}
+		if selected.HTTP, err = resolveHTTPSecrets(selected.HTTP); err != nil {
+			return Effective{}, err
+		}
+	}
+	return Effective{ClientConfig: selected, Profile: profile, Sources: sources, File: fileCfg}, nil
+}
+
+func markSources(sources Sources, cfg ClientConfig, source string) {
+	for _, field := range Fields(cfg) {
+		sources[field.Key] = source
+	}
+}
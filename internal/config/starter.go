@@ -6,20 +6,73 @@ endpoint: http://localhost/OPC/DA
 http_timeout: 30s
 request_timeout: 90s
 
+# A primary/backup server pair can replace endpoint with a list. Calls fail
+# over to the next healthy endpoint and fail back to the primary (auto) or
+# stay on the backup (never).
+# endpoints: [http://opc-a/OPC/DA, http://opc-b/OPC/DA]
+# failover:
+#   failback: auto
+#   health_interval: 30s
+
+# Reads, browses, and status requests can be retried after transport errors,
+# HTTP 5xx responses, or a commFault server state. max_attempts counts the
+# first try; waits double from initial_backoff up to max_backoff.
+# retry:
+#   max_attempts: 3
+#   initial_backoff: 500ms
+#   max_backoff: 10s
+
 # Optional SOAP locale and client request handle.
 # locale: en-US
 # client_handle: opc-xml-da-cli
 
-# Optional Basic authentication.
+# Optional authentication: basic (default), digest, ntlm, or WS-Security
+# UsernameToken headers with wsse (plain password) or wsse-digest.
+# NTLM usernames may be written as DOMAIN\user or user@domain.
+# auth: basic
 # username: user
-# password: secret
+# password: env:OPC_PASS   # or file:/path, cmd:program args, or a literal
+
+# Optional HTTPS settings.
+# tls:
+#   ca_file: internal-ca.pem
+#   cert_file: client.pem
+#   key_file: client-key.pem
+#   min_version: "1.2"
+#   pin_sha256: [sha256/AAAA...]
+#   insecure_skip_verify: false
+
+# Optional HTTP client settings: outbound proxy, connection pool, request
+# compression (gzip also compresses request bodies; auto keeps the usual
+# gzip responses; none turns both off), and extra request headers.
+# http:
+#   proxy: http://proxy.plant.local:3128
+#   proxy_username: svc-opc
+#   proxy_password: env:PROXY_PASS
+#   max_idle_conns_per_host: 4
+#   idle_conn_timeout: 90s
+#   compression: auto
+#   headers:
+#     X-Site: line-3
 
-# Optional named profiles.
+# Servers that deviate from the WSDL can be handled with a quirks preset
+# (wsdl, soap11-strict, dcom-wrapper, lenient) and individual overrides.
+# quirks:
+#   preset: dcom-wrapper
+#   soap_action: quoted
+#   timestamp_zone: UTC
+
+# Optional named profiles. A profile can extend another one, and ${name}
+# is replaced from vars defined at the top level or in the profile chain.
 # default_profile: site-a
 # profiles:
-#   site-a:
-#     endpoint: http://192.168.1.50/OPC/DA
+#   plant:
+#     endpoint: http://${host}/OPC/DA
 #     username: user
 #     password: secret
+#   site-a:
+#     extends: plant
+#     vars:
+#       host: 192.168.1.50
 `)
 }
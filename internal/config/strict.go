@@ -0,0 +1,146 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileKeys lists the config file keys that are not client settings, for
+// typo suggestions.
+var fileKeys = []string{"vars", "default_profile", "profiles", "extends"}
+
+// decodeStrict decodes data into cfg, rejecting keys that no setting uses.
+func decodeStrict(data []byte, cfg *FileConfig) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return describeYAMLError(err)
+	}
+	return nil
+}
+
+var unknownFieldPattern = regexp.MustCompile(`^line (\d+): field (\S+) not found in type \S+$`)
+
+// describeYAMLError rewrites yaml.v3 unknown field errors, which name Go
+// types, into unknown key errors that suggest the closest known key.
+func describeYAMLError(err error) error {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return err
+	}
+	rewritten := make([]string, len(typeErr.Errors))
+	for i, msg := range typeErr.Errors {
+		match := unknownFieldPattern.FindStringSubmatch(msg)
+		if match == nil {
+			rewritten[i] = msg
+			continue
+		}
+		rewritten[i] = fmt.Sprintf("line %s: unknown key %q", match[1], match[2])
+		if suggestion := suggestKey(match[2]); suggestion != "" {
+			rewritten[i] += fmt.Sprintf(" (did you mean %q?)", suggestion)
+		}
+	}
+	return &yaml.TypeError{Errors: rewritten}
+}
+
+// suggestKey returns the known key within two edits of key, if any.
+func suggestKey(key string) string {
+	best, bestDistance := "", 3
+	for _, known := range knownKeys() {
+		if d := editDistance(key, known); d < bestDistance {
+			best, bestDistance = known, d
+		}
+	}
+	return best
+}
+
+func knownKeys() []string {
+	keys := append([]string{}, fileKeys...)
+	for _, key := range ClientConfigKeys {
+		keys = append(keys, key[strings.LastIndex(key, ".")+1:])
+	}
+	return keys
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr := make([]int, len(b)+1)
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev = curr
+	}
+	return prev[len(b)]
+}
+
+// checkProfileRefs checks that default_profile and every extends name an
+// existing profile and that no extends chain loops. Errors carry the line
+// of the offending key in data.
+func checkProfileRefs(cfg FileConfig, data []byte) error {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return err
+	}
+	var top *yaml.Node
+	if len(root.Content) == 1 {
+		top = root.Content[0]
+	}
+	if cfg.DefaultProfile != "" {
+		if _, ok := cfg.Profiles[cfg.DefaultProfile]; !ok {
+			return fmt.Errorf("%sdefault_profile %q is not a defined profile", keyLine(top, "default_profile"), cfg.DefaultProfile)
+		}
+	}
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if cfg.Profiles[name].Extends == "" {
+			continue
+		}
+		if _, err := cfg.ProfileChain(name); err != nil {
+			return fmt.Errorf("%s%w", keyLine(top, "profiles", name, "extends"), err)
+		}
+	}
+	return nil
+}
+
+// keyLine returns "line N: " for the key at path under node, or "" when
+// it cannot be found.
+func keyLine(node *yaml.Node, path ...string) string {
+	for i, name := range path {
+		if node == nil || node.Kind != yaml.MappingNode {
+			return ""
+		}
+		var next *yaml.Node
+		for j := 0; j+1 < len(node.Content); j += 2 {
+			if node.Content[j].Value == name {
+				if i == len(path)-1 {
+					return "line " + strconv.Itoa(node.Content[j].Line) + ": "
+				}
+				next = node.Content[j+1]
+				break
+			}
+		}
+		node = next
+	}
+	return ""
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// TLSConfig configures HTTPS connections to the endpoint.
+type TLSConfig struct {
+	CAFile             string   `yaml:"ca_file,omitempty"`
+	CertFile           string   `yaml:"cert_file,omitempty"`
+	KeyFile            string   `yaml:"key_file,omitempty"`
+	ServerName         string   `yaml:"server_name,omitempty"`
+	MinVersion         string   `yaml:"min_version,omitempty"`
+	InsecureSkipVerify bool     `yaml:"insecure_skip_verify,omitempty"`
+	PinSHA256          []string `yaml:"pin_sha256,omitempty"`
+}
+
+// IsZero reports whether no TLS setting is configured.
+func (t TLSConfig) IsZero() bool {
+	return t.CAFile == "" && t.CertFile == "" && t.KeyFile == "" && t.ServerName == "" &&
+		t.MinVersion == "" && !t.InsecureSkipVerify && len(t.PinSHA256) == 0
+}
+
+// Validate checks that the settings can be turned into a TLS client
+// configuration without reading any files.
+func (t TLSConfig) Validate() error {
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return errors.New("tls.cert_file and tls.key_file must be set together")
+	}
+	if _, err := ParseTLSVersion(t.MinVersion); err != nil {
+		return err
+	}
+	for _, pin := range t.PinSHA256 {
+		if _, err := ParsePin(pin); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseTLSVersion converts "1.2" or "TLS1.3" style names into crypto/tls
+// version constants. An empty value returns zero, the crypto/tls default.
+func ParseTLSVersion(value string) (uint16, error) {
+	switch strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(value)), "TLS") {
+	case "":
+		return 0, nil
+	case "1.0", "10":
+		return tls.VersionTLS10, nil
+	case "1.1", "11":
+		return tls.VersionTLS11, nil
+	case "1.2", "12":
+		return tls.VersionTLS12, nil
+	case "1.3", "13":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("tls.min_version %q is not one of 1.0, 1.1, 1.2, 1.3", value)
+	}
+}
+
+// ParsePin decodes a SHA-256 public key pin given as "sha256/<base64>", as
+// printed by test-connection, or as hex with optional colons.
+func ParsePin(pin string) ([]byte, error) {
+	pin = strings.TrimSpace(pin)
+	var sum []byte
+	var err error
+	if encoded, ok := strings.CutPrefix(pin, "sha256/"); ok {
+		sum, err = base64.StdEncoding.DecodeString(encoded)
+	} else {
+		sum, err = hex.DecodeString(strings.ReplaceAll(pin, ":", ""))
+	}
+	if err != nil || len(sum) != 32 {
+		return nil, fmt.Errorf("tls.pin_sha256 %q is not a SHA-256 pin (sha256/<base64> or hex)", pin)
+	}
+	return sum, nil
+}
+
+func mergeTLSConfig(base, override TLSConfig) TLSConfig {
+	if override.CAFile != "" {
+		base.CAFile = override.CAFile
+	}
+	if override.CertFile != "" {
+		base.CertFile = override.CertFile
+	}
+	if override.KeyFile != "" {
+		base.KeyFile = override.KeyFile
+	}
+	if override.ServerName != "" {
+		base.ServerName = override.ServerName
+	}
+	if override.MinVersion != "" {
+		base.MinVersion = override.MinVersion
+	}
+	if override.InsecureSkipVerify {
+		base.InsecureSkipVerify = true
+	}
+	if len(override.PinSHA256) > 0 {
+		base.PinSHA256 = override.PinSHA256
+	}
+	return base
+}
@@ -0,0 +1,217 @@
+package httpauth
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// digestTransport implements RFC 7616 Digest authentication. The last
+// challenge is reused for later requests, so only the first request and
+// those after a stale nonce need the extra round trip.
+type digestTransport struct {
+	base     http.RoundTripper
+	username string
+	password string
+	random   io.Reader
+
+	mu        sync.Mutex
+	challenge *digestChallenge
+	nc        uint32
+}
+
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+}
+
+func newDigestTransport(base http.RoundTripper, username, password string) *digestTransport {
+	return &digestTransport{base: base, username: username, password: password, random: rand.Reader}
+}
+
+func (t *digestTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	first := cloneRequest(req, body)
+	if authorization, ok, err := t.authorize(first, body); err != nil {
+		return nil, err
+	} else if ok {
+		first.Header.Set("Authorization", authorization)
+	}
+	resp, err := t.base.RoundTrip(first)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	challenge, ok := parseDigestChallenge(resp.Header)
+	if !ok {
+		return resp, nil
+	}
+	discard(resp)
+
+	t.mu.Lock()
+	t.challenge, t.nc = challenge, 0
+	t.mu.Unlock()
+	retry := cloneRequest(req, body)
+	authorization, _, err := t.authorize(retry, body)
+	if err != nil {
+		return nil, err
+	}
+	retry.Header.Set("Authorization", authorization)
+	return t.base.RoundTrip(retry)
+}
+
+// authorize builds the Authorization header from the cached challenge.
+func (t *digestTransport) authorize(req *http.Request, body []byte) (string, bool, error) {
+	t.mu.Lock()
+	challenge := t.challenge
+	t.nc++
+	nc := t.nc
+	t.mu.Unlock()
+	if challenge == nil {
+		return "", false, nil
+	}
+	cnonceBytes := make([]byte, 16)
+	if _, err := io.ReadFull(t.random, cnonceBytes); err != nil {
+		return "", false, err
+	}
+	cnonce := hex.EncodeToString(cnonceBytes)
+	return digestAuthorization(challenge, t.username, t.password, req.Method, req.URL.RequestURI(), body, nc, cnonce), true, nil
+}
+
+func digestAuthorization(c *digestChallenge, username, password, method, uri string, body []byte, nc uint32, cnonce string) string {
+	newHash := md5.New
+	algorithm := strings.ToUpper(c.algorithm)
+	if strings.HasPrefix(algorithm, "SHA-256") {
+		newHash = sha256.New
+	}
+	h := func(parts ...string) string {
+		return hexHash(newHash, strings.Join(parts, ":"))
+	}
+	ha1 := h(username, c.realm, password)
+	if strings.HasSuffix(algorithm, "-SESS") {
+		ha1 = h(ha1, c.nonce, cnonce)
+	}
+	qop := chooseQOP(c.qop)
+	ha2 := h(method, uri)
+	if qop == "auth-int" {
+		ha2 = h(method, uri, hexHash(newHash, string(body)))
+	}
+	ncValue := fmt.Sprintf("%08x", nc)
+	response := h(ha1, c.nonce, ha2)
+	if qop != "" {
+		response = h(ha1, c.nonce, ncValue, cnonce, qop, ha2)
+	}
+
+	parts := []string{
+		"username=" + quoteParam(username),
+		"realm=" + quoteParam(c.realm),
+		"nonce=" + quoteParam(c.nonce),
+		"uri=" + quoteParam(uri),
+		"response=" + quoteParam(response),
+	}
+	if c.algorithm != "" {
+		parts = append(parts, "algorithm="+c.algorithm)
+	}
+	if c.opaque != "" {
+		parts = append(parts, "opaque="+quoteParam(c.opaque))
+	}
+	if qop != "" {
+		parts = append(parts, "qop="+qop, "nc="+ncValue, "cnonce="+quoteParam(cnonce))
+	}
+	return "Digest " + strings.Join(parts, ", ")
+}
+
+func quoteParam(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// chooseQOP prefers auth over auth-int when the server offers both.
+func chooseQOP(offered string) string {
+	choice := ""
+	for _, qop := range strings.Split(offered, ",") {
+		switch strings.TrimSpace(qop) {
+		case "auth":
+			return "auth"
+		case "auth-int":
+			choice = "auth-int"
+		}
+	}
+	return choice
+}
+
+func hexHash(newHash func() hash.Hash, s string) string {
+	sum := newHash()
+	sum.Write([]byte(s))
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// parseDigestChallenge picks the first Digest challenge with a supported
+// algorithm from WWW-Authenticate.
+func parseDigestChallenge(header http.Header) (*digestChallenge, bool) {
+	for _, value := range header.Values("WWW-Authenticate") {
+		scheme, rest, _ := strings.Cut(strings.TrimSpace(value), " ")
+		if !strings.EqualFold(scheme, "Digest") {
+			continue
+		}
+		params := parseAuthParams(rest)
+		c := &digestChallenge{
+			realm:     params["realm"],
+			nonce:     params["nonce"],
+			opaque:    params["opaque"],
+			algorithm: params["algorithm"],
+			qop:       params["qop"],
+		}
+		switch strings.ToUpper(c.algorithm) {
+		case "", "MD5", "MD5-SESS", "SHA-256", "SHA-256-SESS":
+		default:
+			continue
+		}
+		if c.nonce != "" {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// parseAuthParams splits comma-separated name=value pairs whose values may
+// be quoted strings containing commas.
+func parseAuthParams(s string) map[string]string {
+	params := map[string]string{}
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		name, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			return params
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		rest = strings.TrimLeft(rest, " \t")
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			var b strings.Builder
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				b.WriteByte(rest[i])
+			}
+			value, s = b.String(), rest[min(i+1, len(rest)):]
+		} else {
+			value, s, _ = strings.Cut(rest, ",")
+			value = strings.TrimSpace(value)
+		}
+		params[name] = value
+	}
+}
@@ -0,0 +1,235 @@
+package httpauth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMD4(t *testing.T) {
+	for input, want := range map[string]string{
+		"":               "31d6cfe0d16ae931b73c59d7e0c089c0",
+		"abc":            "a448017aaf21d8525fc10ae87aa6729d",
+		"message digest": "d9130a8164549fe818874806e1c7014b",
+		"12345678901234567890123456789012345678901234567890123456789012345678901234567890": "e33b4ddc9c38f2199c3e7b164fcc0536",
+	} {
+		sum := md4([]byte(input))
+		if got := hex.EncodeToString(sum[:]); got != want {
+			t.Fatalf("md4(%q) = %s, want %s", input, got, want)
+		}
+	}
+}
+
+// TestNTLMv2Vectors checks the MS-NLMP 4.2.4 sample values.
+func TestNTLMv2Vectors(t *testing.T) {
+	ntHash := ntowfv2("Domain", "User", "Password")
+	if got := hex.EncodeToString(ntHash); got != "0c868a403bfd7a93a3001ef22ef02e3f" {
+		t.Fatalf("ntowfv2 = %s", got)
+	}
+	serverChallenge, _ := hex.DecodeString("0123456789abcdef")
+	clientChallenge := bytes.Repeat([]byte{0xaa}, 8)
+	targetInfo := append(avPair(2, utf16le("Domain")), avPair(1, utf16le("Server"))...)
+	targetInfo = append(targetInfo, 0, 0, 0, 0)
+	ntResponse, lmResponse := ntlmV2Responses(ntHash, serverChallenge, clientChallenge, make([]byte, 8), targetInfo)
+	if got := hex.EncodeToString(ntResponse[:16]); got != "68cd0ab851e51c96aabc927bebef6a1c" {
+		t.Fatalf("NTProofStr = %s", got)
+	}
+	if got := hex.EncodeToString(lmResponse); got != "86c35097ac9cec102554764a57cccc19aaaaaaaaaaaaaaaa" {
+		t.Fatalf("LMv2 = %s", got)
+	}
+}
+
+func TestParseScheme(t *testing.T) {
+	for input, want := range map[string]string{"": SchemeBasic, "Basic": SchemeBasic, "digest": SchemeDigest, " NTLM ": SchemeNTLM, "WSSE-Digest": SchemeWSSEDigest} {
+		if got, err := ParseScheme(input); err != nil || got != want {
+			t.Fatalf("ParseScheme(%q) = %q, %v", input, got, err)
+		}
+	}
+	if _, err := ParseScheme("kerberos"); err == nil || !strings.Contains(err.Error(), "basic, digest, ntlm") {
+		t.Fatalf("ParseScheme(kerberos) error = %v", err)
+	}
+}
+
+func TestDigestAuthentication(t *testing.T) {
+	server := newDigestServer(t, "opc", "alice", "s3cret")
+	client := &http.Client{Transport: mustRoundTripper(t, SchemeDigest, "alice", "s3cret")}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Post(server.URL+"/opc?x=1", "text/xml", strings.NewReader("<body/>"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || string(body) != "<body/>" {
+			t.Fatalf("request %d: status %d body %q", i, resp.StatusCode, body)
+		}
+	}
+	if server.challenges != 1 {
+		t.Fatalf("challenges = %d, want the cached challenge reused", server.challenges)
+	}
+
+	client = &http.Client{Transport: mustRoundTripper(t, SchemeDigest, "alice", "wrong")}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("wrong password status = %d", resp.StatusCode)
+	}
+}
+
+func TestNTLMAuthentication(t *testing.T) {
+	server := newNTLMServer(t, "PLANT", "opc", "s3cret")
+	client := &http.Client{Transport: mustRoundTripper(t, SchemeNTLM, `PLANT\opc`, "s3cret")}
+	resp, err := client.Post(server.URL, "text/xml", strings.NewReader("<body/>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "<body/>" {
+		t.Fatalf("status %d body %q", resp.StatusCode, body)
+	}
+
+	client = &http.Client{Transport: mustRoundTripper(t, SchemeNTLM, "opc@PLANT", "wrong")}
+	resp, err = client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("wrong password status = %d", resp.StatusCode)
+	}
+}
+
+type digestServer struct {
+	*httptest.Server
+	challenges int
+}
+
+// newDigestServer is a stand-in for a Digest-protected endpoint using
+// MD5 with qop=auth. It checks the client's response hash, including uri.
+func newDigestServer(t *testing.T, realm, username, password string) *digestServer {
+	t.Helper()
+	server := &digestServer{}
+	const nonce = "dcd98b7102dd2f0e8b11d0f600bfb0c093"
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := map[string]string{}
+		if rest, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Digest "); ok {
+			params = parseAuthParams(rest)
+		}
+		md5hex := func(s string) string {
+			sum := md5.Sum([]byte(s))
+			return hex.EncodeToString(sum[:])
+		}
+		ha1 := md5hex(username + ":" + realm + ":" + password)
+		ha2 := md5hex(r.Method + ":" + r.URL.RequestURI())
+		want := md5hex(strings.Join([]string{ha1, nonce, params["nc"], params["cnonce"], "auth", ha2}, ":"))
+		if params["uri"] != r.URL.RequestURI() || params["response"] != want || params["opaque"] != "xyz" {
+			server.challenges++
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest realm=%q, qop="auth,auth-int", nonce=%q, opaque="xyz"`, realm, nonce))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		okHandler().ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func mustRoundTripper(t *testing.T, scheme, username, password string) http.RoundTripper {
+	t.Helper()
+	rt, err := NewRoundTripper(&http.Transport{}, scheme, username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rt
+}
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(w, r.Body)
+	})
+}
+
+func avPair(id uint16, value []byte) []byte {
+	out := binary.LittleEndian.AppendUint16(nil, id)
+	out = binary.LittleEndian.AppendUint16(out, uint16(len(value)))
+	return append(out, value...)
+}
+
+// newNTLMServer is a stand-in for IIS Windows authentication. It issues a
+// challenge per connection and verifies the client's NTProofStr.
+func newNTLMServer(t *testing.T, domain, username, password string) *httptest.Server {
+	t.Helper()
+	var mu sync.Mutex
+	challenges := map[string][]byte{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "NTLM ")
+		msg, err := base64.StdEncoding.DecodeString(token)
+		if !ok || err != nil || len(msg) < 12 {
+			w.Header().Set("WWW-Authenticate", "NTLM")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch binary.LittleEndian.Uint32(msg[8:]) {
+		case 1:
+			serverChallenge := []byte("srvchal!")
+			mu.Lock()
+			challenges[r.RemoteAddr] = serverChallenge
+			mu.Unlock()
+			targetInfo := append(avPair(2, utf16le(domain)), avPair(ntlmAvTimestamp, make([]byte, 8))...)
+			targetInfo = append(targetInfo, 0, 0, 0, 0)
+			challenge := make([]byte, 48)
+			copy(challenge, ntlmSignature)
+			binary.LittleEndian.PutUint32(challenge[8:], 2)
+			binary.LittleEndian.PutUint32(challenge[20:], ntlmNegotiateFlags)
+			copy(challenge[24:], serverChallenge)
+			binary.LittleEndian.PutUint16(challenge[40:], uint16(len(targetInfo)))
+			binary.LittleEndian.PutUint16(challenge[42:], uint16(len(targetInfo)))
+			binary.LittleEndian.PutUint32(challenge[44:], 48)
+			challenge = append(challenge, targetInfo...)
+			w.Header().Set("WWW-Authenticate", "NTLM "+base64.StdEncoding.EncodeToString(challenge))
+			w.WriteHeader(http.StatusUnauthorized)
+		case 3:
+			mu.Lock()
+			serverChallenge := challenges[r.RemoteAddr]
+			mu.Unlock()
+			field := func(i int) []byte {
+				pos := 12 + 8*i
+				length := int(binary.LittleEndian.Uint16(msg[pos:]))
+				offset := int(binary.LittleEndian.Uint32(msg[pos+4:]))
+				return msg[offset : offset+length]
+			}
+			ntResponse := field(1)
+			if serverChallenge == nil || len(ntResponse) < 16 || !bytes.Equal(field(2), utf16le(domain)) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			ntHash := ntowfv2(domain, username, password)
+			mac := hmac.New(md5.New, ntHash)
+			mac.Write(serverChallenge)
+			mac.Write(ntResponse[16:])
+			if !hmac.Equal(mac.Sum(nil), ntResponse[:16]) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			okHandler().ServeHTTP(w, r)
+		default:
+			http.Error(w, fmt.Sprintf("unexpected NTLM message %d", binary.LittleEndian.Uint32(msg[8:])), http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
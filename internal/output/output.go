@@ -2,6 +2,7 @@ package output
 
 import (
 	"io"
+	"strings"
 
 	shared "github.com/DishanRajapaksha/industrial-cli-kit/output"
 )
@@ -14,9 +15,12 @@ const (
 	FormatJSON  = shared.FormatJSON
 	FormatJSONL = shared.FormatJSONL
 	FormatCSV   = shared.FormatCSV
+
+	// FormatInflux renders InfluxDB line protocol for read and watch.
+	FormatInflux = "influx"
 )
 
-func NormaliseFormat(value string) string                        { return shared.NormaliseFormat(value) }
+func NormaliseFormat(value string) string                        { return normaliseFormat(value) }
 func ValidateSnapshotFormat(value string) error                  { return shared.ValidateSnapshotFormat(value) }
 func ValidateStreamFormat(value string) error                    { return shared.ValidateStreamFormat(value) }
 func WriteJSON(w io.Writer, value interface{}) error             { return shared.WriteJSON(w, value) }
@@ -29,3 +33,10 @@ func WriteCSV(w io.Writer, headers []string, rows [][]string) error {
 	return shared.WriteCSV(w, headers, rows)
 }
 func WriteCSVRows(w io.Writer, rows [][]string) error { return shared.WriteCSVRows(w, rows) }
+
+func normaliseFormat(value string) string {
+	if strings.EqualFold(strings.TrimSpace(value), FormatInflux) {
+		return FormatInflux
+	}
+	return shared.NormaliseFormat(value)
+}
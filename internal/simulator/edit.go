@@ -0,0 +1,103 @@
+package simulator
+
+import (
+	"html"
+	"strings"
+
+	"opc-xml-da-cli/service"
+)
+
+// AddBranch adds an empty branch, and any missing parents.
+func (s *Simulator) AddBranch(itemName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ensure(itemName).branch = true
+}
+
+// SetValue sets the value and quality of an item, adding the item and any
+// missing branches. Like a write, the value replaces the item's generator
+// and any result set with SetItemResult; a typed value also changes the
+// item's type.
+func (s *Simulator) SetValue(itemName string, value service.AnyType, quality service.QualityBits) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.ensure(itemName)
+	if value.Type != "" {
+		n.tag.Type = value.Type
+	}
+	n.result = ""
+	s.written[n.name] = writtenValue{value: valueText(n.tag.Type, value), quality: quality, at: s.now()}
+}
+
+// SetItemResult makes reads of an item, added if missing, return resultID,
+// such as E_UNKNOWNITEMNAME, instead of a value. An empty resultID clears
+// it.
+func (s *Simulator) SetItemResult(itemName, resultID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ensure(itemName).result = resultID
+}
+
+// SetWritable sets whether clients may write an item, adding it if missing.
+func (s *Simulator) SetWritable(itemName string, writable bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ensure(itemName).tag.Writable = writable
+}
+
+// Value returns the current value of an item and whether it exists.
+func (s *Simulator) Value(itemName string) (service.AnyType, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := s.item(itemName)
+	if !ok {
+		return service.AnyType{}, false
+	}
+	current := s.sample(n, s.now())
+	return service.AnyType{InnerXML: html.EscapeString(current.value), Type: n.tag.Type}, true
+}
+
+// SetServerStatus sets the server state and status text reported by
+// GetStatus and every reply base.
+func (s *Simulator) SetServerStatus(state service.ServerState, statusInfo string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.model.Server.State = state
+	s.model.Server.StatusInfo = statusInfo
+}
+
+// SetPageSize limits Browse replies to n elements, as page_size does in a
+// model. Zero returns every element at once.
+func (s *Simulator) SetPageSize(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.model.PageSize = n
+}
+
+// ExpireSubscriptions drops every subscription, as a server does when a
+// client stops polling. Later refreshes report the handles as invalid.
+func (s *Simulator) ExpireSubscriptions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscriptions = map[string]*subscription{}
+}
+
+// ensure returns the node called itemName, adding it as a writable string
+// item and its parents as branches. The caller holds s.mu.
+func (s *Simulator) ensure(itemName string) *node {
+	if n, ok := s.nodes[itemName]; ok {
+		return n
+	}
+	parent := s.root
+	if i := strings.LastIndex(itemName, "."); i >= 0 {
+		parent = s.ensure(itemName[:i])
+		parent.branch = true
+	}
+	n := &node{
+		tag:  &Tag{Name: itemName[strings.LastIndex(itemName, ".")+1:], Type: "string", Writable: true},
+		name: itemName,
+	}
+	parent.children = append(parent.children, n)
+	s.nodes[itemName] = n
+	return n
+}
@@ -0,0 +1,176 @@
+package simulator
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Generator produces item values as a function of time since the simulator
+// started, so every client sees the same value at the same moment.
+//
+//	sine     Offset + Amplitude*sin(2π t/Period)
+//	ramp     rises from Min to Max over Period, then starts again
+//	random   a new value in [Min, Max) every Interval
+//	counter  Offset + Step per Interval, wrapping to Min past Max when Max > Min;
+//	         without a max in the model it never wraps
+type Generator struct {
+	Kind      string
+	Amplitude float64
+	Offset    float64
+	Min       float64
+	Max       float64
+	Step      float64
+	Period    time.Duration
+	Interval  time.Duration
+}
+
+func (g *Generator) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type rawGenerator struct {
+		Kind      string   `yaml:"kind"`
+		Amplitude *float64 `yaml:"amplitude"`
+		Offset    float64  `yaml:"offset"`
+		Min       float64  `yaml:"min"`
+		Max       *float64 `yaml:"max"`
+		Step      *float64 `yaml:"step"`
+		Period    string   `yaml:"period"`
+		Interval  string   `yaml:"interval"`
+	}
+	var raw rawGenerator
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	period, err := parseOptionalDuration("period", raw.Period)
+	if err != nil {
+		return err
+	}
+	interval, err := parseOptionalDuration("interval", raw.Interval)
+	if err != nil {
+		return err
+	}
+	*g = Generator{
+		Kind:      raw.Kind,
+		Amplitude: 1,
+		Offset:    raw.Offset,
+		Min:       raw.Min,
+		Max:       100,
+		Step:      1,
+		Period:    period,
+		Interval:  interval,
+	}
+	if raw.Amplitude != nil {
+		g.Amplitude = *raw.Amplitude
+	}
+	if raw.Max != nil {
+		g.Max = *raw.Max
+	} else if g.Kind == "counter" {
+		g.Max = math.Inf(1)
+	}
+	if raw.Step != nil {
+		g.Step = *raw.Step
+	}
+	if g.Period == 0 {
+		g.Period = time.Minute
+	}
+	if g.Interval == 0 {
+		g.Interval = time.Second
+	}
+	return nil
+}
+
+func (g *Generator) validate() error {
+	switch g.Kind {
+	case "sine", "ramp", "random", "counter":
+	case "":
+		return fmt.Errorf("generator kind is required (sine, ramp, random, or counter)")
+	default:
+		return fmt.Errorf("unknown generator kind %q (use sine, ramp, random, or counter)", g.Kind)
+	}
+	if g.Period <= 0 || g.Interval <= 0 {
+		return fmt.Errorf("generator period and interval must be positive")
+	}
+	if (g.Kind == "ramp" || g.Kind == "random") && g.Max <= g.Min {
+		return fmt.Errorf("%s generator needs max greater than min", g.Kind)
+	}
+	if g.Kind == "counter" && g.Max > g.Min && !math.IsInf(g.Max, 1) && g.Step <= 0 {
+		return fmt.Errorf("counter generator with a max needs a positive step")
+	}
+	return nil
+}
+
+func (g *Generator) defaultType() string {
+	if g.Kind == "counter" {
+		return "int"
+	}
+	return "double"
+}
+
+// value returns the generated value at elapsed. seed and name make random
+// generators repeatable per model and tag.
+func (g *Generator) value(elapsed time.Duration, seed int64, name string) float64 {
+	switch g.Kind {
+	case "sine":
+		return g.Offset + g.Amplitude*math.Sin(2*math.Pi*float64(elapsed)/float64(g.Period))
+	case "ramp":
+		fraction := float64(elapsed%g.Period) / float64(g.Period)
+		return g.Min + (g.Max-g.Min)*fraction
+	case "random":
+		bucket := int64(elapsed / g.Interval)
+		hash := fnv.New64a()
+		fmt.Fprintf(hash, "%d\x00%s\x00%d", seed, name, bucket)
+		fraction := float64(hash.Sum64()>>11) / (1 << 53)
+		return g.Min + (g.Max-g.Min)*fraction
+	case "counter":
+		value := g.Offset + g.Step*float64(elapsed/g.Interval)
+		if g.Max > g.Min && value > g.Max {
+			span := g.Max - g.Min + g.Step
+			value = g.Min + math.Mod(value-g.Min, span)
+		}
+		return value
+	}
+	return 0
+}
+
+var numericTypes = map[string]bool{
+	"byte": true, "short": true, "int": true, "long": true,
+	"unsignedByte": true, "unsignedShort": true, "unsignedInt": true, "unsignedLong": true,
+	"float": true, "double": true, "decimal": true,
+}
+
+func supportedType(xsdType string) bool {
+	return numericTypes[xsdType] || xsdType == "boolean" || xsdType == "string" || xsdType == "dateTime"
+}
+
+func isFloatType(xsdType string) bool {
+	return xsdType == "float" || xsdType == "double" || xsdType == "decimal"
+}
+
+func zeroValue(xsdType string) string {
+	switch {
+	case xsdType == "boolean":
+		return "false"
+	case xsdType == "dateTime":
+		return time.Time{}.Format(time.RFC3339)
+	case numericTypes[xsdType]:
+		return "0"
+	}
+	return ""
+}
+
+// formatNumber renders a generated value in the lexical form of xsdType.
+func formatNumber(xsdType string, value float64) string {
+	switch {
+	case isFloatType(xsdType):
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case xsdType == "boolean":
+		return strconv.FormatBool(value != 0)
+	case xsdType == "string":
+		return strconv.FormatFloat(value, 'f', 3, 64)
+	case strings.HasPrefix(xsdType, "unsigned") && value < 0:
+		return "0"
+	}
+	return strconv.FormatInt(int64(math.Round(value)), 10)
+}
@@ -0,0 +1,251 @@
+package simulator
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"opc-xml-da-cli/service"
+)
+
+// Model describes the simulated server: its status and a tree of tags.
+// Leaf tags are items; tags with children are branches. Item names are the
+// tag names along the path joined with ".".
+type Model struct {
+	Server   ServerInfo `yaml:"server"`
+	PageSize int        `yaml:"page_size,omitempty"`
+	Seed     int64      `yaml:"seed,omitempty"`
+	Tags     []*Tag     `yaml:"tags"`
+}
+
+// ServerInfo is reported by GetStatus.
+type ServerInfo struct {
+	State          service.ServerState `yaml:"state,omitempty"`
+	StatusInfo     string              `yaml:"status_info,omitempty"`
+	VendorInfo     string              `yaml:"vendor_info,omitempty"`
+	ProductVersion string              `yaml:"product_version,omitempty"`
+}
+
+// Tag is a branch or item in the simulated address space. Type is an XML
+// Schema type name; Value is the initial value of items without a generator.
+type Tag struct {
+	Name        string     `yaml:"name"`
+	Description string     `yaml:"description,omitempty"`
+	Type        string     `yaml:"type,omitempty"`
+	Value       string     `yaml:"value,omitempty"`
+	Units       string     `yaml:"units,omitempty"`
+	Writable    bool       `yaml:"writable,omitempty"`
+	Generator   *Generator `yaml:"generator,omitempty"`
+	Faults      []Fault    `yaml:"faults,omitempty"`
+	Children    []*Tag     `yaml:"children,omitempty"`
+}
+
+// Fault reports Quality instead of good for Duration at the end of every
+// Every period.
+type Fault struct {
+	Quality  service.QualityBits
+	Every    time.Duration
+	Duration time.Duration
+}
+
+func (f *Fault) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type rawFault struct {
+		Quality  string `yaml:"quality"`
+		Every    string `yaml:"every"`
+		Duration string `yaml:"duration"`
+	}
+	var raw rawFault
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	every, err := parseOptionalDuration("every", raw.Every)
+	if err != nil {
+		return err
+	}
+	duration, err := parseOptionalDuration("duration", raw.Duration)
+	if err != nil {
+		return err
+	}
+	f.Quality = service.QualityBits(raw.Quality)
+	f.Every = every
+	f.Duration = duration
+	return nil
+}
+
+// LoadModel reads a simulator model from a YAML file.
+func LoadModel(path string) (*Model, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseModel(data)
+}
+
+// ParseModel decodes and validates a YAML simulator model.
+func ParseModel(data []byte) (*Model, error) {
+	var model Model
+	if err := yaml.Unmarshal(data, &model); err != nil {
+		return nil, err
+	}
+	if err := model.Validate(); err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+// Validate fills in defaults and checks that tag names are unique and that
+// generators, faults, and initial values are usable.
+func (m *Model) Validate() error {
+	if m.Server.State == "" {
+		m.Server.State = service.ServerStateRunning
+	}
+	if m.Server.VendorInfo == "" {
+		m.Server.VendorInfo = "opc-xml-da-cli simulator"
+	}
+	if m.PageSize < 0 {
+		return errors.New("page_size must not be negative")
+	}
+	if len(m.Tags) == 0 {
+		return errors.New("model has no tags")
+	}
+	return validateTags(m.Tags, "")
+}
+
+func validateTags(tags []*Tag, parent string) error {
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		if tag == nil || tag.Name == "" {
+			return fmt.Errorf("tag under %q has no name", parent)
+		}
+		if strings.Contains(tag.Name, ".") {
+			return fmt.Errorf("tag %q: names must not contain \".\"", tag.Name)
+		}
+		name := joinName(parent, tag.Name)
+		if seen[tag.Name] {
+			return fmt.Errorf("tag %q is defined twice", name)
+		}
+		seen[tag.Name] = true
+		if len(tag.Children) > 0 {
+			if tag.Generator != nil || tag.Value != "" || len(tag.Faults) > 0 {
+				return fmt.Errorf("tag %q: branches cannot have a value, generator, or faults", name)
+			}
+			if err := validateTags(tag.Children, name); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := validateItem(tag, name); err != nil {
+			return fmt.Errorf("tag %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func validateItem(tag *Tag, name string) error {
+	if tag.Generator != nil {
+		if err := tag.Generator.validate(); err != nil {
+			return err
+		}
+		if tag.Type == "" {
+			tag.Type = tag.Generator.defaultType()
+		}
+		if tag.Type == "dateTime" {
+			return errors.New("generators cannot produce dateTime values")
+		}
+	}
+	if tag.Type == "" {
+		tag.Type = "string"
+	}
+	if !supportedType(tag.Type) {
+		return fmt.Errorf("unsupported type %q", tag.Type)
+	}
+	if tag.Generator == nil {
+		if tag.Value == "" && tag.Type != "string" {
+			tag.Value = zeroValue(tag.Type)
+		}
+		if err := service.CheckValue(tag.Type, tag.Value); err != nil {
+			return err
+		}
+	}
+	for i, fault := range tag.Faults {
+		if fault.Every <= 0 || fault.Duration <= 0 || fault.Duration > fault.Every {
+			return fmt.Errorf("fault %d: every and duration must be positive with duration <= every", i+1)
+		}
+		if fault.Quality == "" {
+			tag.Faults[i].Quality = service.QualityBitsBad
+		}
+	}
+	return nil
+}
+
+func joinName(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+func parseOptionalDuration(name, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	return duration, nil
+}
+
+// DefaultModel is served when simulate is started without --model.
+func DefaultModel() *Model {
+	model, err := ParseModel([]byte(defaultModelYAML))
+	if err != nil {
+		panic(err)
+	}
+	return model
+}
+
+const defaultModelYAML = `server:
+  status_info: Simulated plant
+  product_version: "1.0"
+page_size: 50
+tags:
+  - name: Plant
+    children:
+      - name: Boiler
+        children:
+          - name: Temperature
+            description: Boiler water temperature
+            units: degC
+            generator: {kind: sine, offset: 80, amplitude: 5, period: 60s}
+            faults:
+              - {quality: badSensorFailure, every: 5m, duration: 10s}
+          - name: Pressure
+            units: bar
+            generator: {kind: random, min: 2.8, max: 3.2, interval: 2s}
+          - name: Setpoint
+            description: Temperature setpoint
+            units: degC
+            type: double
+            value: "80"
+            writable: true
+      - name: Line1
+        children:
+          - name: Speed
+            units: m/min
+            generator: {kind: ramp, min: 0, max: 120, period: 30s}
+          - name: Count
+            generator: {kind: counter, step: 1, interval: 1s}
+          - name: Running
+            type: boolean
+            value: "true"
+            writable: true
+          - name: Recipe
+            type: string
+            value: standard
+            writable: true
+`
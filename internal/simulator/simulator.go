@@ -0,0 +1,705 @@
+// Package simulator implements an OPC XML-DA server over a configurable tag
+// tree, for developing and testing clients without plant access.
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hooklift/gowsdl/soap"
+
+	"opc-xml-da-cli/service"
+)
+
+// Result codes used in item and reply errors.
+const (
+	ResultUnknownItemName     = "E_UNKNOWNITEMNAME"
+	ResultReadOnly            = "E_READONLY"
+	ResultBadType             = "E_BADTYPE"
+	ResultInvalidContinuation = "E_INVALIDCONTINUATIONPOINT"
+	ResultInvalidFilter       = "E_INVALIDFILTER"
+	ResultNoSubscription      = "E_NOSUBSCRIPTION"
+)
+
+const (
+	defaultSamplingRate = 1000
+	refreshPollInterval = 50 * time.Millisecond
+	maxRefreshWait      = 5 * time.Minute
+	continuationMarker  = "@"
+)
+
+var resultText = map[string]string{
+	ResultUnknownItemName:     "The item name is not known to the server.",
+	ResultReadOnly:            "The item is read only.",
+	ResultBadType:             "The value cannot be converted to the item data type.",
+	ResultInvalidContinuation: "The continuation point is not valid.",
+	ResultInvalidFilter:       "The element name filter is not valid.",
+	ResultNoSubscription:      "The subscription handle is not valid.",
+}
+
+// Simulator serves a Model. It implements service.OpcXmlDASoap, so it can be
+// used directly as a client in tests or served with service.NewHandler.
+type Simulator struct {
+	start time.Time
+	now   func() time.Time
+
+	// mu guards everything below, including the model and the tag tree,
+	// which the Set methods change while requests are served.
+	mu            sync.Mutex
+	model         *Model
+	root          *node
+	nodes         map[string]*node
+	written       map[string]writtenValue
+	subscriptions map[string]*subscription
+	nextHandle    int
+}
+
+type node struct {
+	tag      *Tag
+	name     string
+	branch   bool
+	result   string
+	children []*node
+}
+
+type writtenValue struct {
+	value   string
+	quality service.QualityBits
+	at      time.Time
+}
+
+type subscription struct {
+	items []*service.SubscribeRequestItem
+	last  map[int]string
+}
+
+type sample struct {
+	value     string
+	quality   service.QualityBits
+	timestamp time.Time
+}
+
+// New returns a simulator serving model. The model must have passed Validate.
+func New(model *Model) *Simulator {
+	s := &Simulator{
+		model:         model,
+		start:         time.Now(),
+		now:           time.Now,
+		root:          &node{branch: true},
+		nodes:         map[string]*node{},
+		written:       map[string]writtenValue{},
+		subscriptions: map[string]*subscription{},
+	}
+	s.root.children = s.index(model.Tags, "")
+	return s
+}
+
+func (s *Simulator) index(tags []*Tag, parent string) []*node {
+	nodes := make([]*node, 0, len(tags))
+	for _, tag := range tags {
+		n := &node{tag: tag, name: joinName(parent, tag.Name), branch: len(tag.Children) > 0}
+		n.children = s.index(tag.Children, n.name)
+		s.nodes[n.name] = n
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+// item returns the item called name. The caller holds s.mu.
+func (s *Simulator) item(name string) (*node, bool) {
+	n, ok := s.nodes[name]
+	if !ok || n.branch {
+		return nil, false
+	}
+	return n, true
+}
+
+// sample returns the value of an item at now. The caller holds s.mu.
+func (s *Simulator) sample(n *node, now time.Time) sample {
+	elapsed := now.Sub(s.start)
+	result := sample{quality: service.QualityBitsGood}
+	written, ok := s.written[n.name]
+	switch {
+	case ok:
+		result.value, result.quality, result.timestamp = written.value, written.quality, written.at
+	case n.tag.Generator != nil:
+		result.value = formatNumber(n.tag.Type, n.tag.Generator.value(elapsed, s.model.Seed, n.name))
+		result.timestamp = now
+	default:
+		result.value, result.timestamp = n.tag.Value, s.start
+	}
+	for _, fault := range n.tag.Faults {
+		if elapsed%fault.Every >= fault.Every-fault.Duration {
+			result.quality = fault.Quality
+			break
+		}
+	}
+	return result
+}
+
+func (s *Simulator) itemValue(n *node, now time.Time) *service.ItemValue {
+	if n.result != "" {
+		return &service.ItemValue{ResultID: qname(n.result)}
+	}
+	current := s.sample(n, now)
+	quality := current.quality
+	return &service.ItemValue{
+		Value:     service.AnyType{InnerXML: html.EscapeString(current.value), Type: n.tag.Type},
+		Quality:   &service.OPCQuality{QualityField: &quality},
+		Timestamp: xsdTime(current.timestamp),
+	}
+}
+
+func (s *Simulator) replyBase(received time.Time, clientHandle, locale string) *service.ReplyBase {
+	state := s.model.Server.State
+	return &service.ReplyBase{
+		RcvTime:             xsdTime(received),
+		ReplyTime:           xsdTime(s.now()),
+		ClientRequestHandle: clientHandle,
+		RevisedLocaleID:     locale,
+		ServerState:         &state,
+	}
+}
+
+// GetStatusContext reports the model's server information.
+func (s *Simulator) GetStatusContext(ctx context.Context, request *service.GetStatus) (*service.GetStatusResponse, error) {
+	received := s.now()
+	version := service.InterfaceVersionXML_DA_Version_1_0
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &service.GetStatusResponse{
+		GetStatusResult: s.replyBase(received, request.ClientRequestHandle, request.LocaleID),
+		Status: &service.ServerStatus{
+			StatusInfo:                 s.model.Server.StatusInfo,
+			VendorInfo:                 s.model.Server.VendorInfo,
+			SupportedLocaleIDs:         []string{"en-US"},
+			SupportedInterfaceVersions: []*service.InterfaceVersion{&version},
+			StartTime:                  xsdTime(s.start),
+			ProductVersion:             s.model.Server.ProductVersion,
+		},
+	}, nil
+}
+
+// BrowseContext lists the children of a branch a page at a time. Pages are
+// limited by MaxElementsReturned and the model's page_size, whichever is
+// smaller, and continue from an opaque continuation point.
+func (s *Simulator) BrowseContext(ctx context.Context, request *service.Browse) (*service.BrowseResponse, error) {
+	received := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := &service.BrowseResponse{BrowseResult: s.replyBase(received, request.ClientRequestHandle, request.LocaleID)}
+	parent := s.root
+	if request.ItemName != "" {
+		n, ok := s.nodes[request.ItemName]
+		if !ok {
+			resp.Errors = s.opcErrors(request.ReturnErrorText, ResultUnknownItemName)
+			return resp, nil
+		}
+		parent = n
+	}
+	offset := 0
+	if request.ContinuationPoint != "" {
+		var ok bool
+		offset, ok = parseContinuationPoint(request.ContinuationPoint, request.ItemName)
+		if !ok {
+			resp.Errors = s.opcErrors(request.ReturnErrorText, ResultInvalidContinuation)
+			return resp, nil
+		}
+	}
+
+	filter := service.BrowseFilterAll
+	if request.BrowseFilter != nil && *request.BrowseFilter != "" {
+		filter = *request.BrowseFilter
+	}
+	matches := make([]*node, 0, len(parent.children))
+	for _, child := range parent.children {
+		if (filter == service.BrowseFilterItem && child.branch) || (filter == service.BrowseFilterBranch && !child.branch) {
+			continue
+		}
+		if request.ElementNameFilter != "" {
+			matched, err := path.Match(request.ElementNameFilter, child.tag.Name)
+			if err != nil {
+				resp.Errors = s.opcErrors(request.ReturnErrorText, ResultInvalidFilter)
+				return resp, nil
+			}
+			if !matched {
+				continue
+			}
+		}
+		matches = append(matches, child)
+	}
+	if offset > len(matches) {
+		resp.Errors = s.opcErrors(request.ReturnErrorText, ResultInvalidContinuation)
+		return resp, nil
+	}
+
+	limit := len(matches) - offset
+	if max := int(request.MaxElementsReturned); max > 0 && max < limit {
+		limit = max
+	}
+	if s.model.PageSize > 0 && s.model.PageSize < limit {
+		limit = s.model.PageSize
+	}
+	for _, child := range matches[offset : offset+limit] {
+		element := &service.BrowseElement{
+			Name:        child.tag.Name,
+			ItemPath:    request.ItemPath,
+			ItemName:    child.name,
+			IsItem:      !child.branch,
+			HasChildren: len(child.children) > 0,
+		}
+		if element.IsItem && (request.ReturnAllProperties || len(request.PropertyNames) > 0) {
+			element.Properties = s.properties(child, request.ItemPath, request.PropertyNames, request.ReturnPropertyValues, received)
+		}
+		resp.Elements = append(resp.Elements, element)
+	}
+	if next := offset + limit; next < len(matches) {
+		resp.MoreElements = true
+		resp.ContinuationPoint = request.ItemName + continuationMarker + strconv.Itoa(next)
+	}
+	return resp, nil
+}
+
+func parseContinuationPoint(point, itemName string) (int, bool) {
+	i := strings.LastIndex(point, continuationMarker)
+	if i < 0 || point[:i] != itemName {
+		return 0, false
+	}
+	offset, err := strconv.Atoi(point[i+len(continuationMarker):])
+	if err != nil || offset < 0 {
+		return 0, false
+	}
+	return offset, true
+}
+
+// GetPropertiesContext returns item properties. Without PropertyNames or
+// ReturnAllProperties every property is returned.
+func (s *Simulator) GetPropertiesContext(ctx context.Context, request *service.GetProperties) (*service.GetPropertiesResponse, error) {
+	received := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := &service.GetPropertiesResponse{GetPropertiesResult: s.replyBase(received, request.ClientRequestHandle, request.LocaleID)}
+	results := map[string]bool{}
+	for _, id := range request.ItemIDs {
+		if id == nil {
+			continue
+		}
+		itemPath := firstNonEmpty(id.ItemPath, request.ItemPath)
+		list := &service.PropertyReplyList{ItemPath: itemPath, ItemName: id.ItemName}
+		n, ok := s.item(id.ItemName)
+		if !ok {
+			list.ResultID = qname(ResultUnknownItemName)
+			results[ResultUnknownItemName] = true
+		} else {
+			list.Properties = s.properties(n, itemPath, request.PropertyNames, request.ReturnPropertyValues, received)
+		}
+		resp.PropertyLists = append(resp.PropertyLists, list)
+	}
+	resp.Errors = s.opcErrors(request.ReturnErrorText, sortedKeys(results)...)
+	return resp, nil
+}
+
+func (s *Simulator) properties(n *node, itemPath string, names []*service.QName, withValues bool, now time.Time) []*service.ItemProperty {
+	current := s.sample(n, now)
+	access := "readable"
+	if n.tag.Writable {
+		access = "readWritable"
+	}
+	all := []struct {
+		name, description, xsdType, value string
+	}{
+		{"dataType", "Item Canonical DataType", "QName", "xsd:" + n.tag.Type},
+		{"value", "Item Value", n.tag.Type, current.value},
+		{"quality", "Item Quality", "string", string(current.quality)},
+		{"timestamp", "Item Timestamp", "dateTime", current.timestamp.UTC().Format(time.RFC3339Nano)},
+		{"accessRights", "Item Access Rights", "string", access},
+		{"scanRate", "Server Scan Rate", "float", strconv.Itoa(defaultSamplingRate)},
+		{"engineeringUnits", "EU Units", "string", n.tag.Units},
+		{"description", "Item Description", "string", n.tag.Description},
+	}
+	wanted := map[string]bool{}
+	for _, name := range names {
+		if name == nil {
+			continue
+		}
+		local := string(*name)
+		if i := strings.LastIndex(local, ":"); i >= 0 {
+			local = local[i+1:]
+		}
+		wanted[local] = true
+	}
+	var props []*service.ItemProperty
+	for _, prop := range all {
+		if len(wanted) > 0 && !wanted[prop.name] {
+			continue
+		}
+		property := &service.ItemProperty{
+			Name:        qname(prop.name),
+			Description: prop.description,
+			ItemPath:    itemPath,
+			ItemName:    n.name,
+		}
+		if withValues {
+			property.Value = service.AnyType{InnerXML: html.EscapeString(prop.value), Type: prop.xsdType}
+		}
+		props = append(props, property)
+	}
+	return props
+}
+
+// ReadContext returns the current value of each requested item.
+func (s *Simulator) ReadContext(ctx context.Context, request *service.Read) (*service.ReadResponse, error) {
+	received := s.now()
+	options := requestOptions(request.Options)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := &service.ReadResponse{
+		ReadResult: s.replyBase(received, options.ClientRequestHandle, options.LocaleID),
+		RItemList:  &service.ReplyItemList{},
+	}
+	if request.ItemList == nil {
+		return resp, nil
+	}
+	results := map[string]bool{}
+	for _, item := range request.ItemList.Items {
+		if item == nil {
+			continue
+		}
+		itemPath := firstNonEmpty(item.ItemPath, request.ItemList.ItemPath)
+		value := &service.ItemValue{ResultID: qname(ResultUnknownItemName)}
+		if n, ok := s.item(item.ItemName); ok {
+			value = s.itemValue(n, received)
+		}
+		if value.ResultID != nil {
+			results[string(*value.ResultID)] = true
+		}
+		resp.RItemList.Items = append(resp.RItemList.Items, applyOptions(value, options, itemPath, item.ItemName, item.ClientItemHandle))
+	}
+	resp.Errors = s.opcErrors(options.ReturnErrorText, sortedKeys(results)...)
+	return resp, nil
+}
+
+// WriteContext stores values for writable items. A written value replaces
+// the item's generator until the simulator restarts.
+func (s *Simulator) WriteContext(ctx context.Context, request *service.Write) (*service.WriteResponse, error) {
+	received := s.now()
+	options := requestOptions(request.Options)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := &service.WriteResponse{
+		WriteResult: s.replyBase(received, options.ClientRequestHandle, options.LocaleID),
+		RItemList:   &service.ReplyItemList{},
+	}
+	if request.ItemList == nil {
+		return resp, nil
+	}
+	results := map[string]bool{}
+	for _, item := range request.ItemList.Items {
+		if item == nil {
+			continue
+		}
+		itemPath := firstNonEmpty(item.ItemPath, request.ItemList.ItemPath)
+		value := &service.ItemValue{}
+		n, ok := s.item(item.ItemName)
+		switch {
+		case !ok:
+			value.ResultID = qname(ResultUnknownItemName)
+		case !n.tag.Writable:
+			value.ResultID = qname(ResultReadOnly)
+		default:
+			text := valueText(n.tag.Type, item.Value)
+			if err := service.CheckValue(n.tag.Type, text); err != nil {
+				value.ResultID = qname(ResultBadType)
+				break
+			}
+			n.result = ""
+			s.written[n.name] = writtenValue{value: text, quality: service.QualityBitsGood, at: received}
+			if request.ReturnValuesOnReply {
+				value = s.itemValue(n, received)
+			}
+		}
+		if value.ResultID != nil {
+			results[string(*value.ResultID)] = true
+		}
+		resp.RItemList.Items = append(resp.RItemList.Items, applyOptions(value, options, itemPath, item.ItemName, item.ClientItemHandle))
+	}
+	resp.Errors = s.opcErrors(options.ReturnErrorText, sortedKeys(results)...)
+	return resp, nil
+}
+
+// SubscribeContext registers items for polled refresh. Unknown items are
+// reported per item; the subscription is created when any item is valid.
+func (s *Simulator) SubscribeContext(ctx context.Context, request *service.Subscribe) (*service.SubscribeResponse, error) {
+	received := s.now()
+	options := requestOptions(request.Options)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := &service.SubscribeResponse{
+		SubscribeResult: s.replyBase(received, options.ClientRequestHandle, options.LocaleID),
+		RItemList:       &service.SubscribeReplyItemList{},
+	}
+	if request.ItemList == nil {
+		return resp, nil
+	}
+	sub := &subscription{last: map[int]string{}}
+	results := map[string]bool{}
+	for _, item := range request.ItemList.Items {
+		if item == nil {
+			continue
+		}
+		rate := item.RequestedSamplingRate
+		if rate <= 0 {
+			rate = request.ItemList.RequestedSamplingRate
+		}
+		if rate <= 0 {
+			rate = defaultSamplingRate
+		}
+		itemPath := firstNonEmpty(item.ItemPath, request.ItemList.ItemPath)
+		reply := &service.SubscribeItemValue{RevisedSamplingRate: rate}
+		value := &service.ItemValue{}
+		if n, ok := s.item(item.ItemName); ok {
+			if request.ReturnValuesOnReply {
+				value = s.itemValue(n, received)
+				sub.last[len(sub.items)] = signature(value)
+			}
+			sub.items = append(sub.items, &service.SubscribeRequestItem{ItemPath: itemPath, ItemName: item.ItemName, ClientItemHandle: item.ClientItemHandle})
+		} else {
+			value.ResultID = qname(ResultUnknownItemName)
+			results[ResultUnknownItemName] = true
+		}
+		reply.ItemValue = applyOptions(value, options, itemPath, item.ItemName, item.ClientItemHandle)
+		resp.RItemList.Items = append(resp.RItemList.Items, reply)
+	}
+	resp.Errors = s.opcErrors(options.ReturnErrorText, sortedKeys(results)...)
+	if len(sub.items) == 0 {
+		return resp, nil
+	}
+	s.nextHandle++
+	resp.ServerSubHandle = fmt.Sprintf("sub-%d", s.nextHandle)
+	s.subscriptions[resp.ServerSubHandle] = sub
+	return resp, nil
+}
+
+// SubscriptionPolledRefreshContext returns the items that changed since the
+// previous refresh. It holds the reply until HoldTime and then waits up to
+// WaitTime milliseconds for a change.
+func (s *Simulator) SubscriptionPolledRefreshContext(ctx context.Context, request *service.SubscriptionPolledRefresh) (*service.SubscriptionPolledRefreshResponse, error) {
+	received := s.now()
+	options := requestOptions(request.Options)
+	resp := &service.SubscriptionPolledRefreshResponse{}
+
+	s.mu.Lock()
+	subs := map[string]*subscription{}
+	for _, handle := range request.ServerSubHandles {
+		if sub, ok := s.subscriptions[handle]; ok {
+			subs[handle] = sub
+		} else {
+			resp.InvalidServerSubHandles = append(resp.InvalidServerSubHandles, handle)
+		}
+	}
+	s.mu.Unlock()
+
+	if hold := request.HoldTime.ToGoTime(); !hold.IsZero() {
+		if err := sleepUntil(ctx, hold); err != nil {
+			return nil, err
+		}
+	}
+	wait := time.Duration(request.WaitTime) * time.Millisecond
+	if wait > maxRefreshWait {
+		wait = maxRefreshWait
+	}
+	deadline := time.Now().Add(wait)
+	for {
+		now := s.now()
+		s.mu.Lock()
+		changed := s.refresh(request.ServerSubHandles, subs, request.ReturnAllItems, options, now)
+		if len(changed) > 0 || !time.Now().Before(deadline) {
+			resp.RItemList = changed
+			resp.SubscriptionPolledRefreshResult = s.replyBase(received, options.ClientRequestHandle, options.LocaleID)
+			s.mu.Unlock()
+			return resp, nil
+		}
+		s.mu.Unlock()
+		if err := sleepUntil(ctx, time.Now().Add(refreshPollInterval)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (s *Simulator) refresh(handles []string, subs map[string]*subscription, all bool, options service.RequestOptions, now time.Time) []*service.SubscribePolledRefreshReplyItemList {
+	var lists []*service.SubscribePolledRefreshReplyItemList
+	for _, handle := range handles {
+		sub, ok := subs[handle]
+		if !ok {
+			continue
+		}
+		list := &service.SubscribePolledRefreshReplyItemList{SubscriptionHandle: handle}
+		for i, item := range sub.items {
+			value := &service.ItemValue{ResultID: qname(ResultUnknownItemName)}
+			if n, ok := s.item(item.ItemName); ok {
+				value = s.itemValue(n, now)
+			}
+			sig := signature(value)
+			unchanged := sub.last[i] == sig
+			sub.last[i] = sig
+			if unchanged && !all {
+				continue
+			}
+			list.Items = append(list.Items, applyOptions(value, options, item.ItemPath, item.ItemName, item.ClientItemHandle))
+		}
+		if len(list.Items) > 0 {
+			lists = append(lists, list)
+		}
+	}
+	return lists
+}
+
+// SubscriptionCancelContext removes a subscription. Unknown handles fault
+// with E_NOSUBSCRIPTION.
+func (s *Simulator) SubscriptionCancelContext(ctx context.Context, request *service.SubscriptionCancel) (*service.SubscriptionCancelResponse, error) {
+	s.mu.Lock()
+	_, ok := s.subscriptions[request.ServerSubHandle]
+	delete(s.subscriptions, request.ServerSubHandle)
+	s.mu.Unlock()
+	if !ok {
+		return nil, &soap.SOAPFault{Code: ResultNoSubscription, String: resultText[ResultNoSubscription]}
+	}
+	return &service.SubscriptionCancelResponse{ClientRequestHandle: request.ClientRequestHandle}, nil
+}
+
+func (s *Simulator) GetStatus(request *service.GetStatus) (*service.GetStatusResponse, error) {
+	return s.GetStatusContext(context.Background(), request)
+}
+
+func (s *Simulator) GetProperties(request *service.GetProperties) (*service.GetPropertiesResponse, error) {
+	return s.GetPropertiesContext(context.Background(), request)
+}
+
+func (s *Simulator) Subscribe(request *service.Subscribe) (*service.SubscribeResponse, error) {
+	return s.SubscribeContext(context.Background(), request)
+}
+
+func (s *Simulator) SubscriptionPolledRefresh(request *service.SubscriptionPolledRefresh) (*service.SubscriptionPolledRefreshResponse, error) {
+	return s.SubscriptionPolledRefreshContext(context.Background(), request)
+}
+
+func (s *Simulator) SubscriptionCancel(request *service.SubscriptionCancel) (*service.SubscriptionCancelResponse, error) {
+	return s.SubscriptionCancelContext(context.Background(), request)
+}
+
+func (s *Simulator) Browse(request *service.Browse) (*service.BrowseResponse, error) {
+	return s.BrowseContext(context.Background(), request)
+}
+
+func (s *Simulator) Read(request *service.Read) (*service.ReadResponse, error) {
+	return s.ReadContext(context.Background(), request)
+}
+
+func (s *Simulator) Write(request *service.Write) (*service.WriteResponse, error) {
+	return s.WriteContext(context.Background(), request)
+}
+
+func (s *Simulator) opcErrors(withText bool, results ...string) []*service.OPCError {
+	errs := make([]*service.OPCError, 0, len(results))
+	for _, result := range results {
+		opcErr := &service.OPCError{ID: qname(result)}
+		if withText {
+			opcErr.Text = resultText[result]
+		}
+		errs = append(errs, opcErr)
+	}
+	return errs
+}
+
+func requestOptions(options *service.RequestOptions) service.RequestOptions {
+	if options == nil {
+		return service.RequestOptions{}
+	}
+	return *options
+}
+
+// applyOptions fills in the item identifiers and drops the timestamp as
+// the request options ask.
+func applyOptions(value *service.ItemValue, options service.RequestOptions, itemPath, itemName, clientHandle string) *service.ItemValue {
+	value.ClientItemHandle = clientHandle
+	if options.ReturnItemPath {
+		value.ItemPath = itemPath
+	}
+	if options.ReturnItemName {
+		value.ItemName = itemName
+	}
+	if !options.ReturnItemTime {
+		value.Timestamp = service.XSDDateTime{}
+	}
+	return value
+}
+
+func signature(value *service.ItemValue) string {
+	quality, result := "", ""
+	if value.Quality != nil && value.Quality.QualityField != nil {
+		quality = string(*value.Quality.QualityField)
+	}
+	if value.ResultID != nil {
+		result = string(*value.ResultID)
+	}
+	return value.Value.InnerXML + "\x00" + quality + "\x00" + result
+}
+
+// valueText returns the text of a written value, trimmed unless the item
+// is a string.
+func valueText(xsdType string, value service.AnyType) string {
+	text := html.UnescapeString(value.InnerXML)
+	if xsdType != "string" {
+		text = strings.TrimSpace(text)
+	}
+	return text
+}
+
+func sleepUntil(ctx context.Context, when time.Time) error {
+	delay := time.Until(when)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func xsdTime(t time.Time) service.XSDDateTime {
+	return service.XSDDateTime{XSDDateTime: soap.CreateXsdDateTime(t.UTC(), true)}
+}
+
+func qname(value string) *service.QName {
+	name := service.QName(value)
+	return &name
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
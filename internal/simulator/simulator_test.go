@@ -0,0 +1,278 @@
+package simulator
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hooklift/gowsdl/soap"
+
+	"opc-xml-da-cli/service"
+)
+
+const testModel = `seed: 7
+page_size: 2
+tags:
+  - name: Area
+    children:
+      - name: Wave
+        generator: {kind: sine, offset: 10, amplitude: 2, period: 4s}
+      - name: Ramp
+        generator: {kind: ramp, min: 0, max: 10, period: 10s}
+      - name: Count
+        generator: {kind: counter, interval: 1s}
+        faults:
+          - {quality: badCommFailure, every: 10s, duration: 2s}
+      - name: Mode
+        type: string
+        value: auto
+        writable: true
+`
+
+func newTestSimulator(t *testing.T) (*Simulator, *time.Time) {
+	t.Helper()
+	model, err := ParseModel([]byte(testModel))
+	if err != nil {
+		t.Fatalf("ParseModel() error = %v", err)
+	}
+	sim := New(model)
+	now := sim.start
+	sim.now = func() time.Time { return now }
+	return sim, &now
+}
+
+func readOne(t *testing.T, svc service.OpcXmlDASoap, name string) *service.ItemValue {
+	t.Helper()
+	resp, err := svc.ReadContext(context.Background(), &service.Read{
+		Options:  &service.RequestOptions{ReturnItemName: true, ReturnItemTime: true},
+		ItemList: &service.ReadRequestItemList{Items: []*service.ReadRequestItem{{ItemName: name}}},
+	})
+	if err != nil {
+		t.Fatalf("Read(%s) error = %v", name, err)
+	}
+	return resp.RItemList.Items[0]
+}
+
+func TestGeneratorsAndFaults(t *testing.T) {
+	sim, now := newTestSimulator(t)
+	*now = sim.start.Add(time.Second)
+	if got := readOne(t, sim, "Area.Wave").Value.InnerXML; got != "12" {
+		t.Fatalf("sine at quarter period = %q, want 12", got)
+	}
+	if got := readOne(t, sim, "Area.Ramp").Value.InnerXML; got != "1" {
+		t.Fatalf("ramp at 1s = %q, want 1", got)
+	}
+	*now = sim.start.Add(8500 * time.Millisecond)
+	count := readOne(t, sim, "Area.Count")
+	if count.Value.InnerXML != "8" || count.Value.Type != "int" {
+		t.Fatalf("counter = %q (%s), want 8 (int)", count.Value.InnerXML, count.Value.Type)
+	}
+	if *count.Quality.QualityField != service.QualityBitsBadCommFailure {
+		t.Fatalf("quality during fault = %s", *count.Quality.QualityField)
+	}
+	if got := readOne(t, sim, "Area.Missing").ResultID; got == nil || *got != ResultUnknownItemName {
+		t.Fatalf("unknown item result = %v", got)
+	}
+}
+
+func TestBrowsePagesWithContinuationPoint(t *testing.T) {
+	sim, _ := newTestSimulator(t)
+	var names []string
+	continuation := ""
+	for page := 0; ; page++ {
+		resp, err := sim.BrowseContext(context.Background(), &service.Browse{ItemName: "Area", ContinuationPoint: continuation})
+		if err != nil || len(resp.Errors) > 0 {
+			t.Fatalf("Browse() = %+v, %v", resp, err)
+		}
+		if len(resp.Elements) > 2 {
+			t.Fatalf("page %d has %d elements, want at most 2", page, len(resp.Elements))
+		}
+		for _, el := range resp.Elements {
+			names = append(names, el.ItemName)
+		}
+		if !resp.MoreElements {
+			break
+		}
+		continuation = resp.ContinuationPoint
+	}
+	if got := strings.Join(names, ","); got != "Area.Wave,Area.Ramp,Area.Count,Area.Mode" {
+		t.Fatalf("browsed %s", got)
+	}
+
+	resp, err := sim.BrowseContext(context.Background(), &service.Browse{ItemName: "Area", ContinuationPoint: "Other@2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Errors) != 1 || *resp.Errors[0].ID != ResultInvalidContinuation {
+		t.Fatalf("invalid continuation errors = %+v", resp.Errors)
+	}
+}
+
+func TestWriteChecksAccessAndType(t *testing.T) {
+	sim, _ := newTestSimulator(t)
+	resp, err := sim.WriteContext(context.Background(), &service.Write{
+		Options: &service.RequestOptions{ReturnItemName: true},
+		ItemList: &service.WriteRequestItemList{Items: []*service.ItemValue{
+			{ItemName: "Area.Mode", Value: service.AnyType{InnerXML: "manual", Type: "string"}},
+			{ItemName: "Area.Wave", Value: service.AnyType{InnerXML: "1", Type: "double"}},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.RItemList.Items[0].ResultID != nil {
+		t.Fatalf("writable item result = %s", *resp.RItemList.Items[0].ResultID)
+	}
+	if got := resp.RItemList.Items[1].ResultID; got == nil || *got != ResultReadOnly {
+		t.Fatalf("read-only item result = %v", got)
+	}
+	if got := readOne(t, sim, "Area.Mode").Value.InnerXML; got != "manual" {
+		t.Fatalf("Mode after write = %q", got)
+	}
+}
+
+func TestSetValueAddsItemsAndOverridesModel(t *testing.T) {
+	sim, _ := newTestSimulator(t)
+	sim.SetValue("Area.Tank.Level", service.AnyType{InnerXML: "3", Type: "int"}, service.QualityBitsUncertain)
+	sim.SetValue("Area.Wave", service.AnyType{InnerXML: "1.5", Type: "double"}, service.QualityBitsGood)
+	sim.SetPageSize(0)
+	branches := service.BrowseFilterBranch
+	resp, err := sim.BrowseContext(context.Background(), &service.Browse{ItemName: "Area", BrowseFilter: &branches})
+	if err != nil || len(resp.Elements) != 1 || resp.Elements[0].ItemName != "Area.Tank" {
+		t.Fatalf("Browse(branches) = %+v, %v", resp, err)
+	}
+	level := readOne(t, sim, "Area.Tank.Level")
+	if level.Value.InnerXML != "3" || level.Value.Type != "int" || *level.Quality.QualityField != service.QualityBitsUncertain {
+		t.Fatalf("Level = %+v", level)
+	}
+	if got := readOne(t, sim, "Area.Wave").Value.InnerXML; got != "1.5" {
+		t.Fatalf("Wave after SetValue = %q, want 1.5", got)
+	}
+
+	sim.SetItemResult("Area.Mode", ResultBadType)
+	if got := readOne(t, sim, "Area.Mode").ResultID; got == nil || *got != ResultBadType {
+		t.Fatalf("Mode result = %v", got)
+	}
+	sim.SetWritable("Area.Tank.Level", false)
+	write, err := sim.WriteContext(context.Background(), &service.Write{ItemList: &service.WriteRequestItemList{Items: []*service.ItemValue{
+		{ItemName: "Area.Tank.Level", Value: service.AnyType{InnerXML: "4", Type: "int"}},
+	}}})
+	if err != nil || write.RItemList.Items[0].ResultID == nil || *write.RItemList.Items[0].ResultID != ResultReadOnly {
+		t.Fatalf("Write(read-only) = %+v, %v", write, err)
+	}
+}
+
+func TestPolledRefreshReturnsChanges(t *testing.T) {
+	sim, now := newTestSimulator(t)
+	sub, err := sim.SubscribeContext(context.Background(), &service.Subscribe{
+		ItemList:            &service.SubscribeRequestItemList{Items: []*service.SubscribeRequestItem{{ItemName: "Area.Count"}, {ItemName: "Area.Mode"}}},
+		ReturnValuesOnReply: true,
+	})
+	if err != nil || sub.ServerSubHandle == "" {
+		t.Fatalf("Subscribe() = %+v, %v", sub, err)
+	}
+	*now = sim.start.Add(3 * time.Second)
+	refresh, err := sim.SubscriptionPolledRefreshContext(context.Background(), &service.SubscriptionPolledRefresh{
+		Options:          &service.RequestOptions{ReturnItemName: true},
+		ServerSubHandles: []string{sub.ServerSubHandle, "missing"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refresh.InvalidServerSubHandles) != 1 || refresh.InvalidServerSubHandles[0] != "missing" {
+		t.Fatalf("invalid handles = %v", refresh.InvalidServerSubHandles)
+	}
+	if len(refresh.RItemList) != 1 || len(refresh.RItemList[0].Items) != 1 || refresh.RItemList[0].Items[0].ItemName != "Area.Count" {
+		t.Fatalf("refresh items = %+v", refresh.RItemList)
+	}
+
+	if _, err := sim.SubscriptionCancelContext(context.Background(), &service.SubscriptionCancel{ServerSubHandle: sub.ServerSubHandle}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = sim.SubscriptionCancelContext(context.Background(), &service.SubscriptionCancel{ServerSubHandle: sub.ServerSubHandle})
+	var fault *soap.SOAPFault
+	if !errors.As(err, &fault) || fault.Code != ResultNoSubscription {
+		t.Fatalf("second cancel error = %v", err)
+	}
+}
+
+func TestSimulatorOverSOAP(t *testing.T) {
+	sim, _ := newTestSimulator(t)
+	server := httptest.NewServer(service.NewHandler(sim))
+	defer server.Close()
+	client := service.NewOpcXmlDASoap(soap.NewClient(server.URL))
+
+	status, err := client.GetStatusContext(context.Background(), &service.GetStatus{ClientRequestHandle: "test"})
+	if err != nil {
+		t.Fatalf("GetStatus() error = %v", err)
+	}
+	if status.Status.VendorInfo != "opc-xml-da-cli simulator" || *status.GetStatusResult.ServerState != service.ServerStateRunning {
+		t.Fatalf("status = %+v", status.Status)
+	}
+	value := readOne(t, client, "Area.Mode")
+	if value.Value.InnerXML != "auto" || value.Value.Type != "string" || value.ItemName != "Area.Mode" {
+		t.Fatalf("read over SOAP = %+v", value)
+	}
+	props, err := client.GetPropertiesContext(context.Background(), &service.GetProperties{
+		ItemIDs:              []*service.ItemIdentifier{{ItemName: "Area.Mode"}},
+		ReturnPropertyValues: true,
+	})
+	if err != nil {
+		t.Fatalf("GetProperties() error = %v", err)
+	}
+	found := false
+	for _, prop := range props.PropertyLists[0].Properties {
+		if *prop.Name == "accessRights" {
+			found = prop.Value.InnerXML == "readWritable"
+		}
+	}
+	if !found {
+		t.Fatalf("accessRights property missing or wrong: %+v", props.PropertyLists[0].Properties)
+	}
+	// Faults are sent with status 500 as SOAP 1.1 requires, which the
+	// generated client reports as an HTTP error carrying the fault body.
+	_, err = client.SubscriptionCancelContext(context.Background(), &service.SubscriptionCancel{ServerSubHandle: "nope"})
+	var httpErr *soap.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != 500 || !strings.Contains(string(httpErr.ResponseBody), ResultNoSubscription) {
+		t.Fatalf("cancel fault = %v", err)
+	}
+}
+
+func TestParseModelRejectsBadGenerator(t *testing.T) {
+	_, err := ParseModel([]byte("tags:\n  - name: X\n    generator: {kind: square}\n"))
+	if err == nil || !strings.Contains(err.Error(), "unknown generator kind") {
+		t.Fatalf("ParseModel() error = %v", err)
+	}
+	_, err = ParseModel([]byte("tags:\n  - name: X\n    generator: {kind: counter, max: 10, step: -1}\n"))
+	if err == nil || !strings.Contains(err.Error(), "positive step") {
+		t.Fatalf("ParseModel(counter with negative step) error = %v", err)
+	}
+	if _, err := ParseModel([]byte(defaultModelYAML)); err != nil {
+		t.Fatalf("default model: %v", err)
+	}
+}
+
+func TestCounterWrapsOnlyWithMax(t *testing.T) {
+	model, err := ParseModel([]byte(`tags:
+  - name: Count
+    generator: {kind: counter, interval: 1s}
+  - name: Wrap
+    generator: {kind: counter, min: 0, max: 10, step: 5, interval: 1s}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim := New(model)
+	now := sim.start.Add(150 * time.Second)
+	sim.now = func() time.Time { return now }
+	if got := readOne(t, sim, "Count").Value.InnerXML; got != "150" {
+		t.Fatalf("counter without max at 150s = %q, want 150", got)
+	}
+	now = sim.start.Add(3 * time.Second)
+	if got := readOne(t, sim, "Wrap").Value.InnerXML; got != "0" {
+		t.Fatalf("counter with max 10 at 3s = %q, want 0", got)
+	}
+}
@@ -0,0 +1,279 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultInfluxMeasurement is the measurement name used for line protocol
+// output unless configured otherwise.
+const DefaultInfluxMeasurement = "opcxmlda"
+
+// InfluxLine renders a sample as one line of InfluxDB line protocol. Item
+// identifiers and quality are tags; numeric values are written to the float
+// field "value" and anything else, including NaN and infinities that line
+// protocol cannot carry as floats, to the string field "value_string", so one
+// measurement never mixes field types. The timestamp is the server item
+// timestamp in nanoseconds, falling back to the client receive time.
+func InfluxLine(measurement string, sample Sample) string {
+	if measurement == "" {
+		measurement = DefaultInfluxMeasurement
+	}
+	var b strings.Builder
+	b.WriteString(influxEscape(measurement, ", "))
+	writeInfluxTag(&b, "item_path", sample.ItemPath)
+	writeInfluxTag(&b, "item_name", sample.ItemName)
+	writeInfluxTag(&b, "quality", sample.Quality)
+	b.WriteByte(' ')
+	if number, ok := ParseNumber(sample.Value); ok && !math.IsNaN(number) && !math.IsInf(number, 0) {
+		b.WriteString("value=")
+		b.WriteString(strconv.FormatFloat(number, 'g', -1, 64))
+	} else {
+		b.WriteString(`value_string="`)
+		b.WriteString(influxEscape(sample.Value, `"\`))
+		b.WriteByte('"')
+	}
+	if sample.ResultID != "" {
+		b.WriteString(`,result_id="`)
+		b.WriteString(influxEscape(sample.ResultID, `"\`))
+		b.WriteByte('"')
+	}
+	timestamp := sample.Timestamp
+	if timestamp.IsZero() {
+		timestamp = sample.Received
+	}
+	if !timestamp.IsZero() {
+		b.WriteByte(' ')
+		b.WriteString(strconv.FormatInt(timestamp.UnixNano(), 10))
+	}
+	return b.String()
+}
+
+// ParseNumber converts a formatted item value into a float. Booleans map to 0
+// and 1; any other non-numeric value is reported as not ok.
+func ParseNumber(value string) (float64, bool) {
+	value = strings.TrimSpace(value)
+	switch strings.ToLower(value) {
+	case "true":
+		return 1, true
+	case "false":
+		return 0, true
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, false
+	}
+	return number, true
+}
+
+func writeInfluxTag(b *strings.Builder, key, value string) {
+	if value == "" {
+		return
+	}
+	b.WriteByte(',')
+	b.WriteString(key)
+	b.WriteByte('=')
+	b.WriteString(influxEscape(value, ",= "))
+}
+
+func influxEscape(value, special string) string {
+	var b strings.Builder
+	for _, r := range value {
+		switch {
+		case r == '\n':
+			b.WriteString(`\n`)
+			continue
+		case strings.ContainsRune(special, r):
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// influxSink batches line protocol points and posts them to an
+// InfluxDB-compatible HTTP write endpoint.
+type influxSink struct {
+	endpoint      string
+	token         string
+	username      string
+	password      string
+	measurement   string
+	batchSize     int
+	flushInterval time.Duration
+	retries       int
+	backoff       time.Duration
+	client        *http.Client
+
+	pending    []string
+	firstQueue time.Time
+}
+
+func newInfluxSink(u *url.URL) (*influxSink, error) {
+	query := u.Query()
+	s := &influxSink{
+		measurement: DefaultInfluxMeasurement,
+		batchSize:   500,
+		retries:     3,
+		token:       os.Getenv("INFLUX_TOKEN"),
+	}
+	if v := query.Get("measurement"); v != "" {
+		s.measurement = v
+	}
+	if v := query.Get("token"); v != "" {
+		s.token = v
+	}
+	var err error
+	if v := query.Get("batch_size"); v != "" {
+		if s.batchSize, err = strconv.Atoi(v); err != nil || s.batchSize < 1 {
+			return nil, fmt.Errorf("influx sink: batch_size must be a positive integer")
+		}
+	}
+	if v := query.Get("retries"); v != "" {
+		if s.retries, err = strconv.Atoi(v); err != nil || s.retries < 0 {
+			return nil, fmt.Errorf("influx sink: retries must be zero or greater")
+		}
+	}
+	if s.flushInterval, err = queryDuration(query, "flush_interval", 10*time.Second); err != nil {
+		return nil, fmt.Errorf("influx sink: %w", err)
+	}
+	if s.backoff, err = queryDuration(query, "backoff", time.Second); err != nil {
+		return nil, fmt.Errorf("influx sink: %w", err)
+	}
+	timeout, err := queryDuration(query, "timeout", 30*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("influx sink: %w", err)
+	}
+	s.client = &http.Client{Timeout: timeout}
+	if u.User != nil {
+		s.username = u.User.Username()
+		s.password, _ = u.User.Password()
+	}
+	if u.Host == "" {
+		return nil, errors.New("influx sink: host is required")
+	}
+
+	target := *u
+	target.User = nil
+	target.Scheme = "http"
+	if u.Scheme == "influxs" {
+		target.Scheme = "https"
+	}
+	if target.Path == "" || target.Path == "/" {
+		target.Path = "/api/v2/write"
+	}
+	for _, name := range []string{"measurement", "token", "batch_size", "flush_interval", "retries", "backoff", "timeout"} {
+		query.Del(name)
+	}
+	target.RawQuery = query.Encode()
+	s.endpoint = target.String()
+	return s, nil
+}
+
+func (s *influxSink) Write(ctx context.Context, samples []Sample) error {
+	if len(s.pending) == 0 {
+		s.firstQueue = time.Now()
+	}
+	for _, sample := range samples {
+		s.pending = append(s.pending, InfluxLine(s.measurement, sample))
+	}
+	if len(s.pending) >= s.batchSize || time.Since(s.firstQueue) >= s.flushInterval {
+		return s.flush(ctx)
+	}
+	return nil
+}
+
+func (s *influxSink) Close() error {
+	return s.flush(context.Background())
+}
+
+func (s *influxSink) flush(ctx context.Context) error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	body := []byte(strings.Join(s.pending, "\n") + "\n")
+	delay := s.backoff
+	var err error
+	for attempt := 0; attempt <= s.retries; attempt++ {
+		if attempt > 0 {
+			slog.Info("influx write retry", "attempt", attempt, "delay", delay, "err", err)
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("influx sink: %w", ctx.Err())
+			case <-time.After(delay):
+			}
+			delay *= 2
+		}
+		var retry bool
+		retry, err = s.post(ctx, body)
+		if err == nil {
+			s.pending = s.pending[:0]
+			return nil
+		}
+		if !retry {
+			// Sending the same batch again cannot succeed, and keeping it
+			// would fail every later flush too.
+			s.pending = s.pending[:0]
+			var rejected *influxWriteError
+			if errors.As(err, &rejected) && rejected.status == http.StatusBadRequest {
+				slog.Warn("influx rejected batch; dropped", "err", err, "batch", string(body))
+				return nil
+			}
+			return fmt.Errorf("influx sink: %w", err)
+		}
+	}
+	// The server stayed unavailable through every retry. Keeping the batch
+	// would grow the buffer without bound and stall every later Write on
+	// the same backoff, so the batch is dropped and the capture goes on.
+	slog.Warn("influx write failed after retries; batch dropped", "err", err, "retries", s.retries, "lines", len(s.pending))
+	s.pending = s.pending[:0]
+	return nil
+}
+
+// influxWriteError is a write the server answered with an error status.
+type influxWriteError struct {
+	status int
+	text   string
+	detail string
+}
+
+func (e *influxWriteError) Error() string {
+	return fmt.Sprintf("write returned %s: %s", e.text, e.detail)
+}
+
+// post sends one batch and reports whether a failure is worth retrying.
+func (s *influxSink) post(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Token "+s.token)
+	} else if s.username != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return false, nil
+	}
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = &influxWriteError{status: resp.StatusCode, text: resp.Status, detail: strings.TrimSpace(string(detail))}
+	return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests, err
+}
@@ -0,0 +1,184 @@
+package sink
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestInfluxLine(t *testing.T) {
+	ts := time.Unix(1700000000, 123)
+	tests := []struct {
+		name   string
+		sample Sample
+		want   string
+	}{
+		{
+			name:   "numeric value with server timestamp",
+			sample: Sample{ItemName: "Area 1.Temp", Value: "21.5", Quality: "good", Timestamp: ts},
+			want:   `opcxmlda,item_name=Area\ 1.Temp,quality=good value=21.5 1700000000000000123`,
+		},
+		{
+			name:   "string value falls back to receive time",
+			sample: Sample{ItemPath: "a,b", ItemName: "Name", Value: `say "hi"`, Received: ts},
+			want:   `opcxmlda,item_path=a\,b,item_name=Name value_string="say \"hi\"" 1700000000000000123`,
+		},
+		{
+			name:   "boolean value is numeric",
+			sample: Sample{ItemName: "Run", Value: "true", ResultID: "S_CLAMP"},
+			want:   `opcxmlda,item_name=Run value=1,result_id="S_CLAMP"`,
+		},
+		{
+			name:   "non-finite floats are strings",
+			sample: Sample{ItemName: "Flow", Value: "INF"},
+			want:   `opcxmlda,item_name=Flow value_string="INF"`,
+		},
+		{
+			name:   "NaN is a string",
+			sample: Sample{ItemName: "Flow", Value: "NaN"},
+			want:   `opcxmlda,item_name=Flow value_string="NaN"`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := InfluxLine("", test.sample); got != test.want {
+				t.Fatalf("InfluxLine() = %s\nwant %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestInfluxSinkBatchesAndRetries(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	var auth string
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts == 1 {
+			http.Error(w, "warming up", http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, r.URL.RequestURI()+"\n"+string(body))
+		auth = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(strings.Replace(server.URL, "http://", "influx://", 1) + "/api/v2/write?bucket=opc&org=plant&token=secret&batch_size=2&backoff=1ms&measurement=tags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := newInfluxSink(u)
+	if err != nil {
+		t.Fatalf("newInfluxSink returned error: %v", err)
+	}
+	ctx := context.Background()
+	if err := s.Write(ctx, []Sample{{ItemName: "A", Value: "1"}}); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	if attempts != 0 {
+		t.Fatalf("sink flushed before batch was full")
+	}
+	if err := s.Write(ctx, []Sample{{ItemName: "B", Value: "2"}}); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	if err := s.Write(ctx, []Sample{{ItemName: "C", Value: "x"}}); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	if attempts != 3 || len(bodies) != 2 {
+		t.Fatalf("attempts = %d, bodies = %d; want 3 attempts and 2 batches", attempts, len(bodies))
+	}
+	if auth != "Token secret" {
+		t.Fatalf("Authorization = %q", auth)
+	}
+	want := "/api/v2/write?bucket=opc&org=plant\ntags,item_name=A value=1\ntags,item_name=B value=2\n"
+	if bodies[0] != want {
+		t.Fatalf("first batch = %q, want %q", bodies[0], want)
+	}
+	if !strings.Contains(bodies[1], `tags,item_name=C value_string="x"`) {
+		t.Fatalf("second batch = %q", bodies[1])
+	}
+}
+
+func TestInfluxSinkDoesNotRetryClientErrors(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		http.Error(w, "bad line", http.StatusBadRequest)
+	}))
+	defer server.Close()
+	u, _ := url.Parse(strings.Replace(server.URL, "http://", "influx://", 1) + "/write?db=opc&backoff=1ms")
+	s, err := newInfluxSink(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write(context.Background(), []Sample{{ItemName: "A", Value: "1"}}); err != nil {
+		t.Fatalf("Write returned error before flush: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close error = %v, want the rejected batch dropped", err)
+	}
+	if attempts != 1 || len(s.pending) != 0 {
+		t.Fatalf("attempts = %d, pending %q, want one attempt and nothing pending", attempts, s.pending)
+	}
+	if err := s.Close(); err != nil || attempts != 1 {
+		t.Fatalf("second Close = %v after %d attempts, want the batch not resent", err, attempts)
+	}
+}
+
+func TestInfluxSinkDropsBatchWhenRetriesRunOut(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		http.Error(w, "maintenance", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	u, _ := url.Parse(strings.Replace(server.URL, "http://", "influx://", 1) + "/write?db=opc&batch_size=1&retries=2&backoff=1ms")
+	s, err := newInfluxSink(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := s.Write(context.Background(), []Sample{{ItemName: "A", Value: "1"}}); err != nil {
+			t.Fatalf("Write %d error = %v, want the batch dropped", i, err)
+		}
+		if len(s.pending) != 0 {
+			t.Fatalf("pending = %q after retries ran out", s.pending)
+		}
+	}
+	if attempts != 9 {
+		t.Fatalf("attempts = %d, want 3 per batch", attempts)
+	}
+}
+
+func TestInfluxSinkFailsOnUnauthorized(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad token", http.StatusUnauthorized)
+	}))
+	defer server.Close()
+	u, _ := url.Parse(strings.Replace(server.URL, "http://", "influx://", 1) + "/write?db=opc&batch_size=1")
+	s, err := newInfluxSink(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write(context.Background(), []Sample{{ItemName: "A", Value: "1"}}); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("Write error = %v, want 401 error", err)
+	}
+	if len(s.pending) != 0 {
+		t.Fatalf("pending = %q after a non-retryable error", s.pending)
+	}
+}
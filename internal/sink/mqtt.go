@@ -0,0 +1,552 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	mqttConnect    = 1
+	mqttConnAck    = 2
+	mqttPublish    = 3
+	mqttPubAck     = 4
+	mqttPingReq    = 12
+	mqttPingResp   = 13
+	mqttDisconnect = 14
+
+	defaultMQTTTopic = "{prefix}/{item}"
+	// maxMQTTKeepAlive is the largest keep-alive CONNECT can carry, in
+	// whole seconds as a 16-bit number.
+	maxMQTTKeepAlive = 65535 * time.Second
+)
+
+type mqttConfig struct {
+	Address     string
+	TLS         *tls.Config
+	ClientID    string
+	Username    string
+	Password    string
+	HasPassword bool
+	Prefix      string
+	Topic       string
+	StateTopic  string
+	QoS         byte
+	Retain      bool
+	Will        bool
+	KeepAlive   time.Duration
+	Timeout     time.Duration
+}
+
+// mqttSink publishes samples as JSON using MQTT 3.1.1. Only the subset of the
+// protocol needed by a publisher is implemented: CONNECT with last will,
+// PUBLISH at QoS 0 or 1, keep-alive pings and DISCONNECT.
+//
+// A background goroutine pings the broker every half keep-alive while the
+// connection is idle, so polls spaced further apart than the keep-alive do
+// not make the broker drop the session and publish the last will. The will
+// carries the server state known at CONNECT; later changes of server state
+// are published as retained state messages on the open connection.
+type mqttSink struct {
+	cfg mqttConfig
+
+	mu        sync.Mutex
+	conn      net.Conn
+	reader    *bufio.Reader
+	stopPings chan struct{}
+	nextID    uint16
+	lastState string
+	lastSend  time.Time
+}
+
+func newMQTTSink(u *url.URL) (*mqttSink, error) {
+	cfg, err := parseMQTTURL(u)
+	if err != nil {
+		return nil, fmt.Errorf("mqtt sink: %w", err)
+	}
+	return &mqttSink{cfg: cfg}, nil
+}
+
+func parseMQTTURL(u *url.URL) (mqttConfig, error) {
+	query := u.Query()
+	cfg := mqttConfig{
+		Prefix:   strings.Trim(u.Path, "/"),
+		Topic:    defaultMQTTTopic,
+		ClientID: fmt.Sprintf("opc-xml-da-cli-%d", os.Getpid()),
+		Will:     true,
+	}
+	host := u.Hostname()
+	if host == "" {
+		return cfg, errors.New("broker host is required")
+	}
+	port := u.Port()
+	if port == "" {
+		port = "1883"
+		if u.Scheme == "mqtts" {
+			port = "8883"
+		}
+	}
+	cfg.Address = net.JoinHostPort(host, port)
+	if u.User != nil {
+		cfg.Username = u.User.Username()
+		cfg.Password, cfg.HasPassword = u.User.Password()
+		if cfg.HasPassword && cfg.Username == "" {
+			// MQTT 3.1.1 does not allow a password without a user name.
+			return cfg, errors.New("a password needs a user name")
+		}
+	}
+	if v := query.Get("client_id"); v != "" {
+		cfg.ClientID = v
+	}
+	if v := query.Get("topic"); v != "" {
+		cfg.Topic = v
+	}
+	cfg.StateTopic = joinTopic(cfg.Prefix, "state")
+	if v := query.Get("state_topic"); v != "" {
+		cfg.StateTopic = v
+	}
+	switch query.Get("qos") {
+	case "", "0":
+	case "1":
+		cfg.QoS = 1
+	default:
+		return cfg, fmt.Errorf("qos: only 0 and 1 are supported, got %q", query.Get("qos"))
+	}
+	var err error
+	if cfg.Retain, err = queryBool(query, "retain", false); err != nil {
+		return cfg, err
+	}
+	if cfg.Will, err = queryBool(query, "will", true); err != nil {
+		return cfg, err
+	}
+	if cfg.KeepAlive, err = queryDuration(query, "keepalive", 30*time.Second); err != nil {
+		return cfg, err
+	}
+	if cfg.KeepAlive < 0 || cfg.KeepAlive > maxMQTTKeepAlive {
+		return cfg, fmt.Errorf("keepalive: must be between 0s and %s, got %s", maxMQTTKeepAlive, cfg.KeepAlive)
+	}
+	if cfg.Timeout, err = queryDuration(query, "timeout", 10*time.Second); err != nil {
+		return cfg, err
+	}
+	if u.Scheme == "mqtts" {
+		tlsCfg, err := mqttTLSConfig(query, host)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.TLS = tlsCfg
+	}
+	return cfg, nil
+}
+
+func mqttTLSConfig(query url.Values, host string) (*tls.Config, error) {
+	insecure, err := queryBool(query, "insecure_skip_verify", false)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{ServerName: host, InsecureSkipVerify: insecure, MinVersion: tls.VersionTLS12}
+	if caPath := query.Get("ca_cert"); caPath != "" {
+		pem, err := os.ReadFile(caPath)
+		if err != nil {
+			return nil, fmt.Errorf("ca_cert: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca_cert: no certificates found in %q", caPath)
+		}
+		cfg.RootCAs = pool
+	}
+	certPath, keyPath := query.Get("client_cert"), query.Get("client_key")
+	if certPath != "" || keyPath != "" {
+		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("client_cert: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+func (s *mqttSink) Write(ctx context.Context, samples []Sample) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.publishSamples(ctx, samples)
+	if err == nil {
+		return nil
+	}
+	// One reconnect attempt covers brokers that restarted or dropped an idle
+	// connection between polls.
+	slog.Info("mqtt publish failed; reconnecting", "broker", s.cfg.Address, "err", err)
+	s.closeConn()
+	if err := s.publishSamples(ctx, samples); err != nil {
+		s.closeConn()
+		return fmt.Errorf("mqtt sink: %w", err)
+	}
+	return nil
+}
+
+func (s *mqttSink) publishSamples(ctx context.Context, samples []Sample) error {
+	if s.conn == nil {
+		// Connect with the current server state in the will.
+		for _, sample := range samples {
+			if sample.ServerState != "" {
+				s.lastState = sample.ServerState
+			}
+		}
+	}
+	if err := s.ensureConnected(ctx); err != nil {
+		return err
+	}
+	for _, sample := range samples {
+		if sample.ServerState != "" && sample.ServerState != s.lastState {
+			s.lastState = sample.ServerState
+			if err := s.publishState(true, s.lastState); err != nil {
+				return err
+			}
+		}
+		payload, err := json.Marshal(sample)
+		if err != nil {
+			return err
+		}
+		if err := s.publish(s.topicFor(sample), payload, s.cfg.QoS, s.cfg.Retain); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *mqttSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	if s.cfg.Will {
+		_ = s.publishState(false, s.lastState)
+	}
+	s.disconnect()
+	return nil
+}
+
+// disconnect ends the session cleanly, so the broker discards the will.
+func (s *mqttSink) disconnect() {
+	_ = s.writePacket(mqttDisconnect<<4, nil)
+	s.closeConn()
+}
+
+func (s *mqttSink) topicFor(sample Sample) string {
+	replacer := strings.NewReplacer(
+		"{prefix}", s.cfg.Prefix,
+		"{item}", sanitizeTopicLevel(sample.Item()),
+		"{item_name}", sanitizeTopicLevel(sample.ItemName),
+		"{item_path}", sanitizeTopicLevel(sample.ItemPath),
+	)
+	return strings.TrimPrefix(replacer.Replace(s.cfg.Topic), "/")
+}
+
+func (s *mqttSink) publishState(online bool, state string) error {
+	if s.cfg.StateTopic == "" {
+		return nil
+	}
+	payload, err := json.Marshal(mqttStatePayload(online, state))
+	if err != nil {
+		return err
+	}
+	return s.publish(s.cfg.StateTopic, payload, s.cfg.QoS, true)
+}
+
+func mqttStatePayload(online bool, state string) map[string]interface{} {
+	payload := map[string]interface{}{"online": online}
+	if state != "" {
+		payload["server_state"] = state
+	}
+	return payload
+}
+
+func (s *mqttSink) ensureConnected(ctx context.Context) error {
+	if s.conn != nil {
+		return nil
+	}
+	dialer := &net.Dialer{Timeout: s.cfg.Timeout}
+	var conn net.Conn
+	var err error
+	if s.cfg.TLS != nil {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: s.cfg.TLS}).DialContext(ctx, "tcp", s.cfg.Address)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", s.cfg.Address)
+	}
+	if err != nil {
+		return fmt.Errorf("connect %s: %w", s.cfg.Address, err)
+	}
+	s.conn = conn
+	s.reader = bufio.NewReader(conn)
+	if err := s.writePacket(mqttConnect<<4, s.connectPayload()); err != nil {
+		s.closeConn()
+		return err
+	}
+	packetType, body, err := s.readPacket()
+	if err != nil {
+		s.closeConn()
+		return fmt.Errorf("read connack: %w", err)
+	}
+	if packetType != mqttConnAck || len(body) != 2 {
+		s.closeConn()
+		return fmt.Errorf("unexpected packet type %d waiting for connack", packetType)
+	}
+	if body[1] != 0 {
+		s.closeConn()
+		return fmt.Errorf("broker refused connection: %s", connAckReason(body[1]))
+	}
+	slog.Info("mqtt connected", "broker", s.cfg.Address, "client_id", s.cfg.ClientID)
+	if s.cfg.KeepAlive > 0 {
+		s.stopPings = make(chan struct{})
+		go s.keepAlive(conn, s.stopPings)
+	}
+	if s.cfg.Will {
+		if err := s.publishState(true, s.lastState); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *mqttSink) connectPayload() []byte {
+	var b []byte
+	b = appendMQTTString(b, "MQTT")
+	b = append(b, 4)
+	flags := byte(0x02)
+	if s.cfg.Will && s.cfg.StateTopic != "" {
+		flags |= 0x04 | 0x20 | s.cfg.QoS<<3
+	}
+	if s.cfg.Username != "" {
+		flags |= 0x80
+	}
+	if s.cfg.HasPassword {
+		flags |= 0x40
+	}
+	b = append(b, flags)
+	// CONNECT carries whole seconds; a shorter keep-alive rounds up.
+	b = binary.BigEndian.AppendUint16(b, uint16((s.cfg.KeepAlive+time.Second-1)/time.Second))
+	b = appendMQTTString(b, s.cfg.ClientID)
+	if flags&0x04 != 0 {
+		will, _ := json.Marshal(mqttStatePayload(false, s.lastState))
+		b = appendMQTTString(b, s.cfg.StateTopic)
+		b = appendMQTTBytes(b, will)
+	}
+	if s.cfg.Username != "" {
+		b = appendMQTTString(b, s.cfg.Username)
+	}
+	if s.cfg.HasPassword {
+		b = appendMQTTString(b, s.cfg.Password)
+	}
+	return b
+}
+
+func (s *mqttSink) publish(topic string, payload []byte, qos byte, retain bool) error {
+	header := byte(mqttPublish << 4)
+	header |= qos << 1
+	if retain {
+		header |= 0x01
+	}
+	body := appendMQTTString(nil, topic)
+	var id uint16
+	if qos > 0 {
+		s.nextID++
+		if s.nextID == 0 {
+			s.nextID = 1
+		}
+		id = s.nextID
+		body = binary.BigEndian.AppendUint16(body, id)
+	}
+	body = append(body, payload...)
+	if err := s.writePacket(header, body); err != nil {
+		return err
+	}
+	if qos == 0 {
+		return nil
+	}
+	return s.waitFor(mqttPubAck, id)
+}
+
+// keepAlive pings the broker on conn whenever nothing has been sent for half
+// the keep-alive, until stop is closed. A failed ping drops the connection;
+// the next Write reconnects.
+func (s *mqttSink) keepAlive(conn net.Conn, stop <-chan struct{}) {
+	interval := s.cfg.KeepAlive / 2
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		s.mu.Lock()
+		if s.conn == conn && time.Since(s.lastSend) >= interval {
+			if err := s.ping(); err != nil {
+				slog.Info("mqtt keep-alive failed", "broker", s.cfg.Address, "err", err)
+				s.closeConn()
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *mqttSink) ping() error {
+	if err := s.writePacket(mqttPingReq<<4, nil); err != nil {
+		return err
+	}
+	return s.waitFor(mqttPingResp, 0)
+}
+
+// waitFor reads packets until the expected acknowledgement arrives. Packets
+// that a publisher does not act on are skipped.
+func (s *mqttSink) waitFor(packetType byte, id uint16) error {
+	for {
+		got, body, err := s.readPacket()
+		if err != nil {
+			return err
+		}
+		if got != packetType {
+			continue
+		}
+		if packetType == mqttPubAck && (len(body) < 2 || binary.BigEndian.Uint16(body) != id) {
+			continue
+		}
+		return nil
+	}
+}
+
+func (s *mqttSink) writePacket(header byte, body []byte) error {
+	if s.conn == nil {
+		return errors.New("not connected")
+	}
+	packet := []byte{header}
+	packet = appendRemainingLength(packet, len(body))
+	packet = append(packet, body...)
+	if s.cfg.Timeout > 0 {
+		_ = s.conn.SetWriteDeadline(time.Now().Add(s.cfg.Timeout))
+	}
+	if _, err := s.conn.Write(packet); err != nil {
+		return err
+	}
+	s.lastSend = time.Now()
+	return nil
+}
+
+func (s *mqttSink) readPacket() (byte, []byte, error) {
+	if s.cfg.Timeout > 0 {
+		_ = s.conn.SetReadDeadline(time.Now().Add(s.cfg.Timeout))
+	}
+	header, err := s.reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, err := readRemainingLength(s.reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(s.reader, body); err != nil {
+		return 0, nil, err
+	}
+	return header >> 4, body, nil
+}
+
+func (s *mqttSink) closeConn() {
+	if s.stopPings != nil {
+		close(s.stopPings)
+		s.stopPings = nil
+	}
+	if s.conn != nil {
+		_ = s.conn.Close()
+	}
+	s.conn = nil
+	s.reader = nil
+}
+
+func appendMQTTString(b []byte, value string) []byte {
+	return appendMQTTBytes(b, []byte(value))
+}
+
+func appendMQTTBytes(b []byte, value []byte) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(value)))
+	return append(b, value...)
+}
+
+func appendRemainingLength(b []byte, length int) []byte {
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		b = append(b, digit)
+		if length == 0 {
+			return b
+		}
+	}
+}
+
+func readRemainingLength(r io.ByteReader) (int, error) {
+	length := 0
+	multiplier := 1
+	for i := 0; i < 4; i++ {
+		digit, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		length += int(digit&0x7f) * multiplier
+		if digit&0x80 == 0 {
+			return length, nil
+		}
+		multiplier *= 128
+	}
+	return 0, errors.New("malformed remaining length")
+}
+
+func joinTopic(parts ...string) string {
+	nonEmpty := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+	return strings.Join(nonEmpty, "/")
+}
+
+// sanitizeTopicLevel replaces MQTT wildcard characters, which are not allowed
+// in published topic names.
+func sanitizeTopicLevel(value string) string {
+	return strings.NewReplacer("+", "_", "#", "_").Replace(value)
+}
+
+func connAckReason(code byte) string {
+	switch code {
+	case 1:
+		return "unacceptable protocol version"
+	case 2:
+		return "identifier rejected"
+	case 3:
+		return "server unavailable"
+	case 4:
+		return "bad username or password"
+	case 5:
+		return "not authorized"
+	default:
+		return "return code " + strconv.Itoa(int(code))
+	}
+}
@@ -0,0 +1,258 @@
+package sink
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type mqttPacket struct {
+	Header byte
+	Body   []byte
+}
+
+// startFakeBroker accepts connections, acknowledges CONNECT, PINGREQ and
+// QoS 1 PUBLISH packets, and reports every packet it receives.
+func startFakeBroker(t *testing.T) (string, <-chan mqttPacket) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	packets := make(chan mqttPacket, 64)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakeBroker(conn, packets)
+		}
+	}()
+	return ln.Addr().String(), packets
+}
+
+func serveFakeBroker(conn net.Conn, packets chan<- mqttPacket) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		header, err := reader.ReadByte()
+		if err != nil {
+			return
+		}
+		length, err := readRemainingLength(reader)
+		if err != nil {
+			return
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(reader, body); err != nil {
+			return
+		}
+		packets <- mqttPacket{Header: header, Body: body}
+		switch header >> 4 {
+		case mqttConnect:
+			conn.Write([]byte{mqttConnAck << 4, 2, 0, 0})
+		case mqttPingReq:
+			conn.Write([]byte{mqttPingResp << 4, 0})
+		case mqttPublish:
+			if (header>>1)&0x03 == 1 {
+				topicLen := int(binary.BigEndian.Uint16(body))
+				id := body[2+topicLen : 4+topicLen]
+				conn.Write([]byte{mqttPubAck << 4, 2, id[0], id[1]})
+			}
+		}
+	}
+}
+
+func decodePublish(t *testing.T, packet mqttPacket) (string, []byte) {
+	t.Helper()
+	if packet.Header>>4 != mqttPublish {
+		t.Fatalf("packet type = %d, want publish", packet.Header>>4)
+	}
+	topicLen := int(binary.BigEndian.Uint16(packet.Body))
+	topic := string(packet.Body[2 : 2+topicLen])
+	offset := 2 + topicLen
+	if (packet.Header>>1)&0x03 > 0 {
+		offset += 2
+	}
+	return topic, packet.Body[offset:]
+}
+
+func TestMQTTSinkPublishesSamples(t *testing.T) {
+	addr, packets := startFakeBroker(t)
+	u, err := url.Parse("mqtt://user:secret@" + addr + "/plant?qos=1&retain=true&topic={prefix}/values/{item_name}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := newMQTTSink(u)
+	if err != nil {
+		t.Fatalf("newMQTTSink returned error: %v", err)
+	}
+	sample := Sample{ItemName: "Area.Temp", Value: "21.5", Quality: "good", ServerState: "running", Received: time.Now()}
+	if err := s.Write(context.Background(), []Sample{sample}); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	connect := <-packets
+	if connect.Header>>4 != mqttConnect {
+		t.Fatalf("first packet type = %d, want connect", connect.Header>>4)
+	}
+	flags := connect.Body[7]
+	if flags&0x04 == 0 || flags&0x80 == 0 || flags&0x40 == 0 {
+		t.Fatalf("connect flags = %08b, want will, username and password", flags)
+	}
+
+	if !bytes.Contains(connect.Body, []byte(`{"online":false,"server_state":"running"}`)) {
+		t.Fatalf("connect payload %q lacks the will with the server state", connect.Body)
+	}
+
+	topic, payload := decodePublish(t, <-packets)
+	if topic != "plant/state" || string(payload) != `{"online":true,"server_state":"running"}` {
+		t.Fatalf("online state = %s %s", topic, payload)
+	}
+	valuePacket := <-packets
+	if valuePacket.Header&0x01 == 0 {
+		t.Fatal("value publish is not retained")
+	}
+	topic, payload = decodePublish(t, valuePacket)
+	if topic != "plant/values/Area.Temp" {
+		t.Fatalf("value topic = %q", topic)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(payload, &decoded); err != nil {
+		t.Fatalf("invalid payload %s: %v", payload, err)
+	}
+	if decoded["value"] != "21.5" || decoded["quality"] != "good" {
+		t.Fatalf("payload = %s", payload)
+	}
+	topic, payload = decodePublish(t, <-packets)
+	if topic != "plant/state" || string(payload) != `{"online":false,"server_state":"running"}` {
+		t.Fatalf("offline state = %s %s", topic, payload)
+	}
+	if disconnect := <-packets; disconnect.Header>>4 != mqttDisconnect {
+		t.Fatalf("last packet type = %d, want disconnect", disconnect.Header>>4)
+	}
+}
+
+func TestMQTTSinkKeepsIdleSessionAlive(t *testing.T) {
+	addr, packets := startFakeBroker(t)
+	u, err := url.Parse("mqtt://" + addr + "/plant?keepalive=100ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := newMQTTSink(u)
+	if err != nil {
+		t.Fatalf("newMQTTSink returned error: %v", err)
+	}
+	defer s.Close()
+	if err := s.Write(context.Background(), []Sample{{ItemName: "A", Value: "1", ServerState: "running"}}); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case packet := <-packets:
+			if packet.Header>>4 == mqttPingReq {
+				return
+			}
+		case <-deadline:
+			t.Fatal("no PINGREQ while the connection was idle")
+		}
+	}
+}
+
+func TestMQTTSinkPublishesStateChangesOnOpenConnection(t *testing.T) {
+	addr, packets := startFakeBroker(t)
+	u, err := url.Parse("mqtt://" + addr + "/plant?keepalive=0s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := newMQTTSink(u)
+	if err != nil {
+		t.Fatalf("newMQTTSink returned error: %v", err)
+	}
+	for _, state := range []string{"running", "running", "suspended"} {
+		if err := s.Write(context.Background(), []Sample{{ItemName: "A", Value: "1", ServerState: state}}); err != nil {
+			t.Fatalf("Write returned error: %v", err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var connects int
+	var states []string
+	for done := false; !done; {
+		select {
+		case packet := <-packets:
+			switch packet.Header >> 4 {
+			case mqttConnect:
+				connects++
+				if !bytes.Contains(packet.Body, []byte(`{"online":false,"server_state":"running"}`)) {
+					t.Fatalf("will missing the server state: %q", packet.Body)
+				}
+			case mqttPublish:
+				if topic, payload := decodePublish(t, packet); topic == "plant/state" {
+					if packet.Header&0x01 == 0 {
+						t.Fatalf("state message %s not retained", payload)
+					}
+					states = append(states, string(payload))
+				}
+			case mqttDisconnect:
+				done = true
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("broker saw %d connects and states %q before timing out", connects, states)
+		}
+	}
+	want := []string{`{"online":true,"server_state":"running"}`, `{"online":true,"server_state":"suspended"}`, `{"online":false,"server_state":"suspended"}`}
+	if connects != 1 || strings.Join(states, " ") != strings.Join(want, " ") {
+		t.Fatalf("connects = %d, states = %q; want one connect and %q", connects, states, want)
+	}
+}
+
+func TestParseMQTTURLRejectsInvalidSettings(t *testing.T) {
+	for _, raw := range []string{
+		"mqtt://:secret@broker/plant",
+		"mqtt://broker/plant?keepalive=20h",
+		"mqtt://broker/plant?keepalive=-1s",
+	} {
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := parseMQTTURL(u); err == nil {
+			t.Errorf("parseMQTTURL(%s) accepted invalid settings", raw)
+		}
+	}
+}
+
+func TestOpenRejectsUnknownScheme(t *testing.T) {
+	if _, err := Open("ftp://example.test/x"); err == nil {
+		t.Fatal("Open accepted unsupported scheme")
+	}
+	if _, err := Open("mqtt://broker/x?qos=2"); err == nil {
+		t.Fatal("Open accepted qos 2")
+	}
+}
+
+func TestRemainingLengthRoundTrip(t *testing.T) {
+	for _, length := range []int{0, 127, 128, 16383, 16384, 2097151} {
+		encoded := appendRemainingLength(nil, length)
+		got, err := readRemainingLength(bytes.NewReader(encoded))
+		if err != nil || got != length {
+			t.Fatalf("round trip %d = %d, %v", length, got, err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Package sink delivers watched item samples to external systems.
+package sink
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Sample is one item value observed by a watch loop. NumericValue is only
+// set when reading back a SQLite capture that stored the value as a number.
+type Sample struct {
+	ItemPath     string    `json:"item_path,omitempty"`
+	ItemName     string    `json:"item_name,omitempty"`
+	Value        string    `json:"value"`
+	NumericValue *float64  `json:"numeric_value,omitempty"`
+	Quality      string    `json:"quality,omitempty"`
+	ResultID     string    `json:"result_id,omitempty"`
+	Timestamp    time.Time `json:"timestamp,omitzero"`
+	Received     time.Time `json:"received"`
+	ServerState  string    `json:"server_state,omitempty"`
+}
+
+// Item returns the item name, falling back to the item path.
+func (s Sample) Item() string {
+	if s.ItemName != "" {
+		return s.ItemName
+	}
+	return s.ItemPath
+}
+
+// Status is one GetStatus observation made by status --watch. Error is set
+// instead of the server fields when the request failed.
+type Status struct {
+	ServerState    string    `json:"server_state,omitempty"`
+	StatusInfo     string    `json:"status_info,omitempty"`
+	VendorInfo     string    `json:"vendor_info,omitempty"`
+	ProductVersion string    `json:"product_version,omitempty"`
+	StartTime      time.Time `json:"start_time,omitzero"`
+	Received       time.Time `json:"received"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// Sink receives batches of samples.
+type Sink interface {
+	Write(ctx context.Context, samples []Sample) error
+	Close() error
+}
+
+// StatusWriter is implemented by sinks that also accept server status events.
+type StatusWriter interface {
+	WriteStatus(ctx context.Context, statuses []Status) error
+}
+
+// Open creates a sink from a URL. The scheme selects the implementation.
+func Open(rawURL string) (Sink, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("sink %q: %w", rawURL, err)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "mqtt", "mqtts":
+		return newMQTTSink(u)
+	case "influx", "influxs":
+		return newInfluxSink(u)
+	case "sqlite":
+		return newSQLiteSink(u)
+	case "webhook", "webhooks":
+		return newWebhookSink(u)
+	case "":
+		return nil, fmt.Errorf("sink %q: scheme is required", rawURL)
+	default:
+		return nil, fmt.Errorf("sink %q: unsupported scheme %q", rawURL, u.Scheme)
+	}
+}
+
+func queryBool(values url.Values, name string, fallback bool) (bool, error) {
+	raw := values.Get(name)
+	if raw == "" {
+		return fallback, nil
+	}
+	switch strings.ToLower(raw) {
+	case "1", "true", "yes", "on":
+		return true, nil
+	case "0", "false", "no", "off":
+		return false, nil
+	default:
+		return false, fmt.Errorf("%s: invalid boolean %q", name, raw)
+	}
+}
+
+func queryDuration(values url.Values, name string, fallback time.Duration) (time.Duration, error) {
+	raw := values.Get(name)
+	if raw == "" {
+		return fallback, nil
+	}
+	duration, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	return duration, nil
+}
@@ -0,0 +1,221 @@
+package sink
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS samples (
+	id            INTEGER PRIMARY KEY AUTOINCREMENT,
+	item_path     TEXT    NOT NULL DEFAULT '',
+	item_name     TEXT    NOT NULL DEFAULT '',
+	value         TEXT    NOT NULL DEFAULT '',
+	numeric_value REAL,
+	quality       TEXT    NOT NULL DEFAULT '',
+	result_id     TEXT    NOT NULL DEFAULT '',
+	server_state  TEXT    NOT NULL DEFAULT '',
+	server_time   INTEGER,
+	received_time INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS samples_received_time ON samples (received_time);
+CREATE INDEX IF NOT EXISTS samples_item_received_time ON samples (item_name, item_path, received_time);
+`
+
+// sqliteSink stores samples in a SQLite database. Times are stored as Unix
+// nanoseconds so time windows can use the indexes. Each batch is committed in
+// its own transaction and the database runs in WAL mode, so a crash loses at
+// most the batch in flight.
+type sqliteSink struct {
+	db *sql.DB
+}
+
+func newSQLiteSink(u *url.URL) (*sqliteSink, error) {
+	path := sqlitePath(u)
+	if path == "" {
+		return nil, errors.New("sqlite sink: database path is required, such as sqlite:capture.db")
+	}
+	db, err := OpenSQLite(path)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteSink{db: db}, nil
+}
+
+func sqlitePath(u *url.URL) string {
+	if u.Opaque != "" {
+		return u.Opaque
+	}
+	return u.Host + u.Path
+}
+
+// OpenSQLite opens, and if needed creates, a sample capture database.
+func OpenSQLite(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite sink %q: %w", path, err)
+	}
+	db.SetMaxOpenConns(1)
+	for _, pragma := range []string{"PRAGMA journal_mode=WAL", "PRAGMA synchronous=NORMAL", "PRAGMA busy_timeout=5000"} {
+		if _, err := db.Exec(pragma); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("sqlite sink %q: %w", path, err)
+		}
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("sqlite sink %q: create schema: %w", path, err)
+	}
+	return db, nil
+}
+
+// OpenSQLiteReadOnly opens an existing capture database for reading. It
+// neither creates the file nor changes its schema or journal mode.
+func OpenSQLiteReadOnly(path string) (*sql.DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	dsn := (&url.URL{Scheme: "file", Opaque: url.PathEscape(path), RawQuery: "mode=ro"}).String()
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite %q: %w", path, err)
+	}
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec("PRAGMA busy_timeout=5000"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("sqlite %q: %w", path, err)
+	}
+	return db, nil
+}
+
+func (s *sqliteSink) Write(ctx context.Context, samples []Sample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("sqlite sink: %w", err)
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO samples
+		(item_path, item_name, value, numeric_value, quality, result_id, server_state, server_time, received_time)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("sqlite sink: %w", err)
+	}
+	defer stmt.Close()
+	for _, sample := range samples {
+		var numeric sql.NullFloat64
+		numeric.Float64, numeric.Valid = ParseNumber(sample.Value)
+		var serverTime sql.NullInt64
+		if !sample.Timestamp.IsZero() {
+			serverTime = sql.NullInt64{Int64: sample.Timestamp.UnixNano(), Valid: true}
+		}
+		received := sample.Received
+		if received.IsZero() {
+			received = time.Now()
+		}
+		if _, err := stmt.ExecContext(ctx,
+			sample.ItemPath, sample.ItemName, sample.Value, numeric, sample.Quality,
+			sample.ResultID, sample.ServerState, serverTime, received.UnixNano(),
+		); err != nil {
+			return fmt.Errorf("sqlite sink: insert: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("sqlite sink: commit: %w", err)
+	}
+	return nil
+}
+
+func (s *sqliteSink) Close() error {
+	return s.db.Close()
+}
+
+// Query selects captured samples. Zero times leave that end of the window
+// open; From is inclusive and To is exclusive. Empty Items selects all items.
+type Query struct {
+	From  time.Time
+	To    time.Time
+	Items []QueryItem
+}
+
+// QueryItem identifies one item to export.
+type QueryItem struct {
+	ItemPath string
+	ItemName string
+}
+
+// QuerySQLite streams samples matching q, ordered by receive time, to fn.
+func QuerySQLite(ctx context.Context, db *sql.DB, q Query, fn func(Sample) error) error {
+	var where []string
+	var args []interface{}
+	if !q.From.IsZero() {
+		where = append(where, "received_time >= ?")
+		args = append(args, q.From.UnixNano())
+	}
+	if !q.To.IsZero() {
+		where = append(where, "received_time < ?")
+		args = append(args, q.To.UnixNano())
+	}
+	if len(q.Items) > 0 {
+		var items []string
+		for _, item := range q.Items {
+			switch {
+			case item.ItemName != "" && item.ItemPath != "":
+				items = append(items, "(item_name = ? AND item_path = ?)")
+				args = append(args, item.ItemName, item.ItemPath)
+			case item.ItemName != "":
+				items = append(items, "item_name = ?")
+				args = append(args, item.ItemName)
+			default:
+				items = append(items, "item_path = ?")
+				args = append(args, item.ItemPath)
+			}
+		}
+		where = append(where, "("+strings.Join(items, " OR ")+")")
+	}
+	query := `SELECT item_path, item_name, value, numeric_value, quality, result_id, server_state, server_time, received_time FROM samples`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY received_time, id"
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("query samples: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var sample Sample
+		var numeric sql.NullFloat64
+		var serverTime sql.NullInt64
+		var received int64
+		if err := rows.Scan(&sample.ItemPath, &sample.ItemName, &sample.Value, &numeric, &sample.Quality,
+			&sample.ResultID, &sample.ServerState, &serverTime, &received); err != nil {
+			return fmt.Errorf("query samples: %w", err)
+		}
+		if numeric.Valid {
+			sample.NumericValue = &numeric.Float64
+		}
+		if serverTime.Valid {
+			sample.Timestamp = time.Unix(0, serverTime.Int64).UTC()
+		}
+		sample.Received = time.Unix(0, received).UTC()
+		if err := fn(sample); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("query samples: %w", err)
+	}
+	return nil
+}
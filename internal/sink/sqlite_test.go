@@ -0,0 +1,118 @@
+package sink
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSQLiteSinkRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.db")
+	s, err := Open("sqlite:" + path)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	base := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	samples := []Sample{
+		{ItemName: "Temp", Value: "21.5", Quality: "good", Timestamp: base, Received: base.Add(time.Second), ServerState: "running"},
+		{ItemName: "Mode", Value: "auto", Received: base.Add(2 * time.Second)},
+		{ItemName: "Temp", Value: "22", Quality: "good", Received: base.Add(time.Hour)},
+	}
+	if err := s.Write(context.Background(), samples); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	db, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var numeric sql.NullFloat64
+	if err := db.QueryRow(`SELECT numeric_value FROM samples WHERE item_name = 'Mode'`).Scan(&numeric); err != nil || numeric.Valid {
+		t.Fatalf("numeric_value for text sample = %v, %v; want NULL", numeric, err)
+	}
+
+	var got []Sample
+	q := Query{From: base, To: base.Add(time.Minute), Items: []QueryItem{{ItemName: "Temp"}}}
+	if err := QuerySQLite(context.Background(), db, q, func(sample Sample) error {
+		got = append(got, sample)
+		return nil
+	}); err != nil {
+		t.Fatalf("QuerySQLite returned error: %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("got %d samples, want 1: %+v", len(got), got)
+	}
+	if got[0].Value != "21.5" || !got[0].Timestamp.Equal(base) || !got[0].Received.Equal(base.Add(time.Second)) || got[0].ServerState != "running" {
+		t.Fatalf("sample = %+v", got[0])
+	}
+}
+
+func TestOpenSQLiteRequiresPath(t *testing.T) {
+	if _, err := Open("sqlite:"); err == nil {
+		t.Fatal("Open accepted sqlite sink without a path")
+	}
+}
+
+func TestOpenSQLiteReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing.db")
+	if _, err := OpenSQLiteReadOnly(missing); err == nil {
+		t.Fatal("OpenSQLiteReadOnly accepted a missing database")
+	}
+	if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Fatalf("OpenSQLiteReadOnly created %s: %v", missing, err)
+	}
+
+	plain := filepath.Join(dir, "plain #1%.db")
+	db, err := sql.Open("sqlite", plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TABLE other (id INTEGER)`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	db, err = OpenSQLiteReadOnly(plain)
+	if err != nil {
+		t.Fatalf("OpenSQLiteReadOnly returned error: %v", err)
+	}
+	defer db.Close()
+	var mode string
+	if err := db.QueryRow(`PRAGMA journal_mode`).Scan(&mode); err != nil || mode != "delete" {
+		t.Fatalf("journal_mode = %q, %v; want delete", mode, err)
+	}
+	if err := QuerySQLite(context.Background(), db, Query{}, func(Sample) error { return nil }); err == nil {
+		t.Fatal("QuerySQLite succeeded on a database without samples")
+	}
+	if _, err := db.Exec(`INSERT INTO other VALUES (1)`); err == nil {
+		t.Fatal("read-only database accepted a write")
+	}
+}
+
+func TestOpenSQLiteReadOnlyWhileCapturing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.db")
+	s, err := Open("sqlite:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.Write(context.Background(), []Sample{{ItemName: "Temp", Value: "21", Received: time.Now()}}); err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenSQLiteReadOnly(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	count := 0
+	if err := QuerySQLite(context.Background(), db, Query{}, func(Sample) error { count++; return nil }); err != nil || count != 1 {
+		t.Fatalf("QuerySQLite = %d samples, %v; want 1", count, err)
+	}
+}
@@ -0,0 +1,394 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type webhookEvent struct {
+	Type   string  `json:"type"`
+	Sample *Sample `json:"sample,omitempty"`
+	Status *Status `json:"status,omitempty"`
+}
+
+type webhookBatch struct {
+	Batch  string         `json:"batch"`
+	Source string         `json:"source"`
+	Events []webhookEvent `json:"events"`
+}
+
+// webhookSink posts batches of JSON events to an HTTP endpoint. Every batch is
+// written to a spool directory first and removed once the receiver accepts it,
+// so events survive receiver outages and CLI restarts and are delivered in
+// order. Delivery is at least once; receivers can deduplicate on the batch ID.
+type webhookSink struct {
+	endpoint      string
+	token         string
+	username      string
+	password      string
+	batchSize     int
+	flushInterval time.Duration
+	backoff       time.Duration
+	maxBackoff    time.Duration
+	drainTimeout  time.Duration
+	client        *http.Client
+	spool         *webhookSpool
+
+	pending    []webhookEvent
+	firstQueue time.Time
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wake    chan struct{}
+	closing chan struct{}
+	done    chan struct{}
+}
+
+func newWebhookSink(u *url.URL) (*webhookSink, error) {
+	query := u.Query()
+	s := &webhookSink{
+		batchSize: 100,
+		token:     query.Get("token"),
+	}
+	var err error
+	if v := query.Get("batch_size"); v != "" {
+		if s.batchSize, err = strconv.Atoi(v); err != nil || s.batchSize < 1 {
+			return nil, fmt.Errorf("webhook sink: batch_size must be a positive integer")
+		}
+	}
+	maxBuffered := 10000
+	if v := query.Get("max_buffered"); v != "" {
+		if maxBuffered, err = strconv.Atoi(v); err != nil || maxBuffered < 1 {
+			return nil, fmt.Errorf("webhook sink: max_buffered must be a positive integer")
+		}
+	}
+	if s.flushInterval, err = queryDuration(query, "flush_interval", 5*time.Second); err != nil {
+		return nil, fmt.Errorf("webhook sink: %w", err)
+	}
+	if s.backoff, err = queryDuration(query, "backoff", time.Second); err != nil {
+		return nil, fmt.Errorf("webhook sink: %w", err)
+	}
+	if s.maxBackoff, err = queryDuration(query, "max_backoff", 5*time.Minute); err != nil {
+		return nil, fmt.Errorf("webhook sink: %w", err)
+	}
+	if s.drainTimeout, err = queryDuration(query, "drain_timeout", 10*time.Second); err != nil {
+		return nil, fmt.Errorf("webhook sink: %w", err)
+	}
+	timeout, err := queryDuration(query, "timeout", 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("webhook sink: %w", err)
+	}
+	if s.backoff <= 0 {
+		return nil, errors.New("webhook sink: backoff must be greater than zero")
+	}
+	s.client = &http.Client{Timeout: timeout}
+	if u.User != nil {
+		s.username = u.User.Username()
+		s.password, _ = u.User.Password()
+	}
+	if u.Host == "" {
+		return nil, errors.New("webhook sink: host is required")
+	}
+	bufferDir := query.Get("buffer")
+
+	target := *u
+	target.User = nil
+	target.Scheme = "http"
+	if u.Scheme == "webhooks" {
+		target.Scheme = "https"
+	}
+	for _, name := range []string{"token", "batch_size", "flush_interval", "backoff", "max_backoff", "drain_timeout", "timeout", "buffer", "max_buffered"} {
+		query.Del(name)
+	}
+	target.RawQuery = query.Encode()
+	s.endpoint = target.String()
+
+	if bufferDir == "" {
+		bufferDir = defaultWebhookBuffer(s.endpoint)
+	}
+	if s.spool, err = openWebhookSpool(bufferDir, maxBuffered); err != nil {
+		return nil, fmt.Errorf("webhook sink: %w", err)
+	}
+	if n, _ := s.spool.len(); n > 0 {
+		slog.Info("webhook replaying buffered batches", "endpoint", s.endpoint, "batches", n, "buffer", bufferDir)
+	}
+
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.wake = make(chan struct{}, 1)
+	s.closing = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.run()
+	return s, nil
+}
+
+// defaultWebhookBuffer keeps one spool directory per endpoint in the user
+// cache directory so buffered events are replayed by the next run.
+func defaultWebhookBuffer(endpoint string) string {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		base = os.TempDir()
+	}
+	sum := sha256.Sum256([]byte(endpoint))
+	return filepath.Join(base, "opc-xml-da-cli", "webhook", hex.EncodeToString(sum[:8]))
+}
+
+func (s *webhookSink) Write(_ context.Context, samples []Sample) error {
+	events := make([]webhookEvent, 0, len(samples))
+	for i := range samples {
+		sample := samples[i]
+		events = append(events, webhookEvent{Type: "sample", Sample: &sample})
+	}
+	return s.queue(events)
+}
+
+func (s *webhookSink) WriteStatus(_ context.Context, statuses []Status) error {
+	events := make([]webhookEvent, 0, len(statuses))
+	for i := range statuses {
+		status := statuses[i]
+		events = append(events, webhookEvent{Type: "status", Status: &status})
+	}
+	return s.queue(events)
+}
+
+func (s *webhookSink) queue(events []webhookEvent) error {
+	if len(s.pending) == 0 {
+		s.firstQueue = time.Now()
+	}
+	s.pending = append(s.pending, events...)
+	if len(s.pending) >= s.batchSize || time.Since(s.firstQueue) >= s.flushInterval {
+		return s.flush()
+	}
+	return nil
+}
+
+// flush moves pending events into the spool in batch_size chunks.
+func (s *webhookSink) flush() error {
+	for len(s.pending) > 0 {
+		n := min(len(s.pending), s.batchSize)
+		if err := s.spool.push(s.pending[:n]); err != nil {
+			return fmt.Errorf("webhook sink: %w", err)
+		}
+		s.pending = s.pending[n:]
+	}
+	s.pending = nil
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Close spools pending events and waits up to drain_timeout for the buffer to
+// be delivered. Anything left is replayed on the next run.
+func (s *webhookSink) Close() error {
+	err := s.flush()
+	close(s.closing)
+	timer := time.NewTimer(s.drainTimeout)
+	defer timer.Stop()
+	select {
+	case <-s.done:
+	case <-timer.C:
+		s.cancel()
+		<-s.done
+	}
+	s.cancel()
+	if n, _ := s.spool.len(); n > 0 {
+		slog.Warn("webhook batches left in buffer", "endpoint", s.endpoint, "batches", n, "buffer", s.spool.dir)
+	}
+	return err
+}
+
+// run delivers spooled batches oldest first. A failed batch blocks the ones
+// behind it and is retried with exponential backoff, which keeps the receiver
+// seeing events in order.
+func (s *webhookSink) run() {
+	defer close(s.done)
+	delay := s.backoff
+	for {
+		names, err := s.spool.list()
+		if err != nil {
+			slog.Warn("webhook buffer list failed", "buffer", s.spool.dir, "err", err)
+		}
+		if len(names) == 0 {
+			select {
+			case <-s.wake:
+				continue
+			case <-s.closing:
+				return
+			case <-s.ctx.Done():
+				return
+			}
+		}
+		if err := s.deliver(names[0]); err != nil {
+			slog.Warn("webhook delivery failed; retrying", "endpoint", s.endpoint, "buffered", len(names), "delay", delay, "err", err)
+			select {
+			case <-time.After(delay):
+			case <-s.ctx.Done():
+				return
+			}
+			delay = min(delay*2, max(s.maxBackoff, s.backoff))
+			continue
+		}
+		delay = s.backoff
+	}
+}
+
+// deliver posts one spooled batch. Batches the receiver rejects outright are
+// moved aside so they do not block the queue forever.
+func (s *webhookSink) deliver(name string) error {
+	body, err := s.spool.read(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	retry, err := s.post(body)
+	if err == nil {
+		return s.spool.remove(name)
+	}
+	if !retry {
+		slog.Warn("webhook receiver rejected batch; moved to rejected", "batch", name, "err", err)
+		return s.spool.reject(name)
+	}
+	return err
+}
+
+func (s *webhookSink) post(body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	} else if s.username != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return false, nil
+	}
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout
+	return retry, err
+}
+
+// webhookSpool stores one JSON file per batch. File names are zero-padded
+// sequence numbers, so directory order is delivery order.
+type webhookSpool struct {
+	dir string
+	max int
+
+	mu   sync.Mutex
+	next uint64
+}
+
+func openWebhookSpool(dir string, maxBatches int) (*webhookSpool, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create buffer %q: %w", dir, err)
+	}
+	q := &webhookSpool{dir: dir, max: maxBatches}
+	names, err := q.list()
+	if err != nil {
+		return nil, err
+	}
+	// Start from the clock so batch IDs keep increasing after the buffer has
+	// been drained and the CLI restarts.
+	q.next = uint64(time.Now().UnixNano())
+	if len(names) > 0 {
+		last, _ := strconv.ParseUint(strings.TrimSuffix(names[len(names)-1], ".json"), 10, 64)
+		q.next = max(q.next, last+1)
+	}
+	return q, nil
+}
+
+func (q *webhookSpool) push(events []webhookEvent) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	id := fmt.Sprintf("%020d", q.next)
+	q.next++
+	body, err := json.Marshal(webhookBatch{Batch: id, Source: "opc-xml-da-cli", Events: events})
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(q.dir, id+".tmp")
+	if err := os.WriteFile(tmp, body, 0o600); err != nil {
+		return fmt.Errorf("write buffer: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(q.dir, id+".json")); err != nil {
+		return fmt.Errorf("write buffer: %w", err)
+	}
+	names, err := q.list()
+	if err != nil {
+		return err
+	}
+	if drop := len(names) - q.max; drop > 0 {
+		slog.Warn("webhook buffer full; dropping oldest batches", "buffer", q.dir, "dropped", drop)
+		for _, name := range names[:drop] {
+			_ = os.Remove(filepath.Join(q.dir, name))
+		}
+	}
+	return nil
+}
+
+func (q *webhookSpool) list() ([]string, error) {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read buffer: %w", err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.HasSuffix(entry.Name(), ".json") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (q *webhookSpool) len() (int, error) {
+	names, err := q.list()
+	return len(names), err
+}
+
+func (q *webhookSpool) read(name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(q.dir, name))
+}
+
+func (q *webhookSpool) remove(name string) error {
+	if err := os.Remove(filepath.Join(q.dir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (q *webhookSpool) reject(name string) error {
+	rejected := filepath.Join(q.dir, "rejected")
+	if err := os.MkdirAll(rejected, 0o700); err != nil {
+		return err
+	}
+	return os.Rename(filepath.Join(q.dir, name), filepath.Join(rejected, name))
+}
@@ -0,0 +1,177 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type webhookReceiver struct {
+	mu      sync.Mutex
+	down    bool
+	status  int
+	batches []webhookBatch
+	auth    string
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.down {
+		http.Error(w, "deploying", http.StatusServiceUnavailable)
+		return
+	}
+	if r.status != 0 {
+		http.Error(w, "bad payload", r.status)
+		return
+	}
+	body, _ := io.ReadAll(req.Body)
+	var batch webhookBatch
+	if err := json.Unmarshal(body, &batch); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.batches = append(r.batches, batch)
+	r.auth = req.Header.Get("Authorization")
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (r *webhookReceiver) setDown(down bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.down = down
+}
+
+func (r *webhookReceiver) received() []webhookBatch {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]webhookBatch(nil), r.batches...)
+}
+
+func webhookURL(t *testing.T, server *httptest.Server, buffer, params string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(strings.Replace(server.URL, "http://", "webhook://", 1) + "/hook?buffer=" + url.QueryEscape(buffer) + "&" + params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestWebhookSinkBuffersAndReplaysInOrder(t *testing.T) {
+	receiver := &webhookReceiver{down: true}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	buffer := t.TempDir()
+
+	s, err := newWebhookSink(webhookURL(t, server, buffer, "batch_size=1&backoff=5ms&max_backoff=20ms&token=secret"))
+	if err != nil {
+		t.Fatalf("newWebhookSink returned error: %v", err)
+	}
+	ctx := context.Background()
+	for _, value := range []string{"1", "2", "3"} {
+		if err := s.Write(ctx, []Sample{{ItemName: "Tag", Value: value}}); err != nil {
+			t.Fatalf("Write returned error: %v", err)
+		}
+	}
+	if err := s.WriteStatus(ctx, []Status{{ServerState: "running"}}); err != nil {
+		t.Fatalf("WriteStatus returned error: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if got := receiver.received(); len(got) != 0 {
+		t.Fatalf("receiver got %d batches while down", len(got))
+	}
+	if n, _ := s.spool.len(); n != 4 {
+		t.Fatalf("buffered %d batches, want 4", n)
+	}
+
+	receiver.setDown(false)
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	got := receiver.received()
+	if len(got) != 4 {
+		t.Fatalf("receiver got %d batches, want 4", len(got))
+	}
+	for i, value := range []string{"1", "2", "3"} {
+		if got[i].Events[0].Type != "sample" || got[i].Events[0].Sample.Value != value {
+			t.Fatalf("batch %d = %+v, want sample %s", i, got[i].Events[0], value)
+		}
+	}
+	if got[3].Events[0].Type != "status" || got[3].Events[0].Status.ServerState != "running" {
+		t.Fatalf("last batch = %+v, want status event", got[3].Events[0])
+	}
+	if got[0].Batch >= got[1].Batch {
+		t.Fatalf("batch IDs are not increasing: %s, %s", got[0].Batch, got[1].Batch)
+	}
+	if receiver.auth != "Bearer secret" {
+		t.Fatalf("Authorization = %q", receiver.auth)
+	}
+	if n, _ := s.spool.len(); n != 0 {
+		t.Fatalf("%d batches left in buffer", n)
+	}
+}
+
+func TestWebhookSinkReplaysBufferFromPreviousRun(t *testing.T) {
+	receiver := &webhookReceiver{down: true}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	buffer := t.TempDir()
+
+	first, err := newWebhookSink(webhookURL(t, server, buffer, "backoff=1h&drain_timeout=10ms"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Write(context.Background(), []Sample{{ItemName: "Tag", Value: "kept"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := receiver.received(); len(got) != 0 {
+		t.Fatalf("receiver got %d batches while down", len(got))
+	}
+
+	receiver.setDown(false)
+	second, err := newWebhookSink(webhookURL(t, server, buffer, "backoff=5ms"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got := receiver.received()
+	if len(got) != 1 || got[0].Events[0].Sample.Value != "kept" {
+		t.Fatalf("replayed batches = %+v", got)
+	}
+}
+
+func TestWebhookSinkMovesRejectedBatches(t *testing.T) {
+	receiver := &webhookReceiver{status: http.StatusUnprocessableEntity}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	buffer := t.TempDir()
+
+	s, err := newWebhookSink(webhookURL(t, server, buffer, "backoff=5ms"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write(context.Background(), []Sample{{ItemName: "Tag", Value: "1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	rejected, err := os.ReadDir(filepath.Join(buffer, "rejected"))
+	if err != nil || len(rejected) != 1 {
+		t.Fatalf("rejected batches = %v, %v; want 1", rejected, err)
+	}
+}
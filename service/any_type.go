@@ -0,0 +1,97 @@
+package service
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	xsiNamespace = "http://www.w3.org/2001/XMLSchema-instance"
+	xsdNamespace = "http://www.w3.org/2001/XMLSchema"
+	opcNamespace = "http://opcfoundation.org/webservices/XMLDA/1.0/"
+)
+
+// AnyType holds an xsd:anyType value. InnerXML is the raw element content and
+// Type is the local name of its xsi:type attribute, such as "double" or
+// "ArrayOfInt", when one is present.
+type AnyType struct {
+	InnerXML string `xml:",innerxml"`
+	Type     string `xml:"-" json:"Type,omitempty"`
+}
+
+// UnmarshalXML captures the element content and its xsi:type.
+func (v *AnyType) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	v.Type = ""
+	for _, attr := range start.Attr {
+		if attr.Name.Space == xsiNamespace && attr.Name.Local == "type" {
+			v.Type = attr.Value
+			if i := strings.LastIndex(v.Type, ":"); i >= 0 {
+				v.Type = v.Type[i+1:]
+			}
+		}
+	}
+	var content struct {
+		InnerXML string `xml:",innerxml"`
+	}
+	if err := d.DecodeElement(&content, &start); err != nil {
+		return err
+	}
+	v.InnerXML = content.InnerXML
+	return nil
+}
+
+// MarshalXML writes the element content verbatim and adds xsi:type when Type
+// is set. ArrayOf types are qualified with the OPC namespace and everything
+// else with the XML Schema namespace.
+func (v AnyType) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if v.Type != "" {
+		typePrefix, typeNamespace := "xsd", xsdNamespace
+		if strings.HasPrefix(v.Type, "ArrayOf") {
+			typePrefix, typeNamespace = "opc", opcNamespace
+		}
+		start.Attr = append(start.Attr,
+			xml.Attr{Name: xml.Name{Local: "xmlns:xsi"}, Value: xsiNamespace},
+			xml.Attr{Name: xml.Name{Local: "xmlns:" + typePrefix}, Value: typeNamespace},
+			xml.Attr{Name: xml.Name{Local: "xsi:type"}, Value: typePrefix + ":" + v.Type},
+		)
+	}
+	content := struct {
+		InnerXML string `xml:",innerxml"`
+	}{v.InnerXML}
+	return e.EncodeElement(content, start)
+}
+
+// xsdBitSizes maps the sized XML Schema numeric types to their bit size.
+var xsdBitSizes = map[string]int{
+	"byte": 8, "short": 16, "int": 32, "long": 64,
+	"unsignedByte": 8, "unsignedShort": 16, "unsignedInt": 32, "unsignedLong": 64,
+	"float": 32, "double": 64, "decimal": 64,
+}
+
+// CheckValue reports whether text is a valid lexical value for the XML
+// Schema type xsdType. Integer and float values must fit the type's bit size
+// and booleans must be true, false, 1 or 0. Other types are not checked.
+func CheckValue(xsdType, text string) error {
+	var err error
+	switch xsdType {
+	case "boolean":
+		if text != "true" && text != "false" && text != "1" && text != "0" {
+			err = strconv.ErrSyntax
+		}
+	case "byte", "short", "int", "long":
+		_, err = strconv.ParseInt(text, 10, xsdBitSizes[xsdType])
+	case "unsignedByte", "unsignedShort", "unsignedInt", "unsignedLong":
+		_, err = strconv.ParseUint(text, 10, xsdBitSizes[xsdType])
+	case "float", "double", "decimal":
+		_, err = strconv.ParseFloat(text, xsdBitSizes[xsdType])
+	case "dateTime":
+		_, err = time.Parse(time.RFC3339Nano, text)
+	}
+	if err != nil {
+		return fmt.Errorf("value %q is not a valid %s", text, xsdType)
+	}
+	return nil
+}
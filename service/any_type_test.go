@@ -0,0 +1,69 @@
+package service
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestAnyTypeRoundTripsXSIType(t *testing.T) {
+	item := ItemValue{ItemName: "A", Value: AnyType{InnerXML: "42", Type: "int"}}
+	data, err := xml.Marshal(&item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `xsi:type="xsd:int"`) {
+		t.Fatalf("marshalled value missing xsi:type: %s", data)
+	}
+	var decoded ItemValue
+	if err := xml.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Value != item.Value {
+		t.Fatalf("decoded value = %+v, want %+v", decoded.Value, item.Value)
+	}
+}
+
+func TestAnyTypeWithoutTypeHasNoAttributes(t *testing.T) {
+	data, err := xml.Marshal(&ItemValue{Value: AnyType{InnerXML: "<a>1</a>"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "<Value><a>1</a></Value>") {
+		t.Fatalf("marshalled value = %s", data)
+	}
+}
+
+func TestCheckValue(t *testing.T) {
+	for _, tc := range []struct {
+		xsdType, text string
+		valid         bool
+	}{
+		{"boolean", "true", true},
+		{"boolean", "0", true},
+		{"boolean", "TRUE", false},
+		{"boolean", "t", false},
+		{"byte", "-128", true},
+		{"byte", "128", false},
+		{"short", "32767", true},
+		{"short", "40000", false},
+		{"int", "-2147483648", true},
+		{"int", "3000000000", false},
+		{"long", "3000000000", true},
+		{"unsignedByte", "255", true},
+		{"unsignedByte", "256", false},
+		{"unsignedShort", "-1", false},
+		{"unsignedInt", "4294967296", false},
+		{"float", "3.4e38", true},
+		{"float", "1e39", false},
+		{"double", "1e39", true},
+		{"dateTime", "2024-03-01T12:00:00Z", true},
+		{"dateTime", "yesterday", false},
+		{"string", "anything", true},
+	} {
+		err := CheckValue(tc.xsdType, tc.text)
+		if (err == nil) != tc.valid {
+			t.Errorf("CheckValue(%q, %q) = %v, want valid %v", tc.xsdType, tc.text, err, tc.valid)
+		}
+	}
+}
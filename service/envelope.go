@@ -0,0 +1,222 @@
+package service
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/hooklift/gowsdl/soap"
+)
+
+// Operations lists the OPC XML-DA operation names in WSDL order.
+var Operations = []string{
+	"GetStatus",
+	"Read",
+	"Write",
+	"Subscribe",
+	"SubscriptionPolledRefresh",
+	"SubscriptionCancel",
+	"Browse",
+	"GetProperties",
+}
+
+// NewRequest returns an empty request struct for operation, such as *Read
+// for "Read".
+func NewRequest(operation string) (interface{}, error) {
+	switch operation {
+	case "GetStatus":
+		return new(GetStatus), nil
+	case "Browse":
+		return new(Browse), nil
+	case "GetProperties":
+		return new(GetProperties), nil
+	case "Read":
+		return new(Read), nil
+	case "Write":
+		return new(Write), nil
+	case "Subscribe":
+		return new(Subscribe), nil
+	case "SubscriptionPolledRefresh":
+		return new(SubscriptionPolledRefresh), nil
+	case "SubscriptionCancel":
+		return new(SubscriptionCancel), nil
+	default:
+		return nil, fmt.Errorf("unsupported operation %q", operation)
+	}
+}
+
+// NewResponse returns an empty response struct for operation, such as
+// *ReadResponse for "Read".
+func NewResponse(operation string) (interface{}, error) {
+	switch operation {
+	case "GetStatus":
+		return new(GetStatusResponse), nil
+	case "Browse":
+		return new(BrowseResponse), nil
+	case "GetProperties":
+		return new(GetPropertiesResponse), nil
+	case "Read":
+		return new(ReadResponse), nil
+	case "Write":
+		return new(WriteResponse), nil
+	case "Subscribe":
+		return new(SubscribeResponse), nil
+	case "SubscriptionPolledRefresh":
+		return new(SubscriptionPolledRefreshResponse), nil
+	case "SubscriptionCancel":
+		return new(SubscriptionCancelResponse), nil
+	default:
+		return nil, fmt.Errorf("unsupported operation %q", operation)
+	}
+}
+
+// DecodeRequest decodes an OPC XML-DA request from either a SOAP envelope
+// or a bare operation element, returning the operation name and a pointer
+// to the matching request struct.
+func DecodeRequest(data []byte) (string, interface{}, error) {
+	decoder, start, err := operationElement(data)
+	if err != nil {
+		return "", nil, err
+	}
+	req, err := NewRequest(start.Name.Local)
+	if err != nil {
+		return start.Name.Local, nil, err
+	}
+	if err := decoder.DecodeElement(req, &start); err != nil {
+		return start.Name.Local, nil, fmt.Errorf("decode %s: %w", start.Name.Local, err)
+	}
+	return start.Name.Local, req, nil
+}
+
+// DecodeResponse decodes an OPC XML-DA response envelope, returning the
+// operation name and a pointer to the matching response struct. A SOAP
+// fault is returned as a *soap.SOAPFault error.
+func DecodeResponse(data []byte) (string, interface{}, error) {
+	decoder, start, err := operationElement(data)
+	if err != nil {
+		return "", nil, err
+	}
+	if start.Name.Local == "Fault" {
+		return "", nil, decodeFault(decoder, start)
+	}
+	operation, ok := strings.CutSuffix(start.Name.Local, "Response")
+	if !ok {
+		return "", nil, fmt.Errorf("unexpected response element %q", start.Name.Local)
+	}
+	resp, err := NewResponse(operation)
+	if err != nil {
+		return operation, nil, err
+	}
+	if err := decoder.DecodeElement(resp, &start); err != nil {
+		return operation, nil, fmt.Errorf("decode %s: %w", start.Name.Local, err)
+	}
+	return operation, resp, nil
+}
+
+// SOAP12Namespace is the SOAP 1.2 envelope namespace. SOAP 1.1 uses
+// soap.XmlNsSoapEnv.
+const SOAP12Namespace = "http://www.w3.org/2003/05/soap-envelope"
+
+// RequestOperation returns the operation named by a request, either a SOAP
+// 1.1 or 1.2 envelope or a bare operation element, and the namespace of its
+// envelope, empty for a bare element.
+func RequestOperation(data []byte) (operation, envelope string, err error) {
+	_, start, err := operationElement(data)
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := NewRequest(start.Name.Local); err != nil {
+		return "", "", err
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", "", err
+		}
+		if root, ok := token.(xml.StartElement); ok {
+			if isEnvelopeNamespace(root.Name.Space) {
+				envelope = root.Name.Space
+			}
+			return start.Name.Local, envelope, nil
+		}
+	}
+}
+
+// WrapRequest places a bare operation element, byte for byte, in the body
+// of a SOAP 1.1 envelope, with headers marshalled into the SOAP header.
+func WrapRequest(element []byte, headers ...interface{}) ([]byte, error) {
+	element = bytes.TrimSpace(element)
+	if bytes.HasPrefix(element, []byte("<?xml")) {
+		if end := bytes.Index(element, []byte("?>")); end >= 0 {
+			element = bytes.TrimSpace(element[end+2:])
+		}
+	}
+	var buf bytes.Buffer
+	buf.WriteString(`<soap:Envelope xmlns:soap="` + soap.XmlNsSoapEnv + `">`)
+	if len(headers) > 0 {
+		buf.WriteString("<soap:Header>")
+		for _, header := range headers {
+			data, err := xml.Marshal(header)
+			if err != nil {
+				return nil, fmt.Errorf("marshal SOAP header: %w", err)
+			}
+			buf.Write(data)
+		}
+		buf.WriteString("</soap:Header>")
+	}
+	buf.WriteString("<soap:Body>")
+	buf.Write(element)
+	buf.WriteString("</soap:Body></soap:Envelope>")
+	return buf.Bytes(), nil
+}
+
+// decodeFault decodes a SOAP 1.1 or 1.2 Fault element into a
+// *soap.SOAPFault, which it returns as the error.
+func decodeFault(decoder *xml.Decoder, start xml.StartElement) error {
+	if start.Name.Space == SOAP12Namespace {
+		var fault struct {
+			Code   string   `xml:"Code>Value"`
+			Reason []string `xml:"Reason>Text"`
+		}
+		if err := decoder.DecodeElement(&fault, &start); err != nil {
+			return fmt.Errorf("decode Fault: %w", err)
+		}
+		return &soap.SOAPFault{Code: fault.Code, String: strings.Join(fault.Reason, "; ")}
+	}
+	fault := new(soap.SOAPFault)
+	if err := decoder.DecodeElement(fault, &start); err != nil {
+		return fmt.Errorf("decode Fault: %w", err)
+	}
+	return fault
+}
+
+func isEnvelopeNamespace(space string) bool {
+	return space == soap.XmlNsSoapEnv || space == SOAP12Namespace
+}
+
+// operationElement positions a decoder on the first element inside the SOAP
+// body of a SOAP 1.1 or 1.2 envelope, or on the document element when data
+// is not an envelope.
+func operationElement(data []byte) (*xml.Decoder, xml.StartElement, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, xml.StartElement{}, fmt.Errorf("no operation found in SOAP body: %w", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if !isEnvelopeNamespace(start.Name.Space) || start.Name.Local == "Fault" {
+			return decoder, start, nil
+		}
+		if start.Name.Local == "Header" {
+			if err := decoder.Skip(); err != nil {
+				return nil, xml.StartElement{}, err
+			}
+		}
+	}
+}
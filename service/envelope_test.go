@@ -0,0 +1,48 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hooklift/gowsdl/soap"
+)
+
+func TestSOAP12Envelopes(t *testing.T) {
+	request := `<env:Envelope xmlns:env="` + SOAP12Namespace + `"><env:Header><Session xmlns="urn:vendor"/></env:Header><env:Body><GetStatus xmlns="` + opcNamespace + `"/></env:Body></env:Envelope>`
+	operation, envelope, err := RequestOperation([]byte(request))
+	if err != nil || operation != "GetStatus" || envelope != SOAP12Namespace {
+		t.Fatalf("RequestOperation = %q, %q, %v", operation, envelope, err)
+	}
+	if _, req, err := DecodeRequest([]byte(request)); err != nil {
+		t.Fatalf("DecodeRequest returned error: %v", err)
+	} else if _, ok := req.(*GetStatus); !ok {
+		t.Fatalf("DecodeRequest = %T", req)
+	}
+
+	response := `<env:Envelope xmlns:env="` + SOAP12Namespace + `"><env:Body><GetStatusResponse xmlns="` + opcNamespace + `"><Status ProductVersion="1.0"/></GetStatusResponse></env:Body></env:Envelope>`
+	var status GetStatusResponse
+	if err := (Quirks{}).DecodeResponse([]byte(response), &status); err != nil || status.Status == nil || status.Status.ProductVersion != "1.0" {
+		t.Fatalf("DecodeResponse = %+v, %v", status.Status, err)
+	}
+
+	fault := `<env:Envelope xmlns:env="` + SOAP12Namespace + `"><env:Body><env:Fault><env:Code><env:Value>env:Receiver</env:Value></env:Code><env:Reason><env:Text xml:lang="en">device offline</env:Text></env:Reason></env:Fault></env:Body></env:Envelope>`
+	var soapFault *soap.SOAPFault
+	if _, _, err := DecodeResponse([]byte(fault)); !errors.As(err, &soapFault) || soapFault.Code != "env:Receiver" || soapFault.String != "device offline" {
+		t.Fatalf("DecodeResponse(fault) error = %v", err)
+	}
+}
+
+func TestWrapRequestKeepsElement(t *testing.T) {
+	element := `<Read xmlns="` + opcNamespace + `" xmlns:v="urn:vendor"><v:Extension/></Read>`
+	data, err := WrapRequest([]byte(`<?xml version="1.0"?>` + "\n" + element))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<soap:Envelope xmlns:soap="` + soap.XmlNsSoapEnv + `"><soap:Body>` + element + `</soap:Body></soap:Envelope>`
+	if string(data) != want {
+		t.Fatalf("WrapRequest = %s, want %s", data, want)
+	}
+	if operation, envelope, err := RequestOperation(data); err != nil || operation != "Read" || envelope != soap.XmlNsSoapEnv {
+		t.Fatalf("RequestOperation(wrapped) = %q, %q, %v", operation, envelope, err)
+	}
+}
@@ -0,0 +1,361 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/hooklift/gowsdl/soap"
+)
+
+// SOAPAction header formats for Quirks.SOAPAction.
+const (
+	// SOAPActionURI sends the WSDL action URI as is, the default.
+	SOAPActionURI = "uri"
+	// SOAPActionQuoted wraps the action URI in double quotes, as SOAP 1.1
+	// asks for.
+	SOAPActionQuoted = "quoted"
+	// SOAPActionOperation sends only the operation name, such as Read.
+	SOAPActionOperation = "operation"
+)
+
+// Quirks describes how a server deviates from the OPC XML-DA WSDL, and
+// adjusts request serialization and response parsing to match. The zero
+// value follows the WSDL.
+type Quirks struct {
+	// SOAPAction is the SOAPAction header format; empty means SOAPActionURI.
+	SOAPAction string
+	// NamespacePrefix writes request elements as prefix:Name with the OPC
+	// namespace bound to prefix, for servers that do not resolve a default
+	// namespace.
+	NamespacePrefix string
+	// SendEmptyItemPath writes ItemPath="" instead of omitting an empty
+	// item path.
+	SendEmptyItemPath bool
+	// LenientNamespaces accepts response elements in any namespace, or
+	// none, in place of the OPC namespace.
+	LenientNamespaces bool
+	// TimestampZone is the zone of response timestamps written without an
+	// offset. Nil keeps the default, the local zone of this machine.
+	TimestampZone *time.Location
+	// ContinuationWithoutMoreElements treats a Browse reply that carries a
+	// ContinuationPoint as having more elements even when MoreElements is
+	// missing or false.
+	ContinuationWithoutMoreElements bool
+	// ContinuationOmitsItem leaves ItemPath and ItemName out of Browse
+	// requests that carry a ContinuationPoint.
+	ContinuationOmitsItem bool
+}
+
+// QuirksPresets are built-in quirk sets for common kinds of gateway.
+var QuirksPresets = map[string]Quirks{
+	// wsdl follows the WSDL; it is the same as no quirks.
+	"wsdl": {},
+	// soap11-strict suits servers on strict SOAP 1.1 stacks that need a
+	// quoted SOAPAction, prefixed elements, and every ItemPath attribute.
+	"soap11-strict": {SOAPAction: SOAPActionQuoted, NamespacePrefix: "opc", SendEmptyItemPath: true},
+	// dcom-wrapper suits XML-DA front ends to classic OPC DA servers, which
+	// often write UTC FILETIME timestamps without a zone and answer in a
+	// namespace that differs from the WSDL.
+	"dcom-wrapper": {TimestampZone: time.UTC, LenientNamespaces: true},
+	// lenient accepts the common response deviations.
+	"lenient": {LenientNamespaces: true, ContinuationWithoutMoreElements: true},
+}
+
+// QuirksPresetNames lists the built-in presets in name order.
+func QuirksPresetNames() []string {
+	names := make([]string, 0, len(QuirksPresets))
+	for name := range QuirksPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsZero reports whether q follows the WSDL.
+func (q Quirks) IsZero() bool {
+	return q == Quirks{}
+}
+
+// Call sends request for operation through client with q applied and
+// decodes the reply into response, a pointer such as the one NewResponse
+// returns.
+func (q Quirks) Call(ctx context.Context, client *soap.Client, operation string, request, response interface{}) error {
+	if browse, ok := request.(*Browse); ok && q.ContinuationOmitsItem && browse.ContinuationPoint != "" {
+		page := *browse
+		page.ItemPath, page.ItemName = "", ""
+		request = &page
+	}
+	var body interface{} = request
+	if q.NamespacePrefix != "" || q.SendEmptyItemPath {
+		body = quirkyRequest{request: request, quirks: q}
+	}
+	var reply interface{} = response
+	if q.LenientNamespaces {
+		reply = &lenientResponse{response: response}
+	}
+	if err := client.CallContext(ctx, q.Action(operation), body, reply); err != nil {
+		return err
+	}
+	q.adjustResponse(response)
+	return nil
+}
+
+// Action returns the SOAPAction header value for operation.
+func (q Quirks) Action(operation string) string {
+	switch q.SOAPAction {
+	case SOAPActionQuoted:
+		return `"` + opcNamespace + operation + `"`
+	case SOAPActionOperation:
+		return operation
+	default:
+		return opcNamespace + operation
+	}
+}
+
+// DecodeResponse decodes a SOAP 1.1 or 1.2 response envelope into response
+// with q applied, as Call does. A SOAP fault is returned as a
+// *soap.SOAPFault error.
+func (q Quirks) DecodeResponse(data []byte, response interface{}) error {
+	decoder, start, err := operationElement(data)
+	if err != nil {
+		return err
+	}
+	if start.Name.Local == "Fault" {
+		return decodeFault(decoder, start)
+	}
+	var reply interface{} = response
+	if q.LenientNamespaces {
+		reply = &lenientResponse{response: response}
+	}
+	if err := decoder.DecodeElement(reply, &start); err != nil {
+		return fmt.Errorf("decode %s: %w", start.Name.Local, err)
+	}
+	q.adjustResponse(response)
+	return nil
+}
+
+func (q Quirks) adjustResponse(response interface{}) {
+	if q.TimestampZone != nil {
+		forEachDateTime(reflect.ValueOf(response), func(dt *XSDDateTime) { dt.inZone(q.TimestampZone) })
+	}
+	if browse, ok := response.(*BrowseResponse); ok && q.ContinuationWithoutMoreElements && browse.ContinuationPoint != "" {
+		browse.MoreElements = true
+	}
+}
+
+var xsdDateTimeType = reflect.TypeOf(XSDDateTime{})
+
+// forEachDateTime calls fn for every XSDDateTime reachable from v.
+func forEachDateTime(v reflect.Value, fn func(*XSDDateTime)) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			forEachDateTime(v.Elem(), fn)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			forEachDateTime(v.Index(i), fn)
+		}
+	case reflect.Struct:
+		if v.Type() == xsdDateTimeType {
+			if v.CanAddr() {
+				fn(v.Addr().Interface().(*XSDDateTime))
+			}
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				forEachDateTime(v.Field(i), fn)
+			}
+		}
+	}
+}
+
+// quirkyRequest marshals request and rewrites the elements for the
+// serialization quirks.
+type quirkyRequest struct {
+	request interface{}
+	quirks  Quirks
+}
+
+func (r quirkyRequest) MarshalXML(e *xml.Encoder, _ xml.StartElement) error {
+	data, err := xml.Marshal(r.request)
+	if err != nil {
+		return err
+	}
+	prefix := r.quirks.NamespacePrefix
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	// inOPC tracks, per open element, whether unprefixed names are in the
+	// OPC namespace.
+	var inOPC []bool
+	for {
+		token, err := decoder.RawToken()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			start := xml.StartElement{Name: rawName(t.Name)}
+			opc := len(inOPC) == 0 || inOPC[len(inOPC)-1]
+			for _, attr := range t.Attr {
+				attr.Name = rawName(attr.Name)
+				if attr.Name.Local == "xmlns" {
+					if opc = attr.Value == opcNamespace; opc && prefix != "" {
+						attr.Name.Local = "xmlns:" + prefix
+					}
+				}
+				start.Attr = append(start.Attr, attr)
+			}
+			if r.quirks.SendEmptyItemPath && opc && needsItemPath(start, len(inOPC) == 0) {
+				start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "ItemPath"}})
+			}
+			if opc && prefix != "" && t.Name.Space == "" {
+				start.Name.Local = prefix + ":" + start.Name.Local
+			}
+			inOPC = append(inOPC, opc)
+			token = start
+		case xml.EndElement:
+			end := xml.EndElement{Name: rawName(t.Name)}
+			if inOPC[len(inOPC)-1] && prefix != "" && t.Name.Space == "" {
+				end.Name.Local = prefix + ":" + end.Name.Local
+			}
+			inOPC = inOPC[:len(inOPC)-1]
+			token = end
+		}
+		if err := e.EncodeToken(xml.CopyToken(token)); err != nil {
+			return err
+		}
+	}
+}
+
+// rawName turns a prefixed name from RawToken into a local name the
+// encoder writes verbatim.
+func rawName(name xml.Name) xml.Name {
+	if name.Space == "" {
+		return name
+	}
+	return xml.Name{Local: name.Space + ":" + name.Local}
+}
+
+// needsItemPath reports whether start names an item, or is a Browse
+// request, without an ItemPath attribute.
+func needsItemPath(start xml.StartElement, root bool) bool {
+	named := root && start.Name.Local == "Browse"
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "ItemPath":
+			return false
+		case "ItemName":
+			named = true
+		}
+	}
+	return named
+}
+
+// lenientResponse decodes the operation element into response whatever
+// its namespace.
+type lenientResponse struct {
+	response interface{}
+}
+
+func (r *lenientResponse) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	start.Name.Space = opcNamespace
+	return d.DecodeElement(r.response, &start)
+}
+
+// quirkService is an OpcXmlDASoap client that applies quirks to every call.
+type quirkService struct {
+	client *soap.Client
+	quirks Quirks
+}
+
+// NewOpcXmlDASoapWithQuirks returns a client for a server with quirks q.
+// With the zero Quirks it is the generated client.
+func NewOpcXmlDASoapWithQuirks(client *soap.Client, q Quirks) OpcXmlDASoap {
+	if q.IsZero() {
+		return NewOpcXmlDASoap(client)
+	}
+	return &quirkService{client: client, quirks: q}
+}
+
+func quirkCall[T any](ctx context.Context, s *quirkService, operation string, request interface{}) (*T, error) {
+	response := new(T)
+	if err := s.quirks.Call(ctx, s.client, operation, request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (s *quirkService) GetStatusContext(ctx context.Context, request *GetStatus) (*GetStatusResponse, error) {
+	return quirkCall[GetStatusResponse](ctx, s, "GetStatus", request)
+}
+
+func (s *quirkService) GetPropertiesContext(ctx context.Context, request *GetProperties) (*GetPropertiesResponse, error) {
+	return quirkCall[GetPropertiesResponse](ctx, s, "GetProperties", request)
+}
+
+func (s *quirkService) SubscribeContext(ctx context.Context, request *Subscribe) (*SubscribeResponse, error) {
+	return quirkCall[SubscribeResponse](ctx, s, "Subscribe", request)
+}
+
+func (s *quirkService) SubscriptionPolledRefreshContext(ctx context.Context, request *SubscriptionPolledRefresh) (*SubscriptionPolledRefreshResponse, error) {
+	return quirkCall[SubscriptionPolledRefreshResponse](ctx, s, "SubscriptionPolledRefresh", request)
+}
+
+func (s *quirkService) SubscriptionCancelContext(ctx context.Context, request *SubscriptionCancel) (*SubscriptionCancelResponse, error) {
+	return quirkCall[SubscriptionCancelResponse](ctx, s, "SubscriptionCancel", request)
+}
+
+func (s *quirkService) BrowseContext(ctx context.Context, request *Browse) (*BrowseResponse, error) {
+	return quirkCall[BrowseResponse](ctx, s, "Browse", request)
+}
+
+func (s *quirkService) ReadContext(ctx context.Context, request *Read) (*ReadResponse, error) {
+	return quirkCall[ReadResponse](ctx, s, "Read", request)
+}
+
+func (s *quirkService) WriteContext(ctx context.Context, request *Write) (*WriteResponse, error) {
+	return quirkCall[WriteResponse](ctx, s, "Write", request)
+}
+
+func (s *quirkService) GetStatus(request *GetStatus) (*GetStatusResponse, error) {
+	return s.GetStatusContext(context.Background(), request)
+}
+
+func (s *quirkService) GetProperties(request *GetProperties) (*GetPropertiesResponse, error) {
+	return s.GetPropertiesContext(context.Background(), request)
+}
+
+func (s *quirkService) Subscribe(request *Subscribe) (*SubscribeResponse, error) {
+	return s.SubscribeContext(context.Background(), request)
+}
+
+func (s *quirkService) SubscriptionPolledRefresh(request *SubscriptionPolledRefresh) (*SubscriptionPolledRefreshResponse, error) {
+	return s.SubscriptionPolledRefreshContext(context.Background(), request)
+}
+
+func (s *quirkService) SubscriptionCancel(request *SubscriptionCancel) (*SubscriptionCancelResponse, error) {
+	return s.SubscriptionCancelContext(context.Background(), request)
+}
+
+func (s *quirkService) Browse(request *Browse) (*BrowseResponse, error) {
+	return s.BrowseContext(context.Background(), request)
+}
+
+func (s *quirkService) Read(request *Read) (*ReadResponse, error) {
+	return s.ReadContext(context.Background(), request)
+}
+
+func (s *quirkService) Write(request *Write) (*WriteResponse, error) {
+	return s.WriteContext(context.Background(), request)
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hooklift/gowsdl/soap"
+)
+
+// quirkServer answers every call with body and records the last request.
+type quirkServer struct {
+	action  string
+	request string
+}
+
+func newQuirkServer(t *testing.T, body string) (*quirkServer, *soap.Client) {
+	t.Helper()
+	s := &quirkServer{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		s.action, s.request = r.Header.Get("SOAPAction"), string(data)
+		w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+		_, _ = io.WriteString(w, `<?xml version="1.0"?><soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body>`+body+`</soap:Body></soap:Envelope>`)
+	}))
+	t.Cleanup(server.Close)
+	return s, soap.NewClient(server.URL)
+}
+
+func TestQuirksSOAPActionFormats(t *testing.T) {
+	server, client := newQuirkServer(t, `<GetStatusResponse xmlns="`+opcNamespace+`"/>`)
+	for format, want := range map[string]string{
+		"":                  opcNamespace + "GetStatus",
+		SOAPActionQuoted:    `"` + opcNamespace + `GetStatus"`,
+		SOAPActionOperation: "GetStatus",
+	} {
+		q := Quirks{SOAPAction: format}
+		if err := q.Call(context.Background(), client, "GetStatus", &GetStatus{}, &GetStatusResponse{}); err != nil {
+			t.Fatal(err)
+		}
+		if server.action != want {
+			t.Errorf("SOAPAction with %q = %q, want %q", format, server.action, want)
+		}
+	}
+}
+
+func TestQuirksRewriteRequest(t *testing.T) {
+	server, client := newQuirkServer(t, `<ReadResponse xmlns="`+opcNamespace+`"/>`)
+	svc := NewOpcXmlDASoapWithQuirks(client, Quirks{NamespacePrefix: "opc", SendEmptyItemPath: true})
+	req := &Read{ItemList: &ReadRequestItemList{Items: []*ReadRequestItem{{ItemName: "Plant.Area.Temp"}}}}
+	if _, err := svc.Read(req); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`<opc:Read xmlns:opc="` + opcNamespace + `"`, `<opc:Items ItemName="Plant.Area.Temp" ItemPath="">`, `</opc:Read>`} {
+		if !strings.Contains(server.request, want) {
+			t.Errorf("request missing %s:\n%s", want, server.request)
+		}
+	}
+}
+
+func TestQuirksParseResponses(t *testing.T) {
+	server, client := newQuirkServer(t, `<ReadResponse xmlns="urn:vendor"><RItemList><Items ItemName="A" Timestamp="2024-03-01 12:00:00"/></RItemList></ReadResponse>`)
+	if _, err := NewOpcXmlDASoap(client).Read(&Read{}); err == nil {
+		t.Fatal("Read accepted a response in another namespace without quirks")
+	}
+	svc := NewOpcXmlDASoapWithQuirks(client, QuirksPresets["dcom-wrapper"])
+	resp, err := svc.Read(&Read{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := resp.RItemList.Items[0].Timestamp.ToGoTime()
+	if want := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("timestamp = %v, want %v", got, want)
+	}
+	if server.action != opcNamespace+"Read" {
+		t.Fatalf("SOAPAction = %q", server.action)
+	}
+}
+
+func TestParseXsdDateTimeVariants(t *testing.T) {
+	want := time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC)
+	for _, value := range []string{"2024-03-01T12:00:00+01:00", "2024-03-01T12:00:00+0100", "2024-03-01 12:00:00+01:00", "2024-03-01T11:00:00Z"} {
+		got, hasTz, err := parseXsdDateTime(value)
+		if err != nil || !hasTz || !got.Equal(want) {
+			t.Errorf("parseXsdDateTime(%q) = %v, %v, %v", value, got, hasTz, err)
+		}
+	}
+	if _, hasTz, err := parseXsdDateTime("2024-03-01T12:00:00"); err != nil || hasTz {
+		t.Errorf("parseXsdDateTime without zone = %v, %v", hasTz, err)
+	}
+}
+
+func TestQuirksBrowseContinuation(t *testing.T) {
+	server, client := newQuirkServer(t, `<BrowseResponse xmlns="`+opcNamespace+`" ContinuationPoint="cp2"/>`)
+	svc := NewOpcXmlDASoapWithQuirks(client, Quirks{ContinuationWithoutMoreElements: true, ContinuationOmitsItem: true})
+	resp, err := svc.Browse(&Browse{ItemPath: "Path", ItemName: "Plant", ContinuationPoint: "cp1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.MoreElements {
+		t.Error("MoreElements = false with a continuation point")
+	}
+	if strings.Contains(server.request, "Plant") || !strings.Contains(server.request, `ContinuationPoint="cp1"`) {
+		t.Errorf("continuation request = %s", server.request)
+	}
+}
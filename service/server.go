@@ -0,0 +1,104 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/hooklift/gowsdl/soap"
+)
+
+const maxRequestBytes = 10 << 20
+
+// NewHandler serves the OPC XML-DA SOAP binding over HTTP. Each request is
+// decoded by its body element and dispatched to impl; errors become SOAP
+// faults. A *soap.SOAPFault returned by impl is sent as is, so
+// implementations can choose fault codes such as E_NOSUBSCRIPTION.
+func NewHandler(impl OpcXmlDASoap) http.Handler {
+	return &soapHandler{impl: impl}
+}
+
+type soapHandler struct {
+	impl OpcXmlDASoap
+}
+
+func (h *soapHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "OPC XML-DA requests must be POSTed SOAP envelopes", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBytes))
+	if err != nil {
+		writeFault(w, "soap:Client", fmt.Sprintf("read request: %v", err))
+		return
+	}
+	resp, err := h.dispatch(r.Context(), body)
+	if err != nil {
+		var fault *soap.SOAPFault
+		if errors.As(err, &fault) {
+			writeFault(w, fault.Code, fault.String)
+			return
+		}
+		var clientErr requestError
+		if errors.As(err, &clientErr) {
+			writeFault(w, "soap:Client", err.Error())
+			return
+		}
+		writeFault(w, "soap:Server", err.Error())
+		return
+	}
+	writeEnvelope(w, http.StatusOK, soap.SOAPBody{Content: resp})
+}
+
+// requestError marks faults caused by a malformed request.
+type requestError struct{ error }
+
+// dispatch decodes the operation element inside the SOAP body and calls the
+// matching implementation method.
+func (h *soapHandler) dispatch(ctx context.Context, body []byte) (interface{}, error) {
+	_, req, err := DecodeRequest(body)
+	if err != nil {
+		return nil, requestError{err}
+	}
+	switch req := req.(type) {
+	case *GetStatus:
+		return h.impl.GetStatusContext(ctx, req)
+	case *Browse:
+		return h.impl.BrowseContext(ctx, req)
+	case *GetProperties:
+		return h.impl.GetPropertiesContext(ctx, req)
+	case *Read:
+		return h.impl.ReadContext(ctx, req)
+	case *Write:
+		return h.impl.WriteContext(ctx, req)
+	case *Subscribe:
+		return h.impl.SubscribeContext(ctx, req)
+	case *SubscriptionPolledRefresh:
+		return h.impl.SubscriptionPolledRefreshContext(ctx, req)
+	case *SubscriptionCancel:
+		return h.impl.SubscriptionCancelContext(ctx, req)
+	default:
+		return nil, requestError{fmt.Errorf("unsupported request %T", req)}
+	}
+}
+
+func writeFault(w http.ResponseWriter, code, message string) {
+	writeEnvelope(w, http.StatusInternalServerError, soap.SOAPBody{Fault: &soap.SOAPFault{Code: code, String: message}})
+}
+
+func writeEnvelope(w http.ResponseWriter, status int, body soap.SOAPBody) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	if err := xml.NewEncoder(&buf).Encode(soap.SOAPEnvelope{XmlNS: soap.XmlNsSoapEnv, Body: body}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	w.WriteHeader(status)
+	_, _ = w.Write(buf.Bytes())
+}
@@ -0,0 +1,309 @@
+// Package servicetest provides an in-memory, scriptable OpcXmlDASoap and an
+// httptest server speaking the SOAP binding, for testing code built on the
+// service package without a real OPC XML-DA server.
+//
+// A Fake serves a simulator whose tag tree starts empty, so it browses,
+// pages, and reports subscription changes the same way. Items are addressed
+// by dotted names and branches are created for every prefix, so adding
+// "Plant.Area.Temp" makes Plant and Plant.Area browsable:
+//
+//	fake := servicetest.New()
+//	fake.SetValue("Plant.Area.Temp", 21.5)
+//	fake.SetItemResult("Plant.Area.Broken", "E_UNKNOWNITEMNAME")
+//	fake.SetError("Browse", errors.New("boom"))
+package servicetest
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"opc-xml-da-cli/internal/simulator"
+	"opc-xml-da-cli/service"
+)
+
+// Operation names accepted by SetError, FailNext, and Calls.
+const (
+	OpGetStatus                 = "GetStatus"
+	OpGetProperties             = "GetProperties"
+	OpBrowse                    = "Browse"
+	OpRead                      = "Read"
+	OpWrite                     = "Write"
+	OpSubscribe                 = "Subscribe"
+	OpSubscriptionPolledRefresh = "SubscriptionPolledRefresh"
+	OpSubscriptionCancel        = "SubscriptionCancel"
+)
+
+// Fake is a scriptable in-memory OpcXmlDASoap. Each operation records the
+// call, applies scripted latency and errors, and then answers from the
+// simulator. All methods are safe for concurrent use, so a test can change
+// values while a client polls.
+type Fake struct {
+	sim *simulator.Simulator
+
+	mu       sync.Mutex
+	latency  time.Duration
+	errors   map[string]error
+	failNext map[string][]error
+	calls    map[string]int
+	requests []interface{}
+}
+
+// New returns a Fake reporting a running server with no items.
+func New() *Fake {
+	model := &simulator.Model{Server: simulator.ServerInfo{
+		State:          service.ServerStateRunning,
+		VendorInfo:     "servicetest",
+		ProductVersion: "1.0",
+	}}
+	return &Fake{
+		sim:      simulator.New(model),
+		errors:   map[string]error{},
+		failNext: map[string][]error{},
+		calls:    map[string]int{},
+	}
+}
+
+// SetStatus sets the server state and status text reported by GetStatus and
+// every reply base.
+func (f *Fake) SetStatus(state service.ServerState, statusInfo string) {
+	f.sim.SetServerStatus(state, statusInfo)
+}
+
+// SetLatency delays every call by d, or until the call's context ends.
+func (f *Fake) SetLatency(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.latency = d
+}
+
+// SetPageSize limits Browse replies to n elements, paging the rest with
+// continuation points. Zero returns every element at once.
+func (f *Fake) SetPageSize(n int) {
+	f.sim.SetPageSize(n)
+}
+
+// SetError makes every call to op fail with err until it is cleared with a
+// nil error.
+func (f *Fake) SetError(op string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err == nil {
+		delete(f.errors, op)
+		return
+	}
+	f.errors[op] = err
+}
+
+// FailNext makes the next len(errs) calls to op fail with errs in order.
+func (f *Fake) FailNext(op string, errs ...error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failNext[op] = append(f.failNext[op], errs...)
+}
+
+// Calls returns how many times op has been called, including failed calls.
+func (f *Fake) Calls(op string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[op]
+}
+
+// Requests returns every request received so far, in order. Elements are
+// the request types from the service package, such as *service.Read.
+func (f *Fake) Requests() []interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]interface{}(nil), f.requests...)
+}
+
+// AddBranch adds an empty branch, and any missing parents.
+func (f *Fake) AddBranch(itemName string) {
+	f.sim.AddBranch(itemName)
+}
+
+// SetValue adds or updates an item with good quality. value may be a
+// service.AnyType or a Go bool, string, time.Time, integer, or float, which
+// is encoded with the matching xsd type.
+func (f *Fake) SetValue(itemName string, value interface{}) {
+	f.SetValueQuality(itemName, value, service.QualityBitsGood)
+}
+
+// SetValueQuality adds or updates an item with the given quality.
+func (f *Fake) SetValueQuality(itemName string, value interface{}, quality service.QualityBits) {
+	f.sim.SetValue(itemName, Encode(value), quality)
+}
+
+// SetItemResult makes reads of an item return resultID, such as
+// E_UNKNOWNITEMNAME, instead of a value. An empty resultID clears it.
+func (f *Fake) SetItemResult(itemName, resultID string) {
+	f.sim.SetItemResult(itemName, resultID)
+}
+
+// SetReadOnly makes writes to an item fail with E_READONLY.
+func (f *Fake) SetReadOnly(itemName string, readOnly bool) {
+	f.sim.SetWritable(itemName, !readOnly)
+}
+
+// Value returns the current value of an item and whether it exists.
+func (f *Fake) Value(itemName string) (service.AnyType, bool) {
+	return f.sim.Value(itemName)
+}
+
+// ExpireSubscriptions drops every subscription, as a server does when a
+// client stops polling. Later refreshes report the handles as invalid.
+func (f *Fake) ExpireSubscriptions() {
+	f.sim.ExpireSubscriptions()
+}
+
+// Encode converts a Go value to an xsd typed AnyType.
+func Encode(value interface{}) service.AnyType {
+	text, xsdType := "", ""
+	switch v := value.(type) {
+	case service.AnyType:
+		return v
+	case bool:
+		text, xsdType = strconv.FormatBool(v), "boolean"
+	case string:
+		text, xsdType = escape(v), "string"
+	case time.Time:
+		text, xsdType = v.UTC().Format(time.RFC3339Nano), "dateTime"
+	case int:
+		text, xsdType = strconv.Itoa(v), "int"
+	case int32:
+		text, xsdType = strconv.FormatInt(int64(v), 10), "int"
+	case int64:
+		text, xsdType = strconv.FormatInt(v, 10), "long"
+	case uint32:
+		text, xsdType = strconv.FormatUint(uint64(v), 10), "unsignedInt"
+	case float32:
+		text, xsdType = strconv.FormatFloat(float64(v), 'g', -1, 32), "float"
+	case float64:
+		text, xsdType = strconv.FormatFloat(v, 'g', -1, 64), "double"
+	default:
+		text, xsdType = escape(fmt.Sprint(v)), "string"
+	}
+	return service.AnyType{InnerXML: text, Type: xsdType}
+}
+
+func escape(text string) string {
+	var buf strings.Builder
+	_ = xml.EscapeText(&buf, []byte(text))
+	return buf.String()
+}
+
+// begin records a call and applies latency and scripted errors.
+func (f *Fake) begin(ctx context.Context, op string, request interface{}) error {
+	f.mu.Lock()
+	f.calls[op]++
+	f.requests = append(f.requests, request)
+	latency := f.latency
+	err := f.errors[op]
+	if queued := f.failNext[op]; len(queued) > 0 {
+		err = queued[0]
+		f.failNext[op] = queued[1:]
+	}
+	f.mu.Unlock()
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return err
+}
+
+func (f *Fake) GetStatusContext(ctx context.Context, request *service.GetStatus) (*service.GetStatusResponse, error) {
+	if err := f.begin(ctx, OpGetStatus, request); err != nil {
+		return nil, err
+	}
+	return f.sim.GetStatusContext(ctx, request)
+}
+
+func (f *Fake) GetPropertiesContext(ctx context.Context, request *service.GetProperties) (*service.GetPropertiesResponse, error) {
+	if err := f.begin(ctx, OpGetProperties, request); err != nil {
+		return nil, err
+	}
+	return f.sim.GetPropertiesContext(ctx, request)
+}
+
+func (f *Fake) BrowseContext(ctx context.Context, request *service.Browse) (*service.BrowseResponse, error) {
+	if err := f.begin(ctx, OpBrowse, request); err != nil {
+		return nil, err
+	}
+	return f.sim.BrowseContext(ctx, request)
+}
+
+func (f *Fake) ReadContext(ctx context.Context, request *service.Read) (*service.ReadResponse, error) {
+	if err := f.begin(ctx, OpRead, request); err != nil {
+		return nil, err
+	}
+	return f.sim.ReadContext(ctx, request)
+}
+
+func (f *Fake) WriteContext(ctx context.Context, request *service.Write) (*service.WriteResponse, error) {
+	if err := f.begin(ctx, OpWrite, request); err != nil {
+		return nil, err
+	}
+	return f.sim.WriteContext(ctx, request)
+}
+
+func (f *Fake) SubscribeContext(ctx context.Context, request *service.Subscribe) (*service.SubscribeResponse, error) {
+	if err := f.begin(ctx, OpSubscribe, request); err != nil {
+		return nil, err
+	}
+	return f.sim.SubscribeContext(ctx, request)
+}
+
+func (f *Fake) SubscriptionPolledRefreshContext(ctx context.Context, request *service.SubscriptionPolledRefresh) (*service.SubscriptionPolledRefreshResponse, error) {
+	if err := f.begin(ctx, OpSubscriptionPolledRefresh, request); err != nil {
+		return nil, err
+	}
+	return f.sim.SubscriptionPolledRefreshContext(ctx, request)
+}
+
+func (f *Fake) SubscriptionCancelContext(ctx context.Context, request *service.SubscriptionCancel) (*service.SubscriptionCancelResponse, error) {
+	if err := f.begin(ctx, OpSubscriptionCancel, request); err != nil {
+		return nil, err
+	}
+	return f.sim.SubscriptionCancelContext(ctx, request)
+}
+
+func (f *Fake) GetStatus(request *service.GetStatus) (*service.GetStatusResponse, error) {
+	return f.GetStatusContext(context.Background(), request)
+}
+
+func (f *Fake) GetProperties(request *service.GetProperties) (*service.GetPropertiesResponse, error) {
+	return f.GetPropertiesContext(context.Background(), request)
+}
+
+func (f *Fake) Browse(request *service.Browse) (*service.BrowseResponse, error) {
+	return f.BrowseContext(context.Background(), request)
+}
+
+func (f *Fake) Read(request *service.Read) (*service.ReadResponse, error) {
+	return f.ReadContext(context.Background(), request)
+}
+
+func (f *Fake) Write(request *service.Write) (*service.WriteResponse, error) {
+	return f.WriteContext(context.Background(), request)
+}
+
+func (f *Fake) Subscribe(request *service.Subscribe) (*service.SubscribeResponse, error) {
+	return f.SubscribeContext(context.Background(), request)
+}
+
+func (f *Fake) SubscriptionPolledRefresh(request *service.SubscriptionPolledRefresh) (*service.SubscriptionPolledRefreshResponse, error) {
+	return f.SubscriptionPolledRefreshContext(context.Background(), request)
+}
+
+func (f *Fake) SubscriptionCancel(request *service.SubscriptionCancel) (*service.SubscriptionCancelResponse, error) {
+	return f.SubscriptionCancelContext(context.Background(), request)
+}
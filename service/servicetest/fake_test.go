@@ -0,0 +1,119 @@
+package servicetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hooklift/gowsdl/soap"
+
+	"opc-xml-da-cli/service"
+)
+
+func TestFakeBrowsePagesOverSOAP(t *testing.T) {
+	fake := New()
+	for _, name := range []string{"Plant.A", "Plant.B", "Plant.C"} {
+		fake.SetValue(name, 1)
+	}
+	fake.SetPageSize(2)
+	server := NewServer(fake)
+	defer server.Close()
+	client := server.Service()
+
+	first, err := client.BrowseContext(context.Background(), &service.Browse{ItemName: "Plant"})
+	if err != nil {
+		t.Fatalf("Browse() error = %v", err)
+	}
+	if len(first.Elements) != 2 || !first.MoreElements {
+		t.Fatalf("first page = %d elements, more %v", len(first.Elements), first.MoreElements)
+	}
+	second, err := client.BrowseContext(context.Background(), &service.Browse{ItemName: "Plant", ContinuationPoint: first.ContinuationPoint})
+	if err != nil {
+		t.Fatalf("Browse() error = %v", err)
+	}
+	if len(second.Elements) != 1 || second.Elements[0].ItemName != "Plant.C" || second.MoreElements {
+		t.Fatalf("second page = %+v", second)
+	}
+	if got := fake.Calls(OpBrowse); got != 2 {
+		t.Fatalf("Calls(Browse) = %d", got)
+	}
+}
+
+func TestFakeScriptedErrorsAndLatency(t *testing.T) {
+	fake := New()
+	boom := errors.New("boom")
+	fake.FailNext(OpGetStatus, boom)
+	if _, err := fake.GetStatus(&service.GetStatus{}); !errors.Is(err, boom) {
+		t.Fatalf("first GetStatus() error = %v", err)
+	}
+	if _, err := fake.GetStatus(&service.GetStatus{}); err != nil {
+		t.Fatalf("second GetStatus() error = %v", err)
+	}
+
+	fake.SetLatency(time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := fake.GetStatusContext(ctx, &service.GetStatus{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GetStatus() with latency error = %v", err)
+	}
+}
+
+func TestFakeSubscriptionReportsChanges(t *testing.T) {
+	fake := New()
+	fake.SetValue("Tag.A", 1.5)
+	fake.SetValue("Tag.B", "x")
+	sub, err := fake.Subscribe(&service.Subscribe{
+		ItemList:            &service.SubscribeRequestItemList{Items: []*service.SubscribeRequestItem{{ItemName: "Tag.A"}, {ItemName: "Tag.B"}}},
+		ReturnValuesOnReply: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	refresh := func() *service.SubscriptionPolledRefreshResponse {
+		t.Helper()
+		resp, err := fake.SubscriptionPolledRefresh(&service.SubscriptionPolledRefresh{ServerSubHandles: []string{sub.ServerSubHandle}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	if resp := refresh(); len(resp.RItemList) != 0 {
+		t.Fatalf("unchanged refresh returned %+v", resp.RItemList)
+	}
+	fake.SetValue("Tag.B", "y")
+	resp := refresh()
+	if len(resp.RItemList) != 1 || len(resp.RItemList[0].Items) != 1 || resp.RItemList[0].Items[0].Value.InnerXML != "y" {
+		t.Fatalf("changed refresh returned %+v", resp.RItemList)
+	}
+
+	fake.ExpireSubscriptions()
+	if resp := refresh(); len(resp.InvalidServerSubHandles) != 1 {
+		t.Fatalf("expired refresh returned %+v", resp)
+	}
+	_, err = fake.SubscriptionCancel(&service.SubscriptionCancel{ServerSubHandle: sub.ServerSubHandle})
+	var fault *soap.SOAPFault
+	if !errors.As(err, &fault) || fault.Code != "E_NOSUBSCRIPTION" {
+		t.Fatalf("SubscriptionCancel() error = %v", err)
+	}
+}
+
+func TestFakeWriteRespectsReadOnly(t *testing.T) {
+	fake := New()
+	fake.SetValue("Tag.Setpoint", 1.0)
+	fake.SetValue("Tag.PV", 1.0)
+	fake.SetReadOnly("Tag.PV", true)
+	resp, err := fake.Write(&service.Write{ItemList: &service.WriteRequestItemList{Items: []*service.ItemValue{
+		{ItemName: "Tag.Setpoint", Value: Encode(2.5)},
+		{ItemName: "Tag.PV", Value: Encode(2.5)},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.RItemList.Items[0].ResultID != nil || *resp.RItemList.Items[1].ResultID != "E_READONLY" {
+		t.Fatalf("Write() items = %+v", resp.RItemList.Items)
+	}
+	if value, _ := fake.Value("Tag.Setpoint"); value.InnerXML != "2.5" || value.Type != "double" {
+		t.Fatalf("Value(Tag.Setpoint) = %+v", value)
+	}
+}
@@ -0,0 +1,26 @@
+package servicetest
+
+import (
+	"net/http/httptest"
+
+	"github.com/hooklift/gowsdl/soap"
+
+	"opc-xml-da-cli/service"
+)
+
+// Server is an httptest server speaking the OPC XML-DA SOAP binding, for
+// tests that need real HTTP traffic: endpoints, transports, and debug dumps.
+type Server struct {
+	*httptest.Server
+}
+
+// NewServer starts a server answering with svc, usually a *Fake. Callers
+// must Close it.
+func NewServer(svc service.OpcXmlDASoap) *Server {
+	return &Server{Server: httptest.NewServer(service.NewHandler(svc))}
+}
+
+// Service returns a generated SOAP client connected to the server.
+func (s *Server) Service(opts ...soap.Option) service.OpcXmlDASoap {
+	return service.NewOpcXmlDASoap(soap.NewClient(s.URL, opts...))
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+type securityHeader struct {
+	XMLName xml.Name `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd Security"`
+	Token   struct {
+		Username string `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd Username"`
+		Password struct {
+			Type  string `xml:"Type,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd Password"`
+		Nonce   string `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd Nonce"`
+		Created string `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd Created"`
+	} `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd UsernameToken"`
+}
+
+func TestUsernameTokenPasswordText(t *testing.T) {
+	data, err := xml.Marshal(&UsernameToken{Username: "operator", Password: "s3cret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var header securityHeader
+	if err := xml.Unmarshal(data, &header); err != nil {
+		t.Fatalf("unmarshal %s: %v", data, err)
+	}
+	if header.Token.Username != "operator" || header.Token.Password.Value != "s3cret" || header.Token.Password.Type != WSSEPasswordText {
+		t.Fatalf("token = %+v", header.Token)
+	}
+	if strings.Contains(string(data), "Nonce") {
+		t.Fatalf("PasswordText token carries a nonce: %s", data)
+	}
+}
+
+func TestUsernameTokenPasswordDigest(t *testing.T) {
+	random := make([]byte, 32)
+	for i := range random {
+		random[i] = byte(i)
+	}
+	created := time.Date(2026, 10, 18, 8, 30, 0, 0, time.UTC)
+	token := &UsernameToken{
+		Username: "operator",
+		Password: "s3cret",
+		Digest:   true,
+		Now:      func() time.Time { return created },
+		Random:   bytes.NewReader(random),
+	}
+	first, err := xml.Marshal(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var header securityHeader
+	if err := xml.Unmarshal(first, &header); err != nil {
+		t.Fatalf("unmarshal %s: %v", first, err)
+	}
+	got := header.Token
+	if got.Created != "2026-10-18T08:30:00.000Z" || len(got.Nonce) == 0 || got.Password.Type != WSSEPasswordDigest {
+		t.Fatalf("token = %+v", got)
+	}
+	nonce, err := base64.StdEncoding.DecodeString(got.Nonce)
+	if err != nil || !bytes.Equal(nonce, random[:16]) {
+		t.Fatalf("nonce = %q", got.Nonce)
+	}
+	if got.Password.Value != PasswordDigest(nonce, got.Created, "s3cret") {
+		t.Fatalf("password digest %q does not match nonce and created", got.Password.Value)
+	}
+
+	second, err := xml.Marshal(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(first, second) {
+		t.Fatal("marshalling twice reused the nonce")
+	}
+}
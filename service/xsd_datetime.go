@@ -2,6 +2,7 @@ package service
 
 import (
 	"encoding/xml"
+	"regexp"
 	"strings"
 	"time"
 
@@ -11,6 +12,8 @@ import (
 // XSDDateTime wraps soap.XSDDateTime with RFC3339 handling.
 type XSDDateTime struct {
 	soap.XSDDateTime
+	// zoneless records a parsed value that had no zone offset.
+	zoneless bool
 }
 
 // UnmarshalXMLAttr parses an XML attribute into an XSDDateTime.
@@ -20,9 +23,21 @@ func (xdt *XSDDateTime) UnmarshalXMLAttr(attr xml.Attr) error {
 		return err
 	}
 	xdt.XSDDateTime = soap.CreateXsdDateTime(parsed, hasTz)
+	xdt.zoneless = !hasTz
 	return nil
 }
 
+// inZone reads a value parsed without a zone offset as wall clock time in
+// loc.
+func (xdt *XSDDateTime) inZone(loc *time.Location) {
+	if !xdt.zoneless {
+		return
+	}
+	t := xdt.ToGoTime()
+	xdt.XSDDateTime = soap.CreateXsdDateTime(time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc), true)
+	xdt.zoneless = false
+}
+
 // MarshalXMLAttr formats the XSDDateTime as an XML attribute.
 func (xdt XSDDateTime) MarshalXMLAttr(name xml.Name) (xml.Attr, error) {
 	t := xdt.ToGoTime()
@@ -32,10 +47,20 @@ func (xdt XSDDateTime) MarshalXMLAttr(name xml.Name) (xml.Attr, error) {
 	return xml.Attr{Name: name, Value: t.Format(time.RFC3339Nano)}, nil
 }
 
+// compactOffset matches a zone offset written without a colon, +0100.
+var compactOffset = regexp.MustCompile(`(T[^+-]*[+-][0-9]{2})([0-9]{2})$`)
+
+// parseXsdDateTime parses an xsd:dateTime and reports whether it had a zone.
+// It also accepts a space between date and time and offsets without a
+// colon, which some servers write.
 func parseXsdDateTime(value string) (time.Time, bool, error) {
 	if value == "" {
 		return time.Time{}, true, nil
 	}
+	if len(value) > 10 && value[10] == ' ' {
+		value = value[:10] + "T" + value[11:]
+	}
+	value = compactOffset.ReplaceAllString(value, "${1}:${2}")
 
 	hasTz := false
 	if strings.Contains(value, "T") {